@@ -6,36 +6,112 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/btcsuite/btcutil"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/accesslog"
 	"github.com/revolutionchain/charon/pkg/analytics"
+	"github.com/revolutionchain/charon/pkg/apikeys"
+	"github.com/revolutionchain/charon/pkg/logging"
 	"github.com/revolutionchain/charon/pkg/notifier"
 	"github.com/revolutionchain/charon/pkg/params"
 	"github.com/revolutionchain/charon/pkg/revo"
 	"github.com/revolutionchain/charon/pkg/server"
+	"github.com/revolutionchain/charon/pkg/tracing"
 	"github.com/revolutionchain/charon/pkg/transformer"
+	"github.com/revolutionchain/charon/pkg/txtracker"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
 	app = kingpin.New("charon", "Revo adapter to Ethereum JSON RPC")
 
-	accountsFile = app.Flag("accounts", "account private keys (in WIF) returned by eth_accounts").Envar("ACCOUNTS").File()
-
-	revoRPC             = app.Flag("revo-rpc", "URL of revo RPC service").Envar("REVO_RPC").Default("").String()
+	configFile     = app.Flag("config", "YAML or TOML file of settings named like these flags, which override it, reloaded on SIGHUP or when it changes").Envar("CONFIG").Default("").String()
+	accountsFile   = app.Flag("accounts", "file of account private keys (in WIF, or BIP38 encrypted WIF which stay locked until personal_unlockAccount) returned by eth_accounts").Envar("ACCOUNTS").ExistingFile()
+	keyStoreDir    = app.Flag("keystore", "directory of keystore V3 JSON files (scrypt or pbkdf2), accounts stay locked until personal_unlockAccount").Envar("KEYSTORE").Default("").String()
+	lightKDF       = app.Flag("keystore-lightkdf", "[Insecure] encrypt new key files with cheaper scrypt parameters").Envar("KEYSTORE_LIGHTKDF").Default("false").Bool()
+	mnemonic       = app.Flag("mnemonic", "BIP39 mnemonic to derive accounts returned by eth_accounts from").Envar("MNEMONIC").Default("").String()
+	derivationPath = app.Flag("derivation-path", "BIP32 path of the accounts derived from --mnemonic, the account index is appended to it").Envar("DERIVATION_PATH").Default(revo.DefaultDerivationPath).String()
+	mnemonicCount  = app.Flag("mnemonic-count", "number of accounts to derive from --mnemonic").Envar("MNEMONIC_COUNT").Default("10").Int()
+	signerEndpoint = app.Flag("signer", "external signer (http(s):// URL or unix socket path) holding keys charon never sees, signing requests use Clef's account_* API").Envar("SIGNER").Default("").String()
+	signerTimeout  = app.Flag("signer-timeout", "how long to wait for the external signer, which may wait for a human to approve").Envar("SIGNER_TIMEOUT").Default("30s").Duration()
+	personalAPI    = app.Flag("personal-api", "enable personal_newAccount, personal_importRawKey, personal_listAccounts and personal_lockAccount: 'disabled', 'localhost' (only for requests from the loopback interface) or 'enabled'").Envar("PERSONAL_API").Default("disabled").Enum("disabled", "localhost", "enabled")
+	adminAPI       = app.Flag("admin-api", "enable admin_setLogLevel and admin_logLevels: 'disabled', 'localhost' (only for requests from the loopback interface) or 'enabled'").Envar("ADMIN_API").Default("disabled").Enum("disabled", "localhost", "enabled")
+
+	revoRPC             = app.Flag("revo-rpc", "URL of revo RPC service, comma separated URLs of several revod nodes spread reads over the healthy ones at the tip").Envar("REVO_RPC").Default("").String()
+	revoRPCWallet       = app.Flag("revo-rpc.wallet", "comma separated URLs, in order of preference, of the revod nodes wallet calls and broadcasts go to, the first --revo-rpc node if empty").Envar("REVO_RPC_WALLET").Default("").String()
+	revoRPCMaxLag       = app.Flag("revo-rpc.max-lag", "blocks a revod node may be behind the highest one and still get reads").Envar("REVO_RPC_MAX_LAG").Default("0").Int64()
+	revoRPCCheck        = app.Flag("revo-rpc.check-interval", "how often the height of each revod node is checked, with several of them").Envar("REVO_RPC_CHECK_INTERVAL").Default(revo.DefaultUpstreamCheckInterval.String()).Duration()
+	revoRPCBreaker      = app.Flag("revo-rpc.breaker.failures", "failed calls in a row, unreachable, timed out or busy, after which calls to a revod node fail at once, 0 never stops calling it").Envar("REVO_RPC_BREAKER_FAILURES").Default(strconv.Itoa(int(revo.DefaultBreakerConfig().Failures))).Uint32()
+	revoRPCBreakerOpen  = app.Flag("revo-rpc.breaker.open-timeout", "how long calls to a revod node fail at once before it's probed").Envar("REVO_RPC_BREAKER_OPEN_TIMEOUT").Default(revo.DefaultBreakerConfig().OpenTimeout.String()).Duration()
+	revoRPCProbes       = app.Flag("revo-rpc.breaker.probes", "calls let through to probe a revod node, it's called again once they all succeeded").Envar("REVO_RPC_BREAKER_PROBES").Default(strconv.Itoa(int(revo.DefaultBreakerConfig().Probes))).Uint32()
+	revoRPCBatchSize    = app.Flag("revo-rpc.batch-size", "most calls sent to revod in one JSON-RPC batch, 1 sends calls one at a time").Envar("REVO_RPC_BATCH_SIZE").Default(strconv.Itoa(revo.DefaultBatchSize)).Int()
 	revoNetwork         = app.Flag("revo-network", "if 'regtest' (or connected to a regtest node with 'auto') Charon will generate blocks").Envar("REVO_NETWORK").Default("auto").String()
 	generateToAddressTo = app.Flag("generateToAddressTo", "[regtest only] configure address to mine blocks to when mining new transactions in blocks").Envar("GENERATE_TO_ADDRESS").Default("").String()
 	bind                = app.Flag("bind", "network interface to bind to (e.g. 0.0.0.0) ").Default("localhost").String()
 	port                = app.Flag("port", "port to serve proxy").Default("23889").Int()
 	httpsKey            = app.Flag("https-key", "https keyfile").Default("").String()
 	httpsCert           = app.Flag("https-cert", "https certificate").Default("").String()
-	logFile             = app.Flag("log-file", "write logs to a file").Envar("LOG_FILE").Default("").String()
+	logFile             = app.Flag("log-file", "write logs to a file as well, appending to it if it exists").Envar("LOG_FILE").Default("").String()
 	matureBlockHeight   = app.Flag("mature-block-height-override", "override how old a coinbase/coinstake needs to be to be considered mature enough for spending (REVO uses 2000 blocks after the 32s block fork) - if this value is incorrect transactions can be rejected").Int()
 	healthCheckPercent  = app.Flag("health-check-healthy-request-amount", "configure the minimum request success rate for healthcheck").Envar("HEALTH_CHECK_REQUEST_PERCENT").Default("80").Int()
 
+	logFormat         = app.Flag("log.format", "format of log records: 'logfmt' or 'json'").Envar("LOG_FORMAT").Default(logging.FormatLogfmt).Enum(logging.FormatLogfmt, logging.FormatJSON)
+	logLevel          = app.Flag("log.level", "minimum level of logged records: 'debug', 'info', 'warn' or 'error', 'debug' with --dev and 'warn' otherwise if empty").Envar("LOG_LEVEL").Default("").String()
+	logLevels         = app.Flag("log.levels", "comma separated component=level overriding --log.level for transformer, revo.Client, notifier, clientCache or server").Envar("LOG_LEVELS").Default("").String()
+	logDumpFile       = app.Flag("log.dump-file", "[Development] file the --dev request and response dumps are written to instead of stdout, they are never written to --log-file").Envar("LOG_DUMP_FILE").Default("").String()
+	logFileMaxSize    = app.Flag("log-file.max-size", "size in megabytes at which --log-file (and the dump, access and slow logs) is rotated").Envar("LOG_FILE_MAX_SIZE").Default("100").Int()
+	logFileRotate     = app.Flag("log-file.rotate-every", "rotate --log-file this often on top of its size, 0 only rotates on size").Envar("LOG_FILE_ROTATE_EVERY").Default("0").Duration()
+	logFileMaxBackups = app.Flag("log-file.max-backups", "rotated log files kept, 0 keeps them all").Envar("LOG_FILE_MAX_BACKUPS").Default("0").Int()
+	logFileMaxAge     = app.Flag("log-file.max-age", "days rotated log files are kept, 0 keeps them regardless of their age").Envar("LOG_FILE_MAX_AGE").Default("0").Int()
+	logFileCompress   = app.Flag("log-file.compress", "gzip rotated log files").Envar("LOG_FILE_COMPRESS").Bool()
+
+	accessLogFile       = app.Flag("access-log", "write every call's method, duration, status, client IP and API key to this file, or to stdout with 'stdout'").Envar("ACCESS_LOG").Default("").String()
+	accessLogSample     = app.Flag("access-log.sample-ratio", "fraction of the calls in the access log written with their params and result").Envar("ACCESS_LOG_SAMPLE_RATIO").Default("0").Float64()
+	accessLogRedact     = app.Flag("access-log.redact-methods", "comma separated methods whose params and result are never written to the access log").Envar("ACCESS_LOG_REDACT_METHODS").Default(strings.Join(accesslog.DefaultRedactMethods, ",")).String()
+	accessLogRedactKeys = app.Flag("access-log.redact-fields", "comma separated object fields whose values are never written to the access log").Envar("ACCESS_LOG_REDACT_FIELDS").Default(strings.Join(accesslog.DefaultRedactFields, ",")).String()
+	slowLogFile         = app.Flag("slow-log", "write the calls slower than --slow-log.threshold to this file, or to stdout with 'stdout', instead of the log").Envar("SLOW_LOG").Default("").String()
+	slowLogThreshold    = app.Flag("slow-log.threshold", "calls taking this long are logged with the revod calls they made, 0 doesn't log them").Envar("SLOW_LOG_THRESHOLD").Default("0").Duration()
+
+	cacheMaxSize       = app.Flag("cache.max-size", "megabytes of revod responses cached, the least recently used are evicted past it").Envar("CACHE_MAX_SIZE").Default("64").Int64()
+	cacheTTL           = app.Flag("cache.ttl", "how long revod responses are cached").Envar("CACHE_TTL").Default(revo.DefaultCacheConfig().TTL.String()).Duration()
+	cacheTTLs          = app.Flag("cache.ttls", "comma separated method=duration overriding --cache.ttl for getblock, getrawtransaction, gettxout, gethexaddress or decoderawtransaction, 0 doesn't cache the method").Envar("CACHE_TTLS").Default("").String()
+	cacheFinalTTL      = app.Flag("cache.final-ttl", "how long blocks and transactions with --cache.finality-depth confirmations, and responses which can't change, are cached").Envar("CACHE_FINAL_TTL").Default(revo.DefaultCacheConfig().FinalTTL.String()).Duration()
+	cacheFinalityDepth = app.Flag("cache.finality-depth", "confirmations after which a block or transaction is cached for --cache.final-ttl").Envar("CACHE_FINALITY_DEPTH").Default(strconv.FormatInt(revo.DefaultCacheConfig().FinalityDepth, 10)).Int64()
+
+	txPollInterval       = app.Flag("tx-poll-interval", "how often broadcast transactions are checked and rebroadcast if they left the mempool").Envar("TX_POLL_INTERVAL").Default("30s").Duration()
+	txFinalConfirmations = app.Flag("tx-final-confirmations", "confirmations after which a broadcast transaction is no longer checked").Envar("TX_FINAL_CONFIRMATIONS").Default("20").Int64()
+	txRetention          = app.Flag("tx-retention", "how long charon_getTransactionStatus remembers a transaction after its status last changed").Envar("TX_RETENTION").Default("24h").Duration()
+
+	httpAPI   = app.Flag("http.api", "comma separated namespaces (eth, net, web3, personal, revo, charon, dev...) exposed over HTTP, all of them if empty").Envar("HTTP_API").Default("").String()
+	httpAllow = app.Flag("http.allow", "comma separated methods exposed over HTTP even if their namespace isn't in --http.api").Envar("HTTP_ALLOW").Default("").String()
+	httpDeny  = app.Flag("http.deny", "comma separated methods never exposed over HTTP").Envar("HTTP_DENY").Default("").String()
+	wsAPI     = app.Flag("ws.api", "comma separated namespaces exposed over websockets, all of them if empty").Envar("WS_API").Default("").String()
+	wsAllow   = app.Flag("ws.allow", "comma separated methods exposed over websockets even if their namespace isn't in --ws.api").Envar("WS_ALLOW").Default("").String()
+	wsDeny    = app.Flag("ws.deny", "comma separated methods never exposed over websockets").Envar("WS_DENY").Default("").String()
+
+	rateLimit      = app.Flag("rate-limit", "calls per second allowed per client IP (each call of a batch counts), 0 is unlimited").Envar("RATE_LIMIT").Default("0").Float64()
+	rateLimitBurst = app.Flag("rate-limit-burst", "calls a client IP may send at once above --rate-limit").Envar("RATE_LIMIT_BURST").Default("20").Int()
+	trustedProxies = app.Flag("trusted-proxies", "comma separated IPs and CIDR ranges of reverse proxies whose X-Forwarded-For header gives the client IP").Envar("TRUSTED_PROXIES").Default("").String()
+	maxBodySize    = app.Flag("max-body-size", "maximum size of an HTTP request body, 0 is unlimited").Envar("MAX_BODY_SIZE").Default(strconv.Itoa(server.DefaultMaxBodySize)).Int64()
+	maxBatchCalls  = app.Flag("max-batch-calls", "maximum number of calls in a batch, 0 is unlimited").Envar("MAX_BATCH_CALLS").Default(strconv.Itoa(server.DefaultMaxBatchCalls)).Int()
+	wsMaxFrameSize = app.Flag("ws.max-frame-size", "maximum size of a websocket message, 0 is unlimited").Envar("WS_MAX_FRAME_SIZE").Default(strconv.Itoa(server.DefaultMaxFrameSize)).Int64()
+	batchWorkers   = app.Flag("batch-workers", "how many calls of a batch run at once").Envar("BATCH_WORKERS").Default(strconv.Itoa(server.DefaultBatchWorkers)).Int()
+
+	otelExporter    = app.Flag("otel.exporter", "where OpenTelemetry traces are sent: 'none', 'otlp' (gRPC) or 'stdout'").Envar("OTEL_EXPORTER").Default(tracing.ExporterNone).Enum(tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout)
+	otelEndpoint    = app.Flag("otel.endpoint", "host:port of the OTLP collector, OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317 if empty").Envar("OTEL_ENDPOINT").Default("").String()
+	otelInsecure    = app.Flag("otel.insecure", "connect to the OTLP collector without TLS").Envar("OTEL_INSECURE").Bool()
+	otelSampleRatio = app.Flag("otel.sample-ratio", "fraction of the traces started by charon which are recorded, traces started by clients follow their traceparent").Envar("OTEL_SAMPLE_RATIO").Default("1").Float64()
+
+	metricsEnabled = app.Flag("metrics", "serve Prometheus metrics at /metrics, which doesn't require an API key").Envar("METRICS").Bool()
+
+	apiKeysFile = app.Flag("api-keys", "YAML or JSON file of the API keys requests must carry, with their quotas and allowed methods, reloaded on SIGHUP. The keys can be listed in --config instead").Envar("API_KEYS").Default("").String()
+
 	sqlHost     = app.Flag("sql-host", "database hostname").Envar("SQL_HOST").Default("127.0.0.1").String()
 	sqlPort     = app.Flag("sql-port", "database port").Envar("SQL_PORT").Default("5432").Int()
 	sqlUser     = app.Flag("sql-user", "database username").Envar("SQL_USER").Default("postgres").String()
@@ -53,26 +129,37 @@ var (
 	hideRevodLogs             = app.Flag("hideRevodLogs", "[Development] Hide REVOD debug logs").Envar("HIDE_REVOD_LOGS").Default("false").Bool()
 )
 
-func loadAccounts(r io.Reader, l log.Logger) revo.Accounts {
+func loadAccounts(r io.Reader, keyStore *revo.KeyStore, l log.Logger) revo.Accounts {
 	var accounts revo.Accounts
+	encrypted := 0
 
-	if accountsFile != nil {
-		s := bufio.NewScanner(*accountsFile)
-		for s.Scan() {
-			line := s.Text()
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
 
-			wif, err := btcutil.DecodeWIF(line)
-			if err != nil {
-				level.Error(l).Log("msg", "Failed to parse account", "err", err.Error())
+		if revo.IsBIP38(line) {
+			if err := keyStore.AddBIP38(line); err != nil {
+				level.Error(l).Log("msg", "Failed to parse encrypted account", "err", err.Error())
 				continue
 			}
+			encrypted++
+			continue
+		}
 
-			accounts = append(accounts, wif)
+		wif, err := btcutil.DecodeWIF(line)
+		if err != nil {
+			level.Error(l).Log("msg", "Failed to parse account", "err", err.Error())
+			continue
 		}
+
+		accounts = append(accounts, wif)
 	}
 
-	if len(accounts) > 0 {
-		level.Info(l).Log("msg", fmt.Sprintf("Loaded %d accounts", len(accounts)))
+	if len(accounts) > 0 || encrypted > 0 {
+		level.Info(l).Log("msg", fmt.Sprintf("Loaded %d accounts and %d encrypted accounts", len(accounts), encrypted))
 	} else {
 		level.Warn(l).Log("msg", "No accounts loaded from account file")
 	}
@@ -80,56 +167,145 @@ func loadAccounts(r io.Reader, l log.Logger) revo.Accounts {
 	return accounts
 }
 
+// loadAllAccounts returns the accounts of the file at 'path', if any, and those derived from 'mnemonic'. Encrypted
+// accounts are added to 'keyStore'
+func loadAllAccounts(path string, mnemonic string, derivationPath string, count int, keyStore *revo.KeyStore, l log.Logger) (revo.Accounts, error) {
+	var accounts revo.Accounts
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to open accounts file")
+		}
+		accounts = loadAccounts(file, keyStore, l)
+		file.Close()
+	}
+
+	if mnemonic != "" {
+		derived, err := revo.DeriveAccounts(mnemonic, "", derivationPath, count)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to derive accounts from mnemonic")
+		}
+		level.Info(l).Log("msg", fmt.Sprintf("Derived %d accounts from mnemonic at %s", len(derived), derivationPath))
+		accounts = append(accounts, derived...)
+	}
+
+	return accounts, nil
+}
+
 func action(pc *kingpin.ParseContext) error {
 	addr := fmt.Sprintf("%s:%d", *bind, *port)
+
+	ctx, shutdownRevo := context.WithCancel(context.Background())
+	defer shutdownRevo()
+
 	writers := []io.Writer{os.Stdout}
+	if *logFile != "" {
+		file, err := logging.OpenFile(ctx, logFileConfig(*logFile))
+		if err != nil {
+			return err
+		}
+		writers = append(writers, file)
+	}
 
-	if logFile != nil && (*logFile) != "" {
-		_, err := os.Stat(*logFile)
-		if os.IsNotExist(err) {
-			newLogFile, err := os.Create(*logFile)
-			if err != nil {
-				return errors.Wrapf(err, "Failed to create log file %s", *logFile)
-			} else {
-				writers = append(writers, newLogFile)
-			}
-		} else {
-			existingLogFile, err := os.Open(*logFile)
-			if err != nil {
-				return errors.Wrapf(err, "Failed to open log file %s", *logFile)
-			} else {
-				writers = append(writers, existingLogFile)
-			}
+	// request and response dumps, kept out of the log file
+	var dumpWriter io.Writer = os.Stdout
+	if *logDumpFile != "" {
+		file, err := logging.OpenFile(ctx, logFileConfig(*logDumpFile))
+		if err != nil {
+			return err
 		}
+		dumpWriter = file
 	}
 
-	logWriter := io.MultiWriter(writers...)
-	logger := log.NewLogfmtLogger(logWriter)
+	levels, err := logging.NewLevels(defaultLogLevel(*logLevel))
+	if err != nil {
+		return errors.Wrap(err, "Invalid --log.level")
+	}
+	if err := levels.SetAll(*logLevels); err != nil {
+		return errors.Wrap(err, "Invalid --log.levels")
+	}
 
-	if !*devMode {
-		logger = level.NewFilter(logger, level.AllowWarn())
+	logger, err := logging.NewLogger(io.MultiWriter(writers...), *logFormat, levels)
+	if err != nil {
+		return err
 	}
 
-	var accounts revo.Accounts
-	if *accountsFile != nil {
-		accounts = loadAccounts(*accountsFile, logger)
-		(*accountsFile).Close()
+	accessLog, err := newAccessLog(ctx, logger)
+	if err != nil {
+		return err
+	}
+
+	keyStore, err := revo.NewKeyStore(*keyStoreDir)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load key store")
+	}
+	keyStore.SetLightKDF(*lightKDF)
+	if addresses := keyStore.Addresses(); len(addresses) > 0 {
+		level.Info(logger).Log("msg", fmt.Sprintf("Loaded %d locked accounts from key store", len(addresses)))
+	}
+
+	accounts, err := loadAllAccounts(*accountsFile, *mnemonic, *derivationPath, *mnemonicCount, keyStore, logger)
+	if err != nil {
+		return err
+	}
+
+	var signer revo.Signer
+	if *signerEndpoint != "" {
+		externalSigner, err := revo.NewExternalSigner(*signerEndpoint, *signerTimeout)
+		if err != nil {
+			return errors.Wrap(err, "Failed to setup external signer")
+		}
+		signer = externalSigner
 	}
 
 	isMain := *revoNetwork == revo.ChainMain
 
-	ctx, shutdownRevo := context.WithCancel(context.Background())
-	defer shutdownRevo()
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:       *otelExporter,
+		Endpoint:       *otelEndpoint,
+		Insecure:       *otelInsecure,
+		SampleRatio:    *otelSampleRatio,
+		ServiceVersion: params.VersionWithGitSha,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to setup tracing")
+	}
+	defer func() {
+		// flush the spans not exported yet
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			level.Error(logger).Log("msg", "Failed to flush traces", "err", err)
+		}
+	}()
 
 	revoRequestAnalytics := analytics.NewAnalytics(50)
 
+	cacheConfig, err := newCacheConfig()
+	if err != nil {
+		return err
+	}
+
+	revoRPCs := splitList(*revoRPC)
+	if len(revoRPCs) == 0 {
+		revoRPCs = []string{""}
+	}
 	revoJSONRPC, err := revo.NewClient(
 		isMain,
-		*revoRPC,
+		revoRPCs[0],
+		revo.SetUpstreams(revoRPCs[1:]),
+		revo.SetWalletUpstreams(splitList(*revoRPCWallet)),
+		revo.SetUpstreamMaxLag(*revoRPCMaxLag),
+		revo.SetUpstreamCheckInterval(*revoRPCCheck),
+		revo.SetBatchSize(*revoRPCBatchSize),
+		revo.SetBreakerConfig(revo.BreakerConfig{Failures: *revoRPCBreaker, OpenTimeout: *revoRPCBreakerOpen, Probes: *revoRPCProbes}),
+		revo.SetCacheConfig(cacheConfig),
 		revo.SetDebug(*devMode),
-		revo.SetLogWriter(logWriter),
+		revo.SetLogWriter(dumpWriter),
 		revo.SetLogger(logger),
 		revo.SetAccounts(accounts),
+		revo.SetKeyStore(keyStore),
+		revo.SetSigner(signer),
 		revo.SetGenerateToAddress(*generateToAddressTo),
 		revo.SetIgnoreUnknownTransactions(*ignoreUnknownTransactions),
 		revo.SetDisableSnippingRevoRpcOutput(*disableSnipping),
@@ -154,8 +330,25 @@ func action(pc *kingpin.ParseContext) error {
 		return errors.Wrap(err, "Failed to setup REVO chain")
 	}
 
+	tracker, err := txtracker.New(
+		revoClient,
+		txtracker.SetPollInterval(*txPollInterval),
+		txtracker.SetFinalConfirmations(*txFinalConfirmations),
+		txtracker.SetRetention(*txRetention),
+	)
+	if err != nil {
+		return errors.Wrap(err, "txtracker#New")
+	}
+	tracker.Start(ctx)
+
 	agent := notifier.NewAgent(context.Background(), revoClient, nil)
-	proxies := transformer.DefaultProxies(revoClient, agent)
+	proxies := transformer.DefaultProxies(revoClient, agent, tracker)
+	if *personalAPI != "disabled" {
+		proxies = append(proxies, transformer.PersonalProxies(revoClient, *personalAPI == "localhost")...)
+	}
+	if *adminAPI != "disabled" {
+		proxies = append(proxies, transformer.AdminProxies(levels, *adminAPI == "localhost")...)
+	}
 	t, err := transformer.New(
 		revoClient,
 		proxies,
@@ -167,6 +360,21 @@ func action(pc *kingpin.ParseContext) error {
 	}
 	agent.SetTransformer(t)
 
+	var apiKeys *apikeys.Registry
+	if *apiKeysFile != "" {
+		apiKeys, err = apikeys.Load(*apiKeysFile)
+		if err != nil {
+			return errors.Wrap(err, "Failed to load API keys")
+		}
+		level.Info(logger).Log("msg", fmt.Sprintf("Loaded %d API keys", apiKeys.Len()))
+	} else if loadedConfig != nil && loadedConfig.Keys != nil {
+		apiKeys, err = apikeys.NewRegistry(loadedConfig.Keys)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load API keys from %s", *configFile)
+		}
+		level.Info(logger).Log("msg", fmt.Sprintf("Loaded %d API keys from %s", apiKeys.Len(), *configFile))
+	}
+
 	httpsKeyFile := getEmptyStringIfFileDoesntExist(*httpsKey, logger)
 	httpsCertFile := getEmptyStringIfFileDoesntExist(*httpsCert, logger)
 
@@ -174,21 +382,138 @@ func action(pc *kingpin.ParseContext) error {
 		revoClient,
 		t,
 		addr,
-		server.SetLogWriter(logWriter),
+		server.SetLogWriter(dumpWriter),
 		server.SetLogger(logger),
 		server.SetDebug(*devMode),
 		server.SetSingleThreaded(*singleThreaded),
 		server.SetHttps(httpsKeyFile, httpsCertFile),
 		server.SetRevoAnalytics(revoRequestAnalytics),
 		server.SetHealthCheckPercent(healthCheckPercent),
+		server.SetHTTPMethodFilter(transformer.NewMethodFilter(splitList(*httpAPI), splitList(*httpAllow), splitList(*httpDeny))),
+		server.SetWSMethodFilter(transformer.NewMethodFilter(splitList(*wsAPI), splitList(*wsAllow), splitList(*wsDeny))),
+		server.SetAPIKeys(apiKeys),
+		server.SetRateLimit(*rateLimit, *rateLimitBurst),
+		server.SetTrustedProxies(splitList(*trustedProxies)),
+		server.SetMaxBodySize(*maxBodySize),
+		server.SetMaxBatchCalls(*maxBatchCalls),
+		server.SetMaxFrameSize(*wsMaxFrameSize),
+		server.SetBatchWorkers(*batchWorkers),
+		server.SetMetrics(*metricsEnabled),
+		server.SetAccessLog(accessLog),
 	)
 	if err != nil {
 		return errors.Wrap(err, "server#New")
 	}
 
+	reloader := &reloader{
+		path:       *configFile,
+		file:       loadedConfig,
+		overridden: overriddenSettings(pc),
+		logger:     logger,
+		levels:     levels,
+		client:     revoJSONRPC,
+		keyStore:   keyStore,
+		server:     s,
+		apiKeys:    apiKeys,
+	}
+	if *configFile != "" || *apiKeysFile != "" {
+		go reloader.run(ctx)
+	}
+
 	return s.Start()
 }
 
+// newAccessLog returns the access log and the slow log, nil if neither is enabled. The slow log goes to the log
+// unless it has a file of its own
+func newAccessLog(ctx context.Context, logger log.Logger) (*accesslog.Logger, error) {
+	if *accessLogFile == "" && *slowLogThreshold == 0 {
+		return nil, nil
+	}
+
+	var access log.Logger
+	if *accessLogFile != "" {
+		w, err := openLogSink(ctx, *accessLogFile)
+		if err != nil {
+			return nil, err
+		}
+		if access, err = logging.NewLogger(w, *logFormat, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	slow := level.Warn(log.With(logger, logging.ComponentKey, "server"))
+	if *slowLogFile != "" {
+		w, err := openLogSink(ctx, *slowLogFile)
+		if err != nil {
+			return nil, err
+		}
+		if slow, err = logging.NewLogger(w, *logFormat, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return accesslog.New(access, slow, accesslog.Config{
+		SampleRatio:   *accessLogSample,
+		SlowThreshold: *slowLogThreshold,
+		RedactMethods: splitList(*accessLogRedact),
+		RedactFields:  splitList(*accessLogRedactKeys),
+	}), nil
+}
+
+// openLogSink opens a log file rotated like --log-file, or stdout for "stdout"
+func openLogSink(ctx context.Context, path string) (io.Writer, error) {
+	if path == "stdout" {
+		return os.Stdout, nil
+	}
+	return logging.OpenFile(ctx, logFileConfig(path))
+}
+
+// logFileConfig is a log file rotated as the --log-file.* flags say
+func logFileConfig(path string) logging.FileConfig {
+	return logging.FileConfig{
+		Path:        path,
+		MaxSize:     *logFileMaxSize,
+		RotateEvery: *logFileRotate,
+		MaxBackups:  *logFileMaxBackups,
+		MaxAge:      *logFileMaxAge,
+		Compress:    *logFileCompress,
+	}
+}
+
+// newCacheConfig returns the revod response cache settings of the --cache flags
+func newCacheConfig() (revo.CacheConfig, error) {
+	config := revo.CacheConfig{
+		MaxBytes:      *cacheMaxSize * 1024 * 1024,
+		TTL:           *cacheTTL,
+		TTLs:          make(map[string]time.Duration),
+		FinalTTL:      *cacheFinalTTL,
+		FinalityDepth: *cacheFinalityDepth,
+	}
+	for _, item := range splitList(*cacheTTLs) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return config, errors.Errorf("Invalid --cache.ttls item %q, expected method=duration", item)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return config, errors.Wrapf(err, "Invalid --cache.ttls duration for %s", parts[0])
+		}
+		config.TTLs[strings.TrimSpace(parts[0])] = ttl
+	}
+	return config, nil
+}
+
+// splitList splits a comma separated flag value
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEmptyStringIfFileDoesntExist(file string, l log.Logger) string {
 	_, err := os.Stat(file)
 	if os.IsNotExist(err) {
@@ -200,6 +525,9 @@ func getEmptyStringIfFileDoesntExist(file string, l log.Logger) string {
 
 func Run() {
 	app.Version(params.VersionWithGitSha)
+	if err := loadConfig(os.Args[1:]); err != nil {
+		app.Fatalf("%s", err)
+	}
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 }
 
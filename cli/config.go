@@ -0,0 +1,312 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/apikeys"
+	"github.com/revolutionchain/charon/pkg/config"
+	"github.com/revolutionchain/charon/pkg/logging"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/server"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// how often the configuration file is checked for changes
+const configWatchInterval = 5 * time.Second
+
+// settings applied when the configuration is reloaded, the others need a restart
+var reloadableSettings = map[string]bool{
+	"accounts":                            true,
+	"mnemonic":                            true,
+	"mnemonic-count":                      true,
+	"derivation-path":                     true,
+	"log.level":                           true,
+	"log.levels":                          true,
+	"health-check-healthy-request-amount": true,
+	config.KeysSetting:                    true,
+}
+
+// settings whose values aren't logged
+var secretSettings = map[string]bool{
+	"revo-rpc":        true,
+	"revo-rpc.wallet": true,
+	"mnemonic":        true,
+	"sql-password":    true,
+	"dbstring":        true,
+}
+
+var (
+	// the configuration file read at startup, nil without --config
+	loadedConfig *config.File
+	// the defaults of the flags before the configuration file replaced them
+	flagDefaults = map[string]string{}
+)
+
+// loadConfig reads the --config file and makes its settings the defaults of their flags, so that flags and env vars
+// still override them. It runs before the flags are parsed, 'args' are the command line arguments
+func loadConfig(args []string) error {
+	for _, flag := range app.Model().Flags {
+		flagDefaults[flag.Name] = strings.Join(flag.Default, ",")
+	}
+
+	path := configPath(args)
+	if path == "" {
+		return nil
+	}
+	file, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	if err := checkSettings(file); err != nil {
+		return err
+	}
+
+	for setting, value := range file.Values {
+		app.GetFlag(setting).Default(value)
+	}
+	loadedConfig = file
+	return nil
+}
+
+// configPath returns the --config flag, or the CONFIG env var, before the flags are parsed
+func configPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "--config=") {
+			return strings.TrimPrefix(arg, "--config=")
+		}
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv("CONFIG")
+}
+
+// checkSettings fails for a setting which isn't a flag
+func checkSettings(file *config.File) error {
+	for setting := range file.Values {
+		if setting == "config" || app.GetFlag(setting) == nil {
+			return errors.Errorf("unknown setting '%s' in the configuration file", setting)
+		}
+	}
+	return nil
+}
+
+// overriddenSettings are the settings given on the command line or by an env var, the configuration file doesn't
+// change them
+func overriddenSettings(pc *kingpin.ParseContext) map[string]bool {
+	overridden := make(map[string]bool)
+	for _, element := range pc.Elements {
+		if flag, ok := element.Clause.(*kingpin.FlagClause); ok {
+			overridden[flag.Model().Name] = true
+		}
+	}
+	for _, flag := range app.Model().Flags {
+		if flag.Envar != "" && os.Getenv(flag.Envar) != "" {
+			overridden[flag.Name] = true
+		}
+	}
+	return overridden
+}
+
+// defaultLogLevel is the --log.level 'value', debug with --dev and warn otherwise when it's empty
+func defaultLogLevel(value string) string {
+	if value != "" {
+		return value
+	}
+	if *devMode {
+		return logging.LevelDebug
+	}
+	return logging.LevelWarn
+}
+
+// reloader applies the reloadable settings of the configuration file when it changes or on SIGHUP, which also
+// reloads the --api-keys file
+type reloader struct {
+	mutex sync.Mutex
+
+	path string
+	// the configuration applied last
+	file       *config.File
+	overridden map[string]bool
+	logger     log.Logger
+
+	levels   *logging.Levels
+	client   *revo.Client
+	keyStore *revo.KeyStore
+	server   *server.Server
+	// nil when API keys aren't required
+	apiKeys *apikeys.Registry
+}
+
+func (r *reloader) run(ctx context.Context) {
+	if r.path != "" {
+		go config.Watch(ctx, r.path, configWatchInterval, r.reloadConfig)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reloadAPIKeysFile()
+			r.reloadConfig()
+		}
+	}
+}
+
+func (r *reloader) reloadAPIKeysFile() {
+	if *apiKeysFile == "" {
+		return
+	}
+	if err := r.apiKeys.Reload(); err != nil {
+		level.Error(r.logger).Log("msg", "Failed to reload API keys, keeping the current ones", "err", err)
+		return
+	}
+	level.Info(r.logger).Log("msg", fmt.Sprintf("Reloaded %d API keys", r.apiKeys.Len()))
+}
+
+func (r *reloader) reloadConfig() {
+	if r.path == "" {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	file, err := config.Load(r.path)
+	if err == nil {
+		err = checkSettings(file)
+	}
+	if err != nil {
+		level.Error(r.logger).Log("msg", "Failed to reload the configuration, keeping the current one", "err", err)
+		return
+	}
+
+	changes := config.Diff(r.file, file, secretSettings)
+	if len(changes) == 0 {
+		return
+	}
+
+	changed := make(map[string]bool)
+	var applied []config.Change
+	var restart []string
+	for _, change := range changes {
+		switch {
+		case r.overridden[change.Setting]:
+			// the flag or env var still wins
+		case change.Setting == config.KeysSetting && *apiKeysFile != "":
+			// the --api-keys file wins
+		case change.Setting == config.KeysSetting && r.apiKeys == nil:
+			// API keys weren't required at startup
+			restart = append(restart, change.String())
+		case reloadableSettings[change.Setting]:
+			changed[change.Setting] = true
+			applied = append(applied, change)
+		default:
+			restart = append(restart, change.String())
+		}
+	}
+
+	// settings which couldn't be applied keep their current value, they are applied again on the next reload
+	var failed []string
+
+	if changed["log.level"] || changed["log.levels"] {
+		if err := r.levels.Update(defaultLogLevel(r.value(file, "log.level")), r.value(file, "log.levels")); err != nil {
+			level.Error(r.logger).Log("msg", "Failed to reload the log levels", "err", err)
+			failed = append(failed, "log.level", "log.levels")
+		}
+	}
+
+	if changed["health-check-healthy-request-amount"] {
+		percent, err := strconv.Atoi(r.value(file, "health-check-healthy-request-amount"))
+		if err != nil {
+			level.Error(r.logger).Log("msg", "Failed to reload the health check threshold", "err", err)
+			failed = append(failed, "health-check-healthy-request-amount")
+		} else {
+			r.server.UpdateHealthCheckPercent(percent)
+		}
+	}
+
+	if changed["accounts"] || changed["mnemonic"] || changed["mnemonic-count"] || changed["derivation-path"] {
+		if err := r.reloadAccounts(file); err != nil {
+			level.Error(r.logger).Log("msg", "Failed to reload the accounts, keeping the current ones", "err", err)
+			failed = append(failed, "accounts", "mnemonic", "mnemonic-count", "derivation-path")
+		}
+	}
+
+	if changed[config.KeysSetting] {
+		if err := r.apiKeys.Update(file.Keys); err != nil {
+			level.Error(r.logger).Log("msg", "Failed to reload API keys, keeping the current ones", "err", err)
+			failed = append(failed, config.KeysSetting)
+		}
+	}
+
+	r.file = config.Keep(r.file, file, failed...)
+
+	kept := make(map[string]bool, len(failed))
+	for _, setting := range failed {
+		kept[setting] = true
+	}
+	var reloaded []string
+	for _, change := range applied {
+		if !kept[change.Setting] {
+			reloaded = append(reloaded, change.String())
+		}
+	}
+	if len(reloaded) > 0 {
+		level.Info(r.logger).Log("msg", "Reloaded the configuration", "changes", strings.Join(reloaded, ", "))
+	}
+	if len(restart) > 0 {
+		level.Warn(r.logger).Log("msg", "Configuration changes which need a restart", "changes", strings.Join(restart, ", "))
+	}
+}
+
+// value is the value of a setting once 'file' is reloaded: the flag's when it was given on the command line
+// or by an env var, the file's or the default otherwise
+func (r *reloader) value(file *config.File, setting string) string {
+	if r.overridden[setting] {
+		return app.GetFlag(setting).Model().Value.String()
+	}
+	if value, ok := file.Values[setting]; ok {
+		return value
+	}
+	return flagDefaults[setting]
+}
+
+func (r *reloader) reloadAccounts(file *config.File) error {
+	count, err := strconv.Atoi(r.value(file, "mnemonic-count"))
+	if err != nil {
+		return errors.Wrap(err, "invalid mnemonic-count")
+	}
+	accounts, err := loadAllAccounts(
+		r.value(file, "accounts"),
+		r.value(file, "mnemonic"),
+		r.value(file, "derivation-path"),
+		count,
+		r.keyStore,
+		r.logger,
+	)
+	if err != nil {
+		return err
+	}
+	r.client.UpdateAccounts(accounts)
+	return nil
+}
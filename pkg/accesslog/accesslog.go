@@ -0,0 +1,204 @@
+// Package accesslog logs every JSON-RPC call charon answers, with the params and result of a sampled fraction of
+// them minus what sensitive methods and fields shouldn't leak, and logs calls slower than a threshold along with the
+// revod calls they made
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/revolutionchain/charon/pkg/revo"
+)
+
+// Redacted replaces the values which aren't logged
+const Redacted = "[redacted]"
+
+// DefaultRedactMethods carry signed transactions, signatures, passphrases or private keys in their params or result
+var DefaultRedactMethods = []string{
+	"eth_sendRawTransaction",
+	"eth_sendRawTransactionSync",
+	"eth_sign",
+	"eth_signTransaction",
+	"eth_signTypedData_v4",
+	"personal_sign",
+	"personal_unlockAccount",
+	"personal_importRawKey",
+	"personal_newAccount",
+}
+
+// DefaultRedactFields are object fields holding secrets in any method's params or result
+var DefaultRedactFields = []string{"passphrase", "password", "privateKey"}
+
+type Config struct {
+	// fraction of the calls logged with their params and result
+	SampleRatio float64
+	// calls taking this long or longer are written to the slow log, 0 disables it
+	SlowThreshold time.Duration
+	// methods whose params and result are never logged
+	RedactMethods []string
+	// object fields whose values are never logged, at any depth of the params and result, case insensitive
+	RedactFields []string
+}
+
+// Entry is a JSON-RPC call which was answered
+type Entry struct {
+	Method   string
+	Duration time.Duration
+	// code of the JSON-RPC error the call was answered with, 0 when it succeeded
+	Code     int
+	ClientIP string
+	// name of the API key the call was made with, if any
+	APIKey string
+	Params json.RawMessage
+	Result json.RawMessage
+	// revod calls made while answering
+	RevodCalls []revo.Call
+}
+
+type Logger struct {
+	access        log.Logger
+	slow          log.Logger
+	config        Config
+	redactMethods map[string]bool
+	redactFields  map[string]bool
+	// returns a number in [0, 1) to sample calls with
+	random func() float64
+}
+
+// New returns a logger writing every call to 'access' and slow calls to 'slow', either may be nil to disable it
+func New(access log.Logger, slow log.Logger, config Config) *Logger {
+	l := &Logger{
+		access:        access,
+		slow:          slow,
+		config:        config,
+		redactMethods: make(map[string]bool),
+		redactFields:  make(map[string]bool),
+		random:        rand.Float64,
+	}
+	for _, method := range config.RedactMethods {
+		l.redactMethods[method] = true
+	}
+	for _, field := range config.RedactFields {
+		l.redactFields[strings.ToLower(field)] = true
+	}
+	return l
+}
+
+// Log writes 'entry' to the access log, and to the slow log if it took long enough
+func (l *Logger) Log(entry Entry) {
+	if l == nil {
+		return
+	}
+
+	if l.access != nil {
+		keyvals := append(l.keyvals(entry), "revod_calls", len(entry.RevodCalls))
+		if l.config.SampleRatio > 0 && l.random() < l.config.SampleRatio {
+			keyvals = append(keyvals,
+				"params", l.redact(entry.Method, entry.Params),
+				"result", l.redact(entry.Method, entry.Result),
+			)
+		}
+		l.access.Log(keyvals...)
+	}
+
+	if l.slow != nil && l.config.SlowThreshold > 0 && entry.Duration >= l.config.SlowThreshold {
+		var revodDuration time.Duration
+		for _, call := range entry.RevodCalls {
+			revodDuration += call.Duration
+		}
+		keyvals := append([]interface{}{"msg", "slow call"}, l.keyvals(entry)...)
+		keyvals = append(keyvals,
+			"revod_calls", len(entry.RevodCalls),
+			"revod_duration", revodDuration.String(),
+			"revod", breakdown(entry.RevodCalls),
+		)
+		l.slow.Log(keyvals...)
+	}
+}
+
+func (l *Logger) keyvals(entry Entry) []interface{} {
+	status := "ok"
+	if entry.Code != 0 {
+		status = "error"
+	}
+	keyvals := []interface{}{
+		"method", entry.Method,
+		"duration", entry.Duration.String(),
+		"status", status,
+		"code", entry.Code,
+		"ip", entry.ClientIP,
+	}
+	if entry.APIKey != "" {
+		keyvals = append(keyvals, "api_key", entry.APIKey)
+	}
+	return keyvals
+}
+
+// redact returns 'value' as a string without the values of the redacted fields, or Redacted for a redacted method
+func (l *Logger) redact(method string, value json.RawMessage) string {
+	if len(value) == 0 {
+		return ""
+	}
+	if l.redactMethods[method] {
+		return Redacted
+	}
+	if len(l.redactFields) == 0 {
+		return string(value)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		// not logged rather than risking a secret
+		return Redacted
+	}
+	redacted, err := json.Marshal(l.redactValue(decoded))
+	if err != nil {
+		return Redacted
+	}
+	return string(redacted)
+}
+
+func (l *Logger) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range v {
+			if l.redactFields[strings.ToLower(field)] {
+				v[field] = Redacted
+			} else {
+				v[field] = l.redactValue(fieldValue)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = l.redactValue(v[i])
+		}
+	}
+	return value
+}
+
+// breakdown describes each revod call, e.g. "getblock 12ms, getblock cached, getblockhash 8ms shared,
+// gettransaction 30ms 2 retries failed"
+func breakdown(calls []revo.Call) string {
+	described := make([]string, 0, len(calls))
+	for _, call := range calls {
+		description := fmt.Sprintf("%s %s", call.Method, call.Duration.Round(time.Microsecond))
+		if call.Cached {
+			description = call.Method + " cached"
+		}
+		if call.Shared {
+			description += " shared"
+		}
+		if call.Retries > 0 {
+			description += fmt.Sprintf(" %d retries", call.Retries)
+		}
+		if call.Err != nil {
+			description += " failed"
+		}
+		described = append(described, description)
+	}
+	return strings.Join(described, ", ")
+}
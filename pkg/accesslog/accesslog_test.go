@@ -0,0 +1,123 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/revolutionchain/charon/pkg/revo"
+)
+
+func newTestLogger(config Config, sampled bool) (*Logger, *bytes.Buffer, *bytes.Buffer) {
+	var access, slow bytes.Buffer
+	l := New(log.NewJSONLogger(&access), log.NewJSONLogger(&slow), config)
+	l.random = func() float64 {
+		if sampled {
+			return 0
+		}
+		return 0.99
+	}
+	return l, &access, &slow
+}
+
+func decode(t *testing.T, buffer *bytes.Buffer) map[string]interface{} {
+	var record map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatalf("%s: %s", err, buffer.String())
+	}
+	return record
+}
+
+func TestAccessLog(t *testing.T) {
+	l, access, slow := newTestLogger(Config{SampleRatio: 0.5}, false)
+	l.Log(Entry{
+		Method:   "eth_getBalance",
+		Duration: 3 * time.Millisecond,
+		Code:     -32602,
+		ClientIP: "10.0.0.1",
+		APIKey:   "dapp",
+		Params:   json.RawMessage(`["0x7926223070547d2d15b2ef5e7383e541c338ffe9","latest"]`),
+	})
+
+	record := decode(t, access)
+	if record["method"] != "eth_getBalance" || record["duration"] != "3ms" || record["status"] != "error" ||
+		record["code"] != float64(-32602) || record["ip"] != "10.0.0.1" || record["api_key"] != "dapp" {
+		t.Errorf("Unexpected record %v", record)
+	}
+	if _, ok := record["params"]; ok {
+		t.Error("A call which wasn't sampled was logged with its params")
+	}
+	if slow.Len() != 0 {
+		t.Errorf("Unexpected slow log %s", slow.String())
+	}
+}
+
+func TestAccessLogRedaction(t *testing.T) {
+	l, access, _ := newTestLogger(Config{
+		SampleRatio:   1,
+		RedactMethods: DefaultRedactMethods,
+		RedactFields:  DefaultRedactFields,
+	}, true)
+
+	l.Log(Entry{
+		Method: "eth_sendRawTransaction",
+		Params: json.RawMessage(`["0xf86c808504a817c800825208"]`),
+		Result: json.RawMessage(`"0x1234"`),
+	})
+	record := decode(t, access)
+	if record["params"] != Redacted || record["result"] != Redacted {
+		t.Errorf("eth_sendRawTransaction wasn't redacted %v", record)
+	}
+
+	access.Reset()
+	l.Log(Entry{
+		Method: "eth_call",
+		Params: json.RawMessage(`[{"to":"0x01","data":"0x02","nested":{"PrivateKey":"secret"}},"latest"]`),
+		Result: json.RawMessage(`"0x"`),
+	})
+	record = decode(t, access)
+	params := record["params"].(string)
+	if strings.Contains(params, "secret") || !strings.Contains(params, `"data":"0x02"`) {
+		t.Errorf("Unexpected params %s", params)
+	}
+	if record["result"] != `"0x"` {
+		t.Errorf("Unexpected result %v", record["result"])
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	l, _, slow := newTestLogger(Config{SlowThreshold: time.Second}, false)
+	l.Log(Entry{
+		Method:   "eth_getLogs",
+		Duration: 2 * time.Second,
+		RevodCalls: []revo.Call{
+			{Method: "searchlogs", Duration: 1500 * time.Millisecond, Retries: 1},
+			{Method: "getblock", Cached: true},
+			{Method: "getblockhash", Duration: 10 * time.Millisecond, Shared: true},
+			{Method: "gettransaction", Duration: 300 * time.Millisecond, Err: errors.New("failed")},
+		},
+	})
+
+	record := decode(t, slow)
+	if record["method"] != "eth_getLogs" || record["revod_calls"] != float64(4) || record["revod_duration"] != "1.81s" {
+		t.Errorf("Unexpected record %v", record)
+	}
+	if record["revod"] != "searchlogs 1.5s 1 retries, getblock cached, getblockhash 10ms shared, gettransaction 300ms failed" {
+		t.Errorf("Unexpected breakdown %v", record["revod"])
+	}
+
+	slow.Reset()
+	l.Log(Entry{Method: "eth_blockNumber", Duration: time.Millisecond})
+	if slow.Len() != 0 {
+		t.Errorf("A fast call was logged as slow %s", slow.String())
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	var l *Logger
+	l.Log(Entry{Method: "eth_blockNumber"})
+}
@@ -0,0 +1,282 @@
+package apikeys
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// KeyConfig is an API key and the limits it gets
+type KeyConfig struct {
+	Key  string `yaml:"key" json:"key"`
+	Name string `yaml:"name" json:"name"`
+	// requests per second, 0 is unlimited
+	RPS float64 `yaml:"rps" json:"rps"`
+	// requests allowed at once above RPS, defaults to RPS rounded up
+	Burst int `yaml:"burst" json:"burst"`
+	// requests per UTC day, 0 is unlimited
+	Daily int64 `yaml:"daily" json:"daily"`
+	// methods the key may call, exact names or namespaces like "eth_*", empty allows every method
+	Methods []string `yaml:"methods" json:"methods"`
+}
+
+// Config is the API keys file, YAML or JSON
+type Config struct {
+	Keys []KeyConfig `yaml:"keys" json:"keys"`
+}
+
+// Registry holds the accepted API keys, it can be updated at runtime without losing the usage of retained keys
+type Registry struct {
+	path  string
+	mutex sync.RWMutex
+	keys  map[string]*Key
+}
+
+// NewRegistry returns a registry holding 'configs'
+func NewRegistry(configs []KeyConfig) (*Registry, error) {
+	r := &Registry{}
+	if err := r.Update(configs); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Load returns a registry holding the keys of the file at 'path', Reload reads the file again
+func Load(path string) (*Registry, error) {
+	configs, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRegistry(configs)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	r.path = path
+	return r, nil
+}
+
+func readConfig(path string) ([]KeyConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read API keys")
+	}
+	// YAML is a superset of JSON, this reads both
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrapf(err, "invalid API keys file %s", path)
+	}
+	return config.Keys, nil
+}
+
+// Reload reads the file the registry was loaded from again, on error the current keys are kept
+func (r *Registry) Reload() error {
+	if r.path == "" {
+		return errors.New("API keys weren't loaded from a file")
+	}
+	configs, err := readConfig(r.path)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(r.Update(configs), r.path)
+}
+
+// Update replaces the accepted keys with 'configs'. Keys present before keep their usage counters
+func (r *Registry) Update(configs []KeyConfig) error {
+	keys := make(map[string]*Key, len(configs))
+	for i, config := range configs {
+		config.Key = strings.TrimSpace(config.Key)
+		if config.Key == "" {
+			return errors.Errorf("API key #%d is empty", i+1)
+		}
+		if _, ok := keys[config.Key]; ok {
+			return errors.Errorf("API key %s is listed twice", config.Name)
+		}
+		if config.RPS < 0 || config.Daily < 0 || config.Burst < 0 {
+			return errors.Errorf("API key %s has a negative limit", config.Name)
+		}
+		keys[config.Key] = newKey(config)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for value, key := range keys {
+		if old, ok := r.keys[value]; ok {
+			key.inherit(old)
+		}
+	}
+	r.keys = keys
+	return nil
+}
+
+// Lookup returns the key 'value', nil if it isn't accepted
+func (r *Registry) Lookup(value string) *Key {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.keys[value]
+}
+
+// Len returns the number of accepted keys
+func (r *Registry) Len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.keys)
+}
+
+// Key is an accepted API key, with its limits and usage
+type Key struct {
+	config  KeyConfig
+	bucket  *ratelimit.TokenBucket
+	methods map[string]bool
+	// namespaces allowed with "ns_*"
+	namespaces map[string]bool
+
+	mutex sync.Mutex
+	// the UTC day 'today' counts the requests of
+	day   time.Time
+	today int64
+
+	total    uint64
+	rejected uint64
+}
+
+// Usage is the usage of a Key
+type Usage struct {
+	Name       string `json:"name"`
+	Total      uint64 `json:"total"`
+	Rejected   uint64 `json:"rejected"`
+	Today      int64  `json:"today"`
+	DailyQuota int64  `json:"dailyQuota,omitempty"`
+}
+
+func newKey(config KeyConfig) *Key {
+	k := &Key{config: config}
+
+	if config.RPS > 0 {
+		burst := config.Burst
+		if burst == 0 {
+			burst = int(config.RPS + 0.999)
+		}
+		k.bucket = ratelimit.NewTokenBucket(config.RPS, burst)
+	}
+
+	for _, method := range config.Methods {
+		method = strings.TrimSpace(method)
+		if method == "" {
+			continue
+		}
+		if strings.HasSuffix(method, "_*") {
+			if k.namespaces == nil {
+				k.namespaces = make(map[string]bool)
+			}
+			k.namespaces[strings.TrimSuffix(method, "_*")] = true
+			continue
+		}
+		if k.methods == nil {
+			k.methods = make(map[string]bool)
+		}
+		k.methods[method] = true
+	}
+
+	return k
+}
+
+// inherit takes over the usage of 'old', and its rate limit state if the rate didn't change
+func (k *Key) inherit(old *Key) {
+	old.mutex.Lock()
+	k.day, k.today = old.day, old.today
+	old.mutex.Unlock()
+
+	k.total = atomic.LoadUint64(&old.total)
+	k.rejected = atomic.LoadUint64(&old.rejected)
+
+	if old.bucket != nil && old.config.RPS == k.config.RPS && old.config.Burst == k.config.Burst {
+		k.bucket = old.bucket
+	}
+}
+
+// Name returns the key's name, to log instead of the key itself
+func (k *Key) Name() string {
+	return k.config.Name
+}
+
+// AllowsMethod reports whether the key may call 'method'
+func (k *Key) AllowsMethod(method string) bool {
+	if k.methods == nil && k.namespaces == nil {
+		return true
+	}
+	if k.methods[method] {
+		return true
+	}
+	if i := strings.Index(method, "_"); i != -1 {
+		return k.namespaces[method[:i]]
+	}
+	return false
+}
+
+// AuthorizeCall counts a call to 'method', returning an error if the key may not call it or went over a quota
+func (k *Key) AuthorizeCall(method string) eth.JSONRPCError {
+	return k.authorizeCallAt(method, time.Now())
+}
+
+func (k *Key) authorizeCallAt(method string, now time.Time) eth.JSONRPCError {
+	atomic.AddUint64(&k.total, 1)
+
+	if !k.AllowsMethod(method) {
+		atomic.AddUint64(&k.rejected, 1)
+		return eth.NewMethodNotFoundError(method)
+	}
+
+	if k.bucket != nil && !k.bucket.AllowAt(now) {
+		atomic.AddUint64(&k.rejected, 1)
+		return eth.NewLimitExceededError("request rate limit exceeded")
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if day := utcDay(now); !day.Equal(k.day) {
+		k.day = day
+		k.today = 0
+	}
+	if k.config.Daily > 0 && k.today >= k.config.Daily {
+		atomic.AddUint64(&k.rejected, 1)
+		return eth.NewLimitExceededError("daily request quota exceeded")
+	}
+	k.today++
+
+	return nil
+}
+
+// Usage returns the key's counters
+func (k *Key) Usage() Usage {
+	return k.usageAt(time.Now())
+}
+
+func (k *Key) usageAt(now time.Time) Usage {
+	k.mutex.Lock()
+	today := k.today
+	if !utcDay(now).Equal(k.day) {
+		today = 0
+	}
+	k.mutex.Unlock()
+
+	return Usage{
+		Name:       k.config.Name,
+		Total:      atomic.LoadUint64(&k.total),
+		Rejected:   atomic.LoadUint64(&k.rejected),
+		Today:      today,
+		DailyQuota: k.config.Daily,
+	}
+}
+
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
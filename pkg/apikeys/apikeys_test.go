@@ -0,0 +1,104 @@
+package apikeys
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/revolutionchain/charon/pkg/eth"
+)
+
+func TestKeyLimits(t *testing.T) {
+	registry, err := NewRegistry([]KeyConfig{
+		{Key: "secret", Name: "dapp", RPS: 1, Burst: 2, Daily: 3, Methods: []string{"eth_*", "net_version"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Lookup("unknown") != nil {
+		t.Fatal("expected an unknown key to be refused")
+	}
+	key := registry.Lookup("secret")
+	if key == nil {
+		t.Fatal("expected the key to be accepted")
+	}
+
+	now := time.Date(2022, 6, 1, 23, 59, 0, 0, time.UTC)
+
+	if err := key.authorizeCallAt("personal_sign", now); err == nil || err.Code() != eth.MethodNotFoundErrorCode {
+		t.Fatalf("expected personal_sign to be refused, got %v", err)
+	}
+	for _, method := range []string{"eth_call", "net_version"} {
+		if err := key.authorizeCallAt(method, now); err != nil {
+			t.Fatalf("expected %s to be allowed, got %v", method, err.Message())
+		}
+	}
+	if err := key.authorizeCallAt("eth_call", now); err == nil || err.Code() != eth.LimitExceededErrorCode {
+		t.Fatalf("expected the burst to be exhausted, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	if err := key.authorizeCallAt("eth_call", now); err != nil {
+		t.Fatalf("expected a call after a second, got %v", err.Message())
+	}
+	now = now.Add(time.Second)
+	if err := key.authorizeCallAt("eth_call", now); err == nil || err.Message() != "daily request quota exceeded" {
+		t.Fatalf("expected the daily quota to be exhausted, got %v", err)
+	}
+
+	usage := key.usageAt(now)
+	if usage.Total != 6 || usage.Rejected != 3 || usage.Today != 3 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	// next UTC day
+	now = now.Add(time.Minute)
+	if err := key.authorizeCallAt("eth_call", now); err != nil {
+		t.Fatalf("expected the daily quota to reset, got %v", err.Message())
+	}
+}
+
+func TestReloadKeepsUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`
+keys:
+  - key: a
+    name: first
+  - key: b
+    name: second
+`)
+	registry, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Lookup("a").AuthorizeCall("eth_chainId"); err != nil {
+		t.Fatal(err.Message())
+	}
+
+	// JSON is accepted too
+	write(`{"keys": [{"key": "a", "name": "first", "daily": 10}, {"key": "c", "name": "third"}]}`)
+	if err := registry.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if registry.Len() != 2 || registry.Lookup("b") != nil {
+		t.Fatal("expected key b to be removed")
+	}
+	if usage := registry.Lookup("a").Usage(); usage.Total != 1 || usage.DailyQuota != 10 {
+		t.Fatalf("expected key a to keep its usage, got %+v", usage)
+	}
+
+	write("keys:\n  - key: a\n  - key: a\n")
+	if err := registry.Reload(); err == nil {
+		t.Fatal("expected duplicate keys to be refused")
+	}
+	if registry.Lookup("c") == nil {
+		t.Fatal("expected a failed reload to keep the current keys")
+	}
+}
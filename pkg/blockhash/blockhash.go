@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/revolutionchain/charon/pkg/metrics"
 	"github.com/revolutionchain/ethereum-block-processor/cache"
 	"github.com/revolutionchain/ethereum-block-processor/db"
 	"github.com/revolutionchain/ethereum-block-processor/dispatcher"
@@ -164,15 +165,22 @@ func (bh *BlockHash) Start(databaseConfig *DatabaseConfig, chainIdChan <-chan in
 				if err != nil {
 					return nil, err
 				}
+				metrics.BlockHashLatestBlock.Set(float64(latestBlock))
 
-				return qdb.GetMissingBlocks(ctx, chainId, latestBlock)
+				missingBlocks, err := qdb.GetMissingBlocks(ctx, chainId, latestBlock)
+				if err == nil {
+					metrics.BlockHashMissingBlocks.Set(float64(len(missingBlocks)))
+				}
+				return missingBlocks, err
 			},
 		)
 
 		go func() {
 			for {
 				select {
-				case <-completedBlockChan:
+				case block := <-completedBlockChan:
+					metrics.BlockHashIndexedBlocks.Inc()
+					metrics.BlockHashLastIndexedBlock.Set(float64(block))
 				case <-bh.ctx.Done():
 					return
 				}
@@ -0,0 +1,238 @@
+// Package config reads charon's configuration file. The file is YAML or TOML, its settings are named like the
+// command line flags, nested tables being joined with '.', and its "keys" list holds API keys like the --api-keys file
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/apikeys"
+	"gopkg.in/yaml.v3"
+)
+
+// KeysSetting is the list of API keys in a configuration file
+const KeysSetting = "keys"
+
+// File is a configuration file's content
+type File struct {
+	// setting values as the flags would get them, by flag name
+	Values map[string]string
+	// API keys, nil if the file doesn't list any
+	Keys []apikeys.KeyConfig
+}
+
+// Load reads the configuration file at 'path', TOML for a .toml file and YAML, or JSON, otherwise
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read configuration")
+	}
+
+	raw := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration file %s", path)
+	}
+
+	file := &File{Values: make(map[string]string)}
+	if keys, ok := raw[KeysSetting]; ok {
+		delete(raw, KeysSetting)
+		if file.Keys, err = parseKeys(keys); err != nil {
+			return nil, errors.Wrapf(err, "invalid API keys in %s", path)
+		}
+	}
+	if err := flatten("", raw, file.Values); err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration file %s", path)
+	}
+
+	return file, nil
+}
+
+// parseKeys reads the API keys list the same way for YAML and TOML
+func parseKeys(keys interface{}) ([]apikeys.KeyConfig, error) {
+	data, err := yaml.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	configs := []apikeys.KeyConfig{}
+	if err := yaml.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) error {
+	for key, value := range tree {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+
+		if table, ok := value.(map[string]interface{}); ok {
+			if err := flatten(name, table, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		formatted, err := format(value)
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+		if _, ok := values[name]; ok {
+			return errors.Errorf("%s is set twice", name)
+		}
+		values[name] = formatted
+	}
+	return nil
+}
+
+// format returns 'value' the way it would be written on the command line, lists being comma separated
+func format(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			formatted, err := format(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatted)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", errors.Errorf("unsupported value %v", value)
+	}
+}
+
+// Change is a setting whose value changed, Old or New is empty when it was added or removed
+type Change struct {
+	Setting string
+	Old     string
+	New     string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: '%s' -> '%s'", c.Setting, c.Old, c.New)
+}
+
+// Diff returns the settings which differ between 'old' and 'new', sorted by name. The values of the 'secret'
+// settings aren't included
+func Diff(old *File, new *File, secret map[string]bool) []Change {
+	var changes []Change
+	for setting, oldValue := range old.Values {
+		if newValue := new.Values[setting]; newValue != oldValue {
+			changes = append(changes, Change{Setting: setting, Old: oldValue, New: newValue})
+		}
+	}
+	for setting, newValue := range new.Values {
+		if _, ok := old.Values[setting]; !ok {
+			changes = append(changes, Change{Setting: setting, New: newValue})
+		}
+	}
+	for i := range changes {
+		if secret[changes[i].Setting] {
+			changes[i].Old, changes[i].New = hide(changes[i].Old), hide(changes[i].New)
+		}
+	}
+	// the keys themselves are secrets
+	if keysChanged(old.Keys, new.Keys) {
+		changes = append(changes, Change{
+			Setting: KeysSetting,
+			Old:     fmt.Sprintf("%d API keys", len(old.Keys)),
+			New:     fmt.Sprintf("%d API keys", len(new.Keys)),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Setting < changes[j].Setting })
+	return changes
+}
+
+// Keep returns a copy of 'new' with the value 'settings' have in 'old', so that a later Diff still shows them as
+// changed. A setting 'old' doesn't have is left out
+func Keep(old *File, new *File, settings ...string) *File {
+	kept := &File{Values: make(map[string]string, len(new.Values)), Keys: new.Keys}
+	for setting, value := range new.Values {
+		kept.Values[setting] = value
+	}
+	for _, setting := range settings {
+		if setting == KeysSetting {
+			kept.Keys = old.Keys
+			continue
+		}
+		if value, ok := old.Values[setting]; ok {
+			kept.Values[setting] = value
+		} else {
+			delete(kept.Values, setting)
+		}
+	}
+	return kept
+}
+
+func hide(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "***"
+}
+
+func keysChanged(old []apikeys.KeyConfig, new []apikeys.KeyConfig) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	for i := range old {
+		if fmt.Sprint(old[i]) != fmt.Sprint(new[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch calls 'changed' every time the file at 'path' is modified, checking every 'interval' until 'ctx' is done
+func Watch(ctx context.Context, path string, interval time.Duration, changed func()) {
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				// being replaced, or removed
+				continue
+			}
+			if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				last = info
+				changed()
+			}
+		}
+	}
+}
@@ -47,7 +47,7 @@ func ConvertLogTopicsToStringArray(topics []interface{}) []string {
 func SearchLogsAndFilterExtraTopics(ctx context.Context, q *revo.Revo, req *revo.SearchLogsRequest) (revo.SearchLogsResponse, eth.JSONRPCError) {
 	receipts, err := q.SearchLogs(ctx, req)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	hasTopics := len(req.Topics) != 0
@@ -2,7 +2,10 @@ package eth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // unknown service
@@ -14,9 +17,27 @@ var InvalidRequestErrorCode = -32600
 var InvalidMessageErrorCode = -32700
 var InvalidParamsErrorCode = -32602
 
+// charon failed to handle the request
+var InternalErrorCode = -32603
+
 // logic error
 var CallbackErrorCode = -32000
 
+// geth: a call or gas estimate reverted, the revert data is the error's data
+var ExecutionRevertedErrorCode = 3
+
+// eth_sendRawTransactionSync: the transaction was broadcast, but no receipt was available before the timeout
+var TransactionTimeoutErrorCode = 4
+
+// API keys: the request has no API key or an unknown one
+var UnauthorizedErrorCode = -32001
+
+// the caller went over a request quota, EIP-1474's "limit exceeded"
+var LimitExceededErrorCode = -32005
+
+// the revod upstreams are struggling and calls to them fail at once, EIP-1474's "resource unavailable"
+var ResourceUnavailableErrorCode = -32002
+
 // shutdown error
 // "server is shutting down"
 var ShutdownErrorCode = -32000
@@ -42,10 +63,57 @@ func NewInvalidParamsError(message string) JSONRPCError {
 	return NewJSONRPCError(InvalidParamsErrorCode, message, nil)
 }
 
+func NewInternalError(message string) JSONRPCError {
+	return NewJSONRPCError(InternalErrorCode, message, nil)
+}
+
 func NewCallbackError(message string) JSONRPCError {
 	return NewJSONRPCError(CallbackErrorCode, message, nil)
 }
 
+// NewCallbackErrorFrom is a callback error with the message of 'err', which is kept as its cause
+func NewCallbackErrorFrom(err error) JSONRPCError {
+	return NewJSONRPCError(CallbackErrorCode, err.Error(), err)
+}
+
+// WrapCallbackError is a callback error with 'message', 'err' is kept as its cause
+func WrapCallbackError(err error, message string) JSONRPCError {
+	return NewJSONRPCError(CallbackErrorCode, message, err)
+}
+
+// NewExecutionRevertedError is geth's error for a reverted call, 'reason' is the decoded revert reason and 'data' the
+// hex revert data, either may be empty
+func NewExecutionRevertedError(reason string, data string) JSONRPCError {
+	message := "execution reverted"
+	if reason != "" {
+		message += ": " + reason
+	}
+	if data == "" || data == "0x" {
+		return NewJSONRPCError(ExecutionRevertedErrorCode, message, nil)
+	}
+	return NewJSONRPCErrorWithData(ExecutionRevertedErrorCode, message, data)
+}
+
+func NewTransactionTimeoutError(hash string, timeout time.Duration) JSONRPCError {
+	return NewJSONRPCError(
+		TransactionTimeoutErrorCode,
+		fmt.Sprintf("The transaction %s was added to the mempool but wasn't processed in %s", hash, timeout),
+		nil,
+	)
+}
+
+func NewUpstreamUnavailableError() JSONRPCError {
+	return NewJSONRPCError(ResourceUnavailableErrorCode, "upstream unavailable", nil)
+}
+
+func NewUnauthorizedError(message string) JSONRPCError {
+	return NewJSONRPCError(UnauthorizedErrorCode, message, nil)
+}
+
+func NewLimitExceededError(message string) JSONRPCError {
+	return NewJSONRPCError(LimitExceededErrorCode, message, nil)
+}
+
 type JSONRPCError interface {
 	Code() int
 	Message() string
@@ -60,10 +128,20 @@ func NewJSONRPCError(code int, message string, err error) JSONRPCError {
 	}
 }
 
+// NewJSONRPCErrorWithData returns an error sent with 'data' as its data member
+func NewJSONRPCErrorWithData(code int, message string, data interface{}) JSONRPCError {
+	return &GenericJSONRPCError{
+		code:    code,
+		message: message,
+		data:    data,
+	}
+}
+
 // JSONRPCError contains the message and code for an ETH RPC error
 type GenericJSONRPCError struct {
 	code    int
 	message string
+	data    interface{}
 	err     error
 }
 
@@ -75,16 +153,111 @@ func (err *GenericJSONRPCError) Message() string {
 	return err.message
 }
 
+func (err *GenericJSONRPCError) Data() interface{} {
+	return err.data
+}
+
 func (err *GenericJSONRPCError) Error() error {
 	return err.err
 }
 
 func (err *GenericJSONRPCError) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
+		Code    int         `json:"code"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data,omitempty"`
 	}{
 		Code:    err.code,
 		Message: err.message,
+		Data:    err.data,
 	})
 }
+
+// revodError is an error returned by revod which still has the code and message revod sent, see revo.JSONRPCError
+// and revo.KnownError
+type revodError interface {
+	RPCError() (code int, message string)
+}
+
+// revodErrorTranslation maps revod errors, matched by code or by a fragment of their message, to the message geth
+// returns in the same situation
+type revodErrorTranslation struct {
+	codes     []int
+	fragments []string
+	message   string
+}
+
+func (t *revodErrorTranslation) matches(code int, message string) bool {
+	for _, c := range t.codes {
+		if c == code {
+			return true
+		}
+	}
+	message = strings.ToLower(message)
+	for _, fragment := range t.fragments {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// in order, the first match wins. Messages are the ones client libraries (ethers, web3, viem) look for
+var revodErrorTranslations = []revodErrorTranslation{
+	{
+		// RPC_VERIFY_ALREADY_IN_CHAIN
+		codes:     []int{-27},
+		fragments: []string{"txn-already-in-mempool", "txn-already-known", "transaction already in block chain"},
+		message:   "already known",
+	},
+	{
+		// the inputs were spent by another transaction, the closest thing to a reused nonce
+		fragments: []string{"txn-mempool-conflict", "bad-txns-inputs-missingorspent", "missing inputs", "missingorspent"},
+		message:   "nonce too low",
+	},
+	{
+		// RPC_WALLET_INSUFFICIENT_FUNDS
+		codes:     []int{-6},
+		fragments: []string{"insufficient funds", "insufficient utxo value", "bad-txns-in-belowout"},
+		message:   "insufficient funds for gas * price + value",
+	},
+	{
+		fragments: []string{"min relay fee not met", "mempool min fee not met", "insufficient fee", "bad-txns-fee-notenough", "bad-txns-small-gasprice"},
+		message:   "transaction underpriced",
+	},
+	{
+		fragments: []string{"bad-txns-gas-exceeds-blockgaslimit"},
+		message:   "exceeds block gas limit",
+	},
+	{
+		fragments: []string{"mandatory-script-verify-flag-failed", "bad-txns-invalid-sender"},
+		message:   "invalid sender",
+	},
+	{
+		fragments: []string{"tx-size", "bad-txns-oversize"},
+		message:   "oversized data",
+	},
+}
+
+// NewRevodError translates the error of a call to revod into the error geth returns in the same situation, the
+// message revod returned is kept as the error's data. Other errors are callback errors with their own message,
+// 'err' is kept as the cause of both
+func NewRevodError(err error) JSONRPCError {
+	code, message := 0, err.Error()
+	var rpcErr revodError
+	if errors.As(err, &rpcErr) {
+		code, message = rpcErr.RPCError()
+	}
+
+	for i := range revodErrorTranslations {
+		if revodErrorTranslations[i].matches(code, message) {
+			return &GenericJSONRPCError{
+				code:    CallbackErrorCode,
+				message: revodErrorTranslations[i].message,
+				data:    message,
+				err:     err,
+			}
+		}
+	}
+	return NewCallbackErrorFrom(err)
+}
@@ -0,0 +1,67 @@
+package eth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type testRevodError struct {
+	code    int
+	message string
+}
+
+func (err *testRevodError) Error() string {
+	return fmt.Sprintf("revo [code: %d] %s", err.code, err.message)
+}
+
+func (err *testRevodError) RPCError() (int, string) {
+	return err.code, err.message
+}
+
+func TestNewRevodError(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want string
+	}{
+		{err: &testRevodError{-26, "txn-already-in-mempool"}, want: `{"code":-32000,"message":"already known","data":"txn-already-in-mempool"}`},
+		{err: &testRevodError{-26, "min relay fee not met"}, want: `{"code":-32000,"message":"transaction underpriced","data":"min relay fee not met"}`},
+		{err: &testRevodError{-25, "bad-txns-inputs-missingorspent"}, want: `{"code":-32000,"message":"nonce too low","data":"bad-txns-inputs-missingorspent"}`},
+		{err: &testRevodError{-6, "Insufficient funds"}, want: `{"code":-32000,"message":"insufficient funds for gas * price + value","data":"Insufficient funds"}`},
+		// wrapped, the way the client returns known errors
+		{err: fmt.Errorf("wallet insufficient funds: %w", &testRevodError{-6, "Insufficient funds"}), want: `{"code":-32000,"message":"insufficient funds for gas * price + value","data":"Insufficient funds"}`},
+		{err: &testRevodError{-26, "bad-txns-gas-exceeds-blockgaslimit"}, want: `{"code":-32000,"message":"exceeds block gas limit","data":"bad-txns-gas-exceeds-blockgaslimit"}`},
+		{err: errors.New("Insufficient UTXO value attempted to be sent"), want: `{"code":-32000,"message":"insufficient funds for gas * price + value","data":"Insufficient UTXO value attempted to be sent"}`},
+		// unknown errors are unchanged
+		{err: &testRevodError{-8, "Invalid parameter"}, want: `{"code":-32000,"message":"revo [code: -8] Invalid parameter"}`},
+	} {
+		got, err := json.Marshal(NewRevodError(test.err))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("NewRevodError(%q) = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestNewExecutionRevertedError(t *testing.T) {
+	for _, test := range []struct {
+		reason string
+		data   string
+		want   string
+	}{
+		{want: `{"code":3,"message":"execution reverted"}`},
+		{data: "0x", want: `{"code":3,"message":"execution reverted"}`},
+		{reason: "not owner", data: "0x08c379a0", want: `{"code":3,"message":"execution reverted: not owner","data":"0x08c379a0"}`},
+	} {
+		got, err := json.Marshal(NewExecutionRevertedError(test.reason, test.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("NewExecutionRevertedError(%q, %q) = %s, want %s", test.reason, test.data, got, test.want)
+		}
+	}
+}
@@ -22,7 +22,8 @@ type JSONRPCResult struct {
 	JSONRPC   string          `json:"jsonrpc"`
 	RawResult json.RawMessage `json:"result,omitempty"`
 	Error     JSONRPCError    `json:"error,omitempty"`
-	ID        json.RawMessage `json:"id,omitempty"`
+	// always present, null when the request's id couldn't be read
+	ID json.RawMessage `json:"id"`
 }
 
 func NewJSONRPCResult(id json.RawMessage, res interface{}) (*JSONRPCResult, error) {
@@ -4,6 +4,8 @@ import (
 	"math/big"
 	"sync"
 	"sync/atomic"
+
+	"github.com/revolutionchain/charon/pkg/metrics"
 )
 
 type FilterType int
@@ -14,6 +16,19 @@ const (
 	NewPendingTransactionFilterTy
 )
 
+func (ty FilterType) String() string {
+	switch ty {
+	case NewFilterTy:
+		return "logs"
+	case NewBlockFilterTy:
+		return "blocks"
+	case NewPendingTransactionFilterTy:
+		return "pendingTransactions"
+	default:
+		return "unknown"
+	}
+}
+
 type Filter struct {
 	ID           uint64
 	Type         FilterType
@@ -42,12 +57,15 @@ func (f *FilterSimulator) New(ty FilterType, req ...interface{}) *Filter {
 	}
 
 	f.filters.Store(id, filter)
+	metrics.Filters.WithLabelValues(ty.String()).Inc()
 
 	return filter
 }
 
 func (f *FilterSimulator) Uninstall(filterID uint64) {
-	f.filters.Delete(filterID)
+	if filter, ok := f.filters.LoadAndDelete(filterID); ok {
+		metrics.Filters.WithLabelValues(filter.(*Filter).Type.String()).Dec()
+	}
 }
 
 func (f *FilterSimulator) Filter(filterID uint64) (value interface{}, ok bool) {
@@ -0,0 +1,86 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// IsNotification reports whether the request has no id member, a notification gets no response
+func (r *JSONRPCRequest) IsNotification() bool {
+	return r.ID == nil
+}
+
+// ParseJSONRPCRequest parses a JSON-RPC 2.0 request object. An invalid request is returned along with an invalid
+// request error, with its id if it had a valid one, so the error can be answered with it
+func ParseJSONRPCRequest(data json.RawMessage) (*JSONRPCRequest, JSONRPCError) {
+	var envelope struct {
+		JSONRPC json.RawMessage `json:"jsonrpc"`
+		Method  json.RawMessage `json:"method"`
+		ID      json.RawMessage `json:"id"`
+		Params  json.RawMessage `json:"params"`
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return &JSONRPCRequest{}, NewInvalidRequestError("invalid request, expected an object")
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return &JSONRPCRequest{}, NewInvalidRequestError("invalid request")
+	}
+
+	req := &JSONRPCRequest{Params: envelope.Params}
+	// the id is a string, a number or null
+	if envelope.ID != nil {
+		switch envelope.ID[0] {
+		case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			req.ID = envelope.ID
+		default:
+			return req, NewInvalidRequestError("invalid request id, expected a string, a number or null")
+		}
+	}
+
+	if err := json.Unmarshal(envelope.JSONRPC, &req.JSONRPC); err != nil || req.JSONRPC != RPCVersion {
+		return req, NewInvalidRequestError(`invalid request, "jsonrpc" must be "2.0"`)
+	}
+	if err := json.Unmarshal(envelope.Method, &req.Method); err != nil || req.Method == "" {
+		return req, NewInvalidRequestError("invalid request, the method must be a non empty string")
+	}
+	if len(envelope.Params) > 0 {
+		switch envelope.Params[0] {
+		case '[', '{', 'n':
+		default:
+			return req, NewInvalidRequestError("invalid request, params must be an array or an object")
+		}
+	}
+
+	return req, nil
+}
+
+// ParseJSONRPCMessage splits a JSON-RPC 2.0 message, a request or a batch of requests, into its requests.
+// errs[i] is the error to answer reqs[i] with when it isn't valid. The message wide error is either a parse error
+// for invalid JSON or an invalid request error for an empty batch
+func ParseJSONRPCMessage(data []byte) (reqs []*JSONRPCRequest, errs []JSONRPCError, batch bool, err JSONRPCError) {
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return nil, nil, false, NewInvalidMessageError("parse error")
+	}
+
+	if data[0] != '[' {
+		req, reqErr := ParseJSONRPCRequest(data)
+		return []*JSONRPCRequest{req}, []JSONRPCError{reqErr}, false, nil
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, nil, true, NewInvalidMessageError("parse error")
+	}
+	if len(elements) == 0 {
+		return nil, nil, true, NewInvalidRequestError("empty batch")
+	}
+
+	reqs = make([]*JSONRPCRequest, len(elements))
+	errs = make([]JSONRPCError, len(elements))
+	for i, element := range elements {
+		reqs[i], errs[i] = ParseJSONRPCRequest(element)
+	}
+	return reqs, errs, true, nil
+}
@@ -0,0 +1,73 @@
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseJSONRPCMessage(t *testing.T) {
+	for _, test := range []struct {
+		message string
+		batch   bool
+		err     int
+		// error code per request, 0 for valid requests
+		reqErrs []int
+		ids     []string
+	}{
+		{message: `{"jsonrpc":"2.0","method":"eth_chainId","id":"abc"}`, reqErrs: []int{0}, ids: []string{`"abc"`}},
+		{message: `{"jsonrpc":"2.0","method":"eth_chainId","id":null}`, reqErrs: []int{0}, ids: []string{`null`}},
+		{message: `{"jsonrpc":"2.0","method":"eth_chainId"}`, reqErrs: []int{0}, ids: []string{``}},
+		{message: `{"jsonrpc":"2.0","method":"eth_getBalance","params":"0x1","id":7}`, reqErrs: []int{InvalidRequestErrorCode}, ids: []string{`7`}},
+		{message: `{"method":"eth_chainId","id":1}`, reqErrs: []int{InvalidRequestErrorCode}, ids: []string{`1`}},
+		{message: `{"jsonrpc":"2.0","method":1,"id":{}}`, reqErrs: []int{InvalidRequestErrorCode}, ids: []string{``}},
+		{message: `{"jsonrpc":"2.0","method":"eth_chainId"`, err: InvalidMessageErrorCode},
+		{message: `[]`, batch: true, err: InvalidRequestErrorCode},
+		{
+			message: `[1, {"jsonrpc":"2.0","method":"eth_chainId","id":1.5}, {"jsonrpc":"2.0","method":"net_version"}]`,
+			batch:   true,
+			reqErrs: []int{InvalidRequestErrorCode, 0, 0},
+			ids:     []string{``, `1.5`, ``},
+		},
+	} {
+		reqs, errs, batch, err := ParseJSONRPCMessage([]byte(test.message))
+		if batch != test.batch {
+			t.Errorf("%s: expected batch to be %v", test.message, test.batch)
+		}
+		if test.err != 0 {
+			if err == nil || err.Code() != test.err {
+				t.Errorf("%s: expected error %d, got %v", test.message, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.message, err.Message())
+			continue
+		}
+		if len(reqs) != len(test.reqErrs) {
+			t.Errorf("%s: expected %d requests, got %d", test.message, len(test.reqErrs), len(reqs))
+			continue
+		}
+		for i := range reqs {
+			code := 0
+			if errs[i] != nil {
+				code = errs[i].Code()
+			}
+			if code != test.reqErrs[i] {
+				t.Errorf("%s: request %d: expected error %d, got %d", test.message, i, test.reqErrs[i], code)
+			}
+			if string(reqs[i].ID) != test.ids[i] {
+				t.Errorf("%s: request %d: expected id %q, got %q", test.message, i, test.ids[i], reqs[i].ID)
+			}
+		}
+	}
+}
+
+func TestJSONRPCResultAlwaysHasAnID(t *testing.T) {
+	data, err := json.Marshal(&JSONRPCResult{JSONRPC: RPCVersion, Error: NewInvalidMessageError("parse error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+}
@@ -96,6 +96,65 @@ type (
 	SendRawTransactionResponse string
 )
 
+// ========== eth_sendRawTransactionSync / eth_sendTransactionSync ============= //
+
+// SendRawTransactionSyncRequest is [rawTx, timeout], timeout is optional and in milliseconds
+type SendRawTransactionSyncRequest struct {
+	RawTx   string
+	Timeout *ETHInt
+}
+
+func (r *SendRawTransactionSyncRequest) UnmarshalJSON(data []byte) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	if paramsNum := len(params); paramsNum < 1 || paramsNum > 2 {
+		return fmt.Errorf("invalid parameters number - %d/2", paramsNum)
+	}
+
+	if err := json.Unmarshal(params[0], &r.RawTx); err != nil {
+		return errors.Wrap(err, "invalid raw transaction")
+	}
+	if len(params) == 2 && string(params[1]) != "null" {
+		r.Timeout = new(ETHInt)
+		if err := json.Unmarshal(params[1], r.Timeout); err != nil {
+			return errors.Wrap(err, "invalid timeout")
+		}
+	}
+
+	return nil
+}
+
+// SendTransactionSyncRequest is [transaction, timeout], timeout is optional and in milliseconds
+type SendTransactionSyncRequest struct {
+	Transaction SendTransactionRequest
+	Timeout     *ETHInt
+}
+
+func (r *SendTransactionSyncRequest) UnmarshalJSON(data []byte) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	if paramsNum := len(params); paramsNum < 1 || paramsNum > 2 {
+		return fmt.Errorf("invalid parameters number - %d/2", paramsNum)
+	}
+
+	// SendTransactionRequest expects the whole params array
+	if err := json.Unmarshal([]byte("["+string(params[0])+"]"), &r.Transaction); err != nil {
+		return err
+	}
+	if len(params) == 2 && string(params[1]) != "null" {
+		r.Timeout = new(ETHInt)
+		if err := json.Unmarshal(params[1], r.Timeout); err != nil {
+			return errors.Wrap(err, "invalid timeout")
+		}
+	}
+
+	return nil
+}
+
 // CallResponse
 type CallResponse string
 
@@ -145,6 +204,40 @@ func (t *CallRequest) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ========== personal_unlockAccount ============= //
+
+// PersonalUnlockAccountRequest is [address, passphrase, duration], duration is optional and in seconds
+type PersonalUnlockAccountRequest struct {
+	Address    string
+	Passphrase string
+	Duration   *ETHInt
+}
+
+func (r *PersonalUnlockAccountRequest) UnmarshalJSON(data []byte) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	if paramsNum := len(params); paramsNum < 2 || paramsNum > 3 {
+		return fmt.Errorf("invalid parameters number - %d/3", paramsNum)
+	}
+
+	if err := json.Unmarshal(params[0], &r.Address); err != nil {
+		return errors.Wrap(err, "invalid address")
+	}
+	if err := json.Unmarshal(params[1], &r.Passphrase); err != nil {
+		return errors.Wrap(err, "invalid passphrase")
+	}
+	if len(params) == 3 && string(params[2]) != "null" {
+		r.Duration = new(ETHInt)
+		if err := json.Unmarshal(params[2], r.Duration); err != nil {
+			return errors.Wrap(err, "invalid duration")
+		}
+	}
+
+	return nil
+}
+
 type (
 	PersonalUnlockAccountResponse bool
 	BlockNumberResponse           string
@@ -182,17 +275,10 @@ func (t *SignRequest) UnmarshalJSON(data []byte) (err error) {
 	}
 
 	if data, ok := params[1].(string); ok {
-		var msg []byte
-		if !strings.HasPrefix(data, "0x") {
-			msg = []byte(data)
-		} else {
-			msg, err = hex.DecodeString(utils.RemoveHexPrefix(data))
-			if err != nil {
-				return errors.Wrap(err, "invalid data format")
-			}
+		t.Message, err = decodeSignMessage(data)
+		if err != nil {
+			return err
 		}
-
-		t.Message = msg
 	} else {
 		return errors.New("data should be a hex string")
 	}
@@ -200,6 +286,172 @@ func (t *SignRequest) UnmarshalJSON(data []byte) (err error) {
 	return nil
 }
 
+// decodeSignMessage decodes a message to sign, hex encoded if it starts with 0x and as is otherwise
+func decodeSignMessage(data string) ([]byte, error) {
+	if !strings.HasPrefix(data, "0x") {
+		return []byte(data), nil
+	}
+	msg, err := hex.DecodeString(utils.RemoveHexPrefix(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data format")
+	}
+	return msg, nil
+}
+
+// ========== personal_sign ============= //
+
+// PersonalSignRequest is [message, address, password], eth_sign's parameters the other way around.
+// The password is optional and must be empty, keystore accounts are unlocked with personal_unlockAccount
+type PersonalSignRequest struct {
+	Message  []byte
+	Account  string
+	Password string
+}
+
+func (r *PersonalSignRequest) UnmarshalJSON(data []byte) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	if paramsNum := len(params); paramsNum < 2 || paramsNum > 3 {
+		return fmt.Errorf("invalid parameters number - %d/3", paramsNum)
+	}
+
+	var message string
+	if err := json.Unmarshal(params[0], &message); err != nil {
+		return errors.New("data should be a hex string")
+	}
+	msg, err := decodeSignMessage(message)
+	if err != nil {
+		return err
+	}
+	r.Message = msg
+	if err := json.Unmarshal(params[1], &r.Account); err != nil {
+		return errors.New("account address should be a hex string")
+	}
+	if len(params) == 3 && string(params[2]) != "null" {
+		if err := json.Unmarshal(params[2], &r.Password); err != nil {
+			return errors.Wrap(err, "invalid password")
+		}
+	}
+
+	return nil
+}
+
+type PersonalSignResponse string
+
+// PersonalECRecoverRequest is [message, signature]
+type PersonalECRecoverRequest struct {
+	Message   []byte
+	Signature []byte
+}
+
+func (r *PersonalECRecoverRequest) UnmarshalJSON(data []byte) error {
+	var params []string
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	if len(params) != 2 {
+		return fmt.Errorf("invalid parameters number - %d/2", len(params))
+	}
+
+	msg, err := decodeSignMessage(params[0])
+	if err != nil {
+		return err
+	}
+	r.Message = msg
+	if r.Signature, err = hex.DecodeString(utils.RemoveHexPrefix(params[1])); err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	return nil
+}
+
+type PersonalECRecoverResponse string
+
+// ========== eth_signTypedData_v4 ============= //
+
+// TypedDataSignatureOptions is the optional last parameter of eth_signTypedData_v4 and revo_recoverTypedData.
+// RawDigest signs the EIP-712 digest itself, as contracts verifying with btc_ecrecover expect,
+// instead of the digest as a message with Revo's signed message header like eth_sign
+type TypedDataSignatureOptions struct {
+	RawDigest bool `json:"rawDigest"`
+}
+
+// SignTypedDataRequest is [address, typedData, options], options is optional
+type SignTypedDataRequest struct {
+	Account   string
+	TypedData *TypedData
+	Options   TypedDataSignatureOptions
+}
+
+func (r *SignTypedDataRequest) UnmarshalJSON(data []byte) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	if paramsNum := len(params); paramsNum < 2 || paramsNum > 3 {
+		return fmt.Errorf("invalid parameters number - %d/3", paramsNum)
+	}
+
+	if err := json.Unmarshal(params[0], &r.Account); err != nil {
+		return errors.Wrap(err, "invalid address")
+	}
+	typedData, err := UnmarshalTypedData(params[1])
+	if err != nil {
+		return err
+	}
+	r.TypedData = typedData
+	if len(params) == 3 && string(params[2]) != "null" {
+		if err := json.Unmarshal(params[2], &r.Options); err != nil {
+			return errors.Wrap(err, "invalid options")
+		}
+	}
+
+	return nil
+}
+
+type SignTypedDataResponse string
+
+// RecoverTypedDataRequest is [typedData, signature, options], options is optional
+type RecoverTypedDataRequest struct {
+	TypedData *TypedData
+	Signature []byte
+	Options   TypedDataSignatureOptions
+}
+
+func (r *RecoverTypedDataRequest) UnmarshalJSON(data []byte) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	if paramsNum := len(params); paramsNum < 2 || paramsNum > 3 {
+		return fmt.Errorf("invalid parameters number - %d/3", paramsNum)
+	}
+
+	typedData, err := UnmarshalTypedData(params[0])
+	if err != nil {
+		return err
+	}
+	r.TypedData = typedData
+	var signature string
+	if err := json.Unmarshal(params[1], &signature); err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+	if r.Signature, err = hex.DecodeString(utils.RemoveHexPrefix(signature)); err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+	if len(params) == 3 && string(params[2]) != "null" {
+		if err := json.Unmarshal(params[2], &r.Options); err != nil {
+			return errors.Wrap(err, "invalid options")
+		}
+	}
+
+	return nil
+}
+
+type RecoverTypedDataResponse string
+
 // ========== GetLogs ============= //
 
 type (
@@ -1137,3 +1389,21 @@ func (r *Web3Sha3Request) UnmarshalJSON(data []byte) error {
 }
 
 type NetPeerCountResponse string
+
+// ======= charon_getTransactionStatus ======= //
+type GetTransactionStatusRequest = GetTransactionByHashRequest
+
+type GetTransactionStatusResponse struct {
+	Hash   string `json:"hash"`
+	Status string `json:"status"`
+	// Only set when the status is "mined"
+	BlockHash     *string `json:"blockHash"`
+	BlockNumber   *string `json:"blockNumber"`
+	Confirmations string  `json:"confirmations"`
+	// How many times charon resent the transaction after it left the mempool
+	Rebroadcasts string `json:"rebroadcasts"`
+	// Last error revod returned for this transaction, if any
+	Error     string `json:"error,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
@@ -0,0 +1,312 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/utils"
+	"golang.org/x/crypto/sha3"
+)
+
+const typedDataDomainType = "EIP712Domain"
+
+var (
+	typedDataArrayRegexp = regexp.MustCompile(`^(.+)\[(\d*)\]$`)
+	typedDataIntRegexp   = regexp.MustCompile(`^(u?)int(\d*)$`)
+	typedDataBytesRegexp = regexp.MustCompile(`^bytes(\d+)$`)
+)
+
+// TypedData is EIP-712 typed structured data, as passed to eth_signTypedData_v4
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// UnmarshalTypedData accepts typed data as a JSON object or, like MetaMask sends it, as a JSON string holding the object
+func UnmarshalTypedData(data json.RawMessage) (*TypedData, error) {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err == nil {
+		data = json.RawMessage(encoded)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep uint256 values exact
+	decoder.UseNumber()
+
+	var typedData TypedData
+	if err := decoder.Decode(&typedData); err != nil {
+		return nil, errors.Wrap(err, "invalid typed data")
+	}
+	if _, ok := typedData.Types[typedDataDomainType]; !ok {
+		return nil, errors.Errorf("typed data has no %s type", typedDataDomainType)
+	}
+	if _, ok := typedData.Types[typedData.PrimaryType]; !ok {
+		return nil, errors.Errorf("unknown primary type %q", typedData.PrimaryType)
+	}
+
+	return &typedData, nil
+}
+
+// Digest returns the EIP-712 hash to sign, keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+func (td *TypedData) Digest() ([]byte, error) {
+	domainSeparator, err := td.HashStruct(typedDataDomainType, td.Domain)
+	if err != nil {
+		return nil, errors.Wrap(err, "domain")
+	}
+
+	if td.PrimaryType == typedDataDomainType {
+		return keccak256([]byte("\x19\x01"), domainSeparator), nil
+	}
+
+	messageHash, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, errors.Wrap(err, "message")
+	}
+
+	return keccak256([]byte("\x19\x01"), domainSeparator, messageHash), nil
+}
+
+// HashStruct returns keccak256(typeHash ‖ encodeData(data)) for the struct type 'primaryType'
+func (td *TypedData) HashStruct(primaryType string, data map[string]interface{}) ([]byte, error) {
+	encoded, err := td.encodeData(primaryType, data)
+	if err != nil {
+		return nil, err
+	}
+	return keccak256(encoded), nil
+}
+
+// EncodeType returns the EIP-712 type encoding of 'primaryType', like "Mail(Person from,Person to,string contents)Person(string name,address wallet)"
+func (td *TypedData) EncodeType(primaryType string) string {
+	deps := td.dependencies(primaryType, map[string]bool{})
+	sort.Strings(deps)
+
+	var buf strings.Builder
+	for _, dep := range append([]string{primaryType}, deps...) {
+		buf.WriteString(dep)
+		buf.WriteString("(")
+		for i, field := range td.Types[dep] {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(field.Type)
+			buf.WriteString(" ")
+			buf.WriteString(field.Name)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String()
+}
+
+// dependencies returns the struct types 'primaryType' references, directly or not, without 'primaryType' itself
+func (td *TypedData) dependencies(primaryType string, seen map[string]bool) []string {
+	seen[primaryType] = true
+
+	var deps []string
+	for _, field := range td.Types[primaryType] {
+		fieldType := baseType(field.Type)
+		if _, ok := td.Types[fieldType]; !ok || seen[fieldType] {
+			continue
+		}
+		deps = append(deps, fieldType)
+		deps = append(deps, td.dependencies(fieldType, seen)...)
+	}
+	return deps
+}
+
+func (td *TypedData) encodeData(primaryType string, data map[string]interface{}) ([]byte, error) {
+	fields := td.Types[primaryType]
+
+	encoded := keccak256([]byte(td.EncodeType(primaryType)))
+	for _, field := range fields {
+		value, err := td.encodeValue(field.Type, data[field.Name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s.%s", primaryType, field.Name)
+		}
+		encoded = append(encoded, value...)
+	}
+	return encoded, nil
+}
+
+// encodeValue returns the 32 byte encoding of 'value' as a 'fieldType'
+func (td *TypedData) encodeValue(fieldType string, value interface{}) ([]byte, error) {
+	if match := typedDataArrayRegexp.FindStringSubmatch(fieldType); match != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, errors.Errorf("expected an array for %s", fieldType)
+		}
+		if match[2] != "" {
+			if length, _ := strconv.Atoi(match[2]); length != len(items) {
+				return nil, errors.Errorf("expected %d items for %s, got %d", length, fieldType, len(items))
+			}
+		}
+
+		var encoded []byte
+		for _, item := range items {
+			itemEncoded, err := td.encodeValue(match[1], item)
+			if err != nil {
+				return nil, err
+			}
+			encoded = append(encoded, itemEncoded...)
+		}
+		return keccak256(encoded), nil
+	}
+
+	if _, ok := td.Types[fieldType]; ok {
+		if value == nil {
+			// a missing struct member is encoded as zeros
+			return make([]byte, 32), nil
+		}
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("expected an object for %s", fieldType)
+		}
+		return td.HashStruct(fieldType, data)
+	}
+
+	switch fieldType {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return nil, errors.New("expected a string")
+		}
+		return keccak256([]byte(str)), nil
+
+	case "bytes":
+		data, err := typedDataBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return keccak256(data), nil
+
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, errors.New("expected a bool")
+		}
+		if b {
+			return leftPad32([]byte{1}), nil
+		}
+		return make([]byte, 32), nil
+
+	case "address":
+		data, err := typedDataBytes(value)
+		if err != nil || len(data) != 20 {
+			return nil, errors.Errorf("invalid address %v", value)
+		}
+		return leftPad32(data), nil
+	}
+
+	if match := typedDataBytesRegexp.FindStringSubmatch(fieldType); match != nil {
+		size, _ := strconv.Atoi(match[1])
+		if size < 1 || size > 32 {
+			return nil, errors.Errorf("invalid type %s", fieldType)
+		}
+		data, err := typedDataBytes(value)
+		if err != nil || len(data) > size {
+			return nil, errors.Errorf("invalid %s %v", fieldType, value)
+		}
+		encoded := make([]byte, 32)
+		copy(encoded, data)
+		return encoded, nil
+	}
+
+	if match := typedDataIntRegexp.FindStringSubmatch(fieldType); match != nil {
+		bits := 256
+		if match[2] != "" {
+			bits, _ = strconv.Atoi(match[2])
+		}
+		if bits < 8 || bits > 256 || bits%8 != 0 {
+			return nil, errors.Errorf("invalid type %s", fieldType)
+		}
+		return encodeTypedDataInt(value, match[1] == "u", bits)
+	}
+
+	return nil, errors.Errorf("unknown type %s", fieldType)
+}
+
+func encodeTypedDataInt(value interface{}, unsigned bool, bits int) ([]byte, error) {
+	var n *big.Int
+	switch v := value.(type) {
+	case json.Number:
+		n, _ = new(big.Int).SetString(v.String(), 10)
+	case float64:
+		n, _ = new(big.Float).SetFloat64(v).Int(nil)
+	case string:
+		if strings.HasPrefix(v, "0x") || strings.HasPrefix(v, "0X") {
+			n, _ = new(big.Int).SetString(v[2:], 16)
+		} else {
+			n, _ = new(big.Int).SetString(v, 10)
+		}
+	}
+	if n == nil {
+		return nil, errors.Errorf("invalid integer %v", value)
+	}
+
+	if unsigned {
+		if n.Sign() < 0 || n.BitLen() > bits {
+			return nil, errors.Errorf("%s out of range for uint%d", n, bits)
+		}
+	} else {
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, errors.Errorf("%s out of range for int%d", n, bits)
+		}
+	}
+
+	if n.Sign() < 0 {
+		// two's complement
+		n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return leftPad32(n.Bytes()), nil
+}
+
+func typedDataBytes(value interface{}) ([]byte, error) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, errors.Errorf("expected a hex string, got %v", value)
+	}
+	data, err := hex.DecodeString(utils.RemoveHexPrefix(str))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex string %s", str)
+	}
+	return data, nil
+}
+
+func baseType(fieldType string) string {
+	for {
+		match := typedDataArrayRegexp.FindStringSubmatch(fieldType)
+		if match == nil {
+			return fieldType
+		}
+		fieldType = match[1]
+	}
+}
+
+func leftPad32(data []byte) []byte {
+	padded := make([]byte, 32)
+	copy(padded[32-len(data):], data)
+	return padded
+}
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		hash.Write(d)
+	}
+	return hash.Sum(nil)
+}
@@ -0,0 +1,91 @@
+package eth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// the example from EIP-712
+const testTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestTypedDataDigest(t *testing.T) {
+	typedData, err := UnmarshalTypedData(json.RawMessage(testTypedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := typedData.EncodeType("Mail"); got != "Mail(Person from,Person to,string contents)Person(string name,address wallet)" {
+		t.Errorf("unexpected type encoding %s", got)
+	}
+
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(domainSeparator); got != "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f" {
+		t.Errorf("unexpected domain separator %s", got)
+	}
+
+	digest, err := typedData.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(digest); got != "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2" {
+		t.Errorf("unexpected digest %s", got)
+	}
+
+	// MetaMask sends the typed data as a JSON string
+	encoded, _ := json.Marshal(testTypedData)
+	fromString, err := UnmarshalTypedData(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stringDigest, _ := fromString.Digest(); hex.EncodeToString(stringDigest) != hex.EncodeToString(digest) {
+		t.Error("typed data passed as a string has a different digest")
+	}
+}
+
+func TestTypedDataRejectsOutOfRangeIntegers(t *testing.T) {
+	typedData := &TypedData{Types: map[string][]TypedDataField{}}
+	if _, err := typedData.encodeValue("uint8", json.Number("256")); err == nil {
+		t.Error("expected 256 to be out of range for uint8")
+	}
+	encoded, err := typedData.encodeValue("int8", json.Number("-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(encoded) != "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" {
+		t.Errorf("unexpected encoding of -1: %x", encoded)
+	}
+}
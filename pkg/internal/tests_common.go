@@ -57,10 +57,18 @@ func (d *doerMappedMock) updateId(id int) {
 func (d *doerMappedMock) Do(request *http.Request) (*http.Response, error) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
-	requestJSON, err := parseRequestFromBody(request)
+	requestBody, err := ioutil.ReadAll(request.Body)
 	if err != nil {
 		return nil, err
 	}
+	if bytes.HasPrefix(bytes.TrimSpace(requestBody), []byte("[")) {
+		return d.doBatch(requestBody)
+	}
+
+	requestJSON := eth.JSONRPCRequest{}
+	if err := json.Unmarshal(requestBody, &requestJSON); err != nil {
+		return nil, err
+	}
 
 	if d.Responses[requestJSON.Method] == nil {
 		log.Printf("No mocked response for %s\n", requestJSON.Method)
@@ -73,6 +81,41 @@ func (d *doerMappedMock) Do(request *http.Request) (*http.Response, error) {
 	}, nil
 }
 
+// doBatch answers each call of a batch with the response mocked for its method, with the call's id
+func (d *doerMappedMock) doBatch(requestBody []byte) (*http.Response, error) {
+	var requests []eth.JSONRPCRequest
+	if err := json.Unmarshal(requestBody, &requests); err != nil {
+		return nil, err
+	}
+
+	responses := make([]json.RawMessage, 0, len(requests))
+	for _, request := range requests {
+		if d.Responses[request.Method] == nil {
+			log.Printf("No mocked response for %s\n", request.Method)
+		}
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal(d.popResponse(request.Method), &response); err != nil || response == nil {
+			responses = append(responses, json.RawMessage("null"))
+			continue
+		}
+		response["id"] = request.ID
+		responseRaw, err := json.Marshal(response)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, responseRaw)
+	}
+
+	responseRaw, err := json.Marshal(responses)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewReader(responseRaw)),
+	}, nil
+}
+
 func PrepareEthRPCRequest(id int, params []json.RawMessage) (*eth.JSONRPCRequest, error) {
 	requestID, err := json.Marshal(1)
 	if err != nil {
@@ -215,21 +258,6 @@ func (d *doerMappedMock) AddErrorWithRequestID(requestID int, requestType string
 	return nil
 }
 
-func parseRequestFromBody(request *http.Request) (*eth.JSONRPCRequest, error) {
-	requestJSON := eth.JSONRPCRequest{}
-	requestBody, err := ioutil.ReadAll(request.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	err = json.Unmarshal(requestBody, &requestJSON)
-	if err != nil {
-		return nil, err
-	}
-
-	return &requestJSON, err
-}
-
 func CreateMockedClient(doerInstance Doer) (revoClient *revo.Revo, err error) {
 	return CreateMockedClientForNetwork(doerInstance, "test")
 }
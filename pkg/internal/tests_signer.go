@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+// SignerServer is a signer daemon holding its keys in memory, it speaks the same API as the signers
+// revo.ExternalSigner talks to and stands in for them in tests
+type SignerServer struct {
+	accounts revo.Accounts
+}
+
+func NewSignerServer(accounts revo.Accounts) *SignerServer {
+	return &SignerServer{accounts: accounts}
+}
+
+// ServeHTTP answers a JSON-RPC request POSTed to the signer
+func (s *SignerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req revo.JSONRPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.handle(&req))
+}
+
+// Serve answers one JSON-RPC request per connection accepted on 'listener', until it is closed
+func (s *SignerServer) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			var req revo.JSONRPCRequest
+			if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+				return
+			}
+			json.NewEncoder(conn).Encode(s.handle(&req))
+		}()
+	}
+}
+
+func (s *SignerServer) handle(req *revo.JSONRPCRequest) interface{} {
+	result, err := s.call(req.Method, req.Params)
+
+	resp := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      req.ID,
+	}
+	if err != nil {
+		// Clef's code for denied requests
+		resp["error"] = &revo.SignerError{Code: -32000, Message: err.Error()}
+	} else {
+		resp["result"] = result
+	}
+	return resp
+}
+
+func (s *SignerServer) call(method string, rawParams json.RawMessage) (interface{}, error) {
+	switch method {
+	case revo.SignerMethodList:
+		addresses := make([]string, 0, len(s.accounts))
+		for _, acc := range s.accounts {
+			addresses = append(addresses, utils.AddHexPrefix((&revo.Account{WIF: acc}).ToHexAddress()))
+		}
+		return addresses, nil
+
+	case revo.SignerMethodSignData:
+		var params []string
+		if err := json.Unmarshal(rawParams, &params); err != nil || len(params) != 3 {
+			return nil, errors.New("expected [contentType, address, data]")
+		}
+		if params[0] != revo.SignerContentTypeRevoMessage {
+			return nil, errors.Errorf("unsupported content type %s", params[0])
+		}
+		acc := s.accounts.FindByHexAddress(strings.ToLower(utils.RemoveHexPrefix(params[1])))
+		if acc == nil {
+			return nil, revo.ErrUnknownAccount
+		}
+		message, err := hex.DecodeString(utils.RemoveHexPrefix(params[2]))
+		if err != nil {
+			return nil, err
+		}
+		sig, err := revo.SignMessage(acc.PrivKey, message)
+		if err != nil {
+			return nil, err
+		}
+		return utils.AddHexPrefix(hex.EncodeToString(sig)), nil
+
+	case revo.SignerMethodSignTransaction:
+		var params []revo.SignerTransaction
+		if err := json.Unmarshal(rawParams, &params); err != nil || len(params) != 1 {
+			return nil, errors.New("expected [transaction]")
+		}
+		return s.signTransaction(&params[0])
+
+	default:
+		return nil, errors.Errorf("the method %s does not exist/is not available", method)
+	}
+}
+
+func (s *SignerServer) signTransaction(req *revo.SignerTransaction) (*revo.SignerTransactionResult, error) {
+	acc := s.accounts.FindByHexAddress(strings.ToLower(utils.RemoveHexPrefix(req.From)))
+	if acc == nil {
+		return nil, revo.ErrUnknownAccount
+	}
+
+	tx, err := revo.DeserializeTx(req.UnsignedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	prevScripts := make([][]byte, 0, len(req.Inputs))
+	for _, input := range req.Inputs {
+		script, err := hex.DecodeString(input.Script)
+		if err != nil {
+			return nil, err
+		}
+		prevScripts = append(prevScripts, script)
+	}
+
+	if err := revo.SignTx(tx, prevScripts, acc); err != nil {
+		return nil, err
+	}
+
+	rawTx, err := revo.SerializeTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &revo.SignerTransactionResult{Raw: utils.AddHexPrefix(rawTx)}, nil
+}
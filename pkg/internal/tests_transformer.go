@@ -22,7 +22,7 @@ func (t *mockTransformer) Transform(req *eth.JSONRPCRequest, c echo.Context) (in
 	}
 	resp, err := proxy.Request(req, c)
 	if err != nil {
-		return nil, eth.NewCallbackError(errors.WithMessagef(err.Error(), "couldn't proxy %s request", req.Method).Error())
+		return nil, eth.NewCallbackErrorFrom(errors.WithMessagef(err.Error(), "couldn't proxy %s request", req.Method))
 	}
 	return resp, nil
 }
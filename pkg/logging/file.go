@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// FileConfig is a log file and when it's rotated, the rotated files are kept next to it
+type FileConfig struct {
+	Path string
+	// rotate when the file reaches this size, in megabytes, lumberjack's 100MB if 0
+	MaxSize int
+	// rotate this often, 0 only rotates on size
+	RotateEvery time.Duration
+	// rotated files kept, 0 keeps them all
+	MaxBackups int
+	// days rotated files are kept, 0 keeps them regardless of their age
+	MaxAge int
+	// gzip rotated files
+	Compress bool
+}
+
+// OpenFile opens the log file of 'config' for appending, the file is rotated until 'ctx' is done
+func OpenFile(ctx context.Context, config FileConfig) (*lumberjack.Logger, error) {
+	// lumberjack opens the file on the first write, fail now rather than losing every record
+	file, err := os.OpenFile(config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open log file %s", config.Path)
+	}
+	file.Close()
+
+	logFile := &lumberjack.Logger{
+		Filename:   config.Path,
+		MaxSize:    config.MaxSize,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAge,
+		Compress:   config.Compress,
+	}
+
+	if config.RotateEvery > 0 {
+		go rotate(ctx, logFile, config.RotateEvery)
+	}
+	go func() {
+		<-ctx.Done()
+		logFile.Close()
+	}()
+
+	return logFile, nil
+}
+
+func rotate(ctx context.Context, logFile *lumberjack.Logger, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logFile.Rotate()
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package logging builds charon's loggers: logfmt or JSON records, filtered by a level per component which can be
+// changed while charon runs, written to rotated files
+package logging
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// output formats
+const (
+	FormatLogfmt = "logfmt"
+	FormatJSON   = "json"
+)
+
+// levels, from the most verbose
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// ComponentKey is the key of the component a record comes from, the last one of a record wins so that a logger
+// derived from another one can have a level of its own
+const ComponentKey = "component"
+
+// DefaultComponent names the level of records from any component without a level of its own
+const DefaultComponent = "default"
+
+// Components are the components with log levels of their own
+var Components = []string{"transformer", "revo.Client", "notifier", "clientCache", "server"}
+
+var levelRanks = map[string]int{
+	LevelDebug: 0,
+	LevelInfo:  1,
+	LevelWarn:  2,
+	LevelError: 3,
+}
+
+func parseLevel(name string) (int, error) {
+	rank, ok := levelRanks[strings.ToLower(name)]
+	if !ok {
+		return 0, errors.Errorf("unknown log level '%s', expected debug, info, warn or error", name)
+	}
+	return rank, nil
+}
+
+func levelName(rank int) string {
+	for name, r := range levelRanks {
+		if r == rank {
+			return name
+		}
+	}
+	return ""
+}
+
+// Levels are the minimum levels of records logged for each component, safe to change while logging
+type Levels struct {
+	mutex        sync.RWMutex
+	defaultLevel int
+	components   map[string]int
+}
+
+// NewLevels returns levels logging 'defaultLevel' and above for every component
+func NewLevels(defaultLevel string) (*Levels, error) {
+	rank, err := parseLevel(defaultLevel)
+	if err != nil {
+		return nil, err
+	}
+	return &Levels{
+		defaultLevel: rank,
+		components:   make(map[string]int),
+	}, nil
+}
+
+// Set sets the level of 'component', DefaultComponent sets the level of components without one
+func (l *Levels) Set(component string, levelName string) error {
+	rank, err := parseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	if component == "" {
+		return errors.New("missing component")
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if component == DefaultComponent {
+		l.defaultLevel = rank
+	} else {
+		l.components[component] = rank
+	}
+	return nil
+}
+
+// SetAll sets the levels of a comma separated list of component=level
+func (l *Levels) SetAll(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("invalid component log level '%s', expected component=level", item)
+		}
+		if err := l.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update replaces every level with 'defaultLevel' and the comma separated component=level 'list', nothing changes
+// on error
+func (l *Levels) Update(defaultLevel string, list string) error {
+	updated, err := NewLevels(defaultLevel)
+	if err != nil {
+		return err
+	}
+	if err := updated.SetAll(list); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.defaultLevel = updated.defaultLevel
+	l.components = updated.components
+	return nil
+}
+
+// All returns the level of every component, DefaultComponent included
+func (l *Levels) All() map[string]string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	all := map[string]string{DefaultComponent: levelName(l.defaultLevel)}
+	for _, component := range Components {
+		all[component] = levelName(l.defaultLevel)
+	}
+	for component, rank := range l.components {
+		all[component] = levelName(rank)
+	}
+	return all
+}
+
+func (l *Levels) String() string {
+	all := l.All()
+	items := make([]string, 0, len(all))
+	for component, level := range all {
+		items = append(items, fmt.Sprintf("%s=%s", component, level))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (l *Levels) allows(component string, rank int) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	min, ok := l.components[component]
+	if !ok {
+		min = l.defaultLevel
+	}
+	return rank >= min
+}
+
+// NewLogger returns a logger writing 'format' records to 'w', records under the level of their component are
+// dropped. Records without a level are info records, nil 'levels' keep every record
+func NewLogger(w io.Writer, format string, levels *Levels) (log.Logger, error) {
+	var logger log.Logger
+	switch format {
+	case FormatLogfmt, "":
+		logger = log.NewLogfmtLogger(w)
+	case FormatJSON:
+		logger = log.NewJSONLogger(w)
+	default:
+		return nil, errors.Errorf("unknown log format '%s', expected logfmt or json", format)
+	}
+	// the writer may be shared with the request dumps
+	logger = log.NewSyncLogger(logger)
+
+	if levels == nil {
+		return logger, nil
+	}
+	return &filter{next: logger, levels: levels}, nil
+}
+
+type filter struct {
+	next   log.Logger
+	levels *Levels
+}
+
+func (f *filter) Log(keyvals ...interface{}) error {
+	rank := levelRanks[LevelInfo]
+	component := ""
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == level.Key() {
+			if value, ok := keyvals[i+1].(level.Value); ok {
+				if r, err := parseLevel(value.String()); err == nil {
+					rank = r
+				}
+			}
+		} else if keyvals[i] == ComponentKey {
+			component = fmt.Sprint(keyvals[i+1])
+		}
+	}
+
+	if !f.levels.allows(component, rank) {
+		return nil
+	}
+	return f.next.Log(keyvals...)
+}
@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+func TestLevelsPerComponent(t *testing.T) {
+	levels, err := NewLevels(LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := levels.SetAll("transformer=debug, clientCache=error"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer, FormatLogfmt, levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := log.WithPrefix(logger, ComponentKey, "revo.Client")
+	level.Debug(log.With(client, ComponentKey, "transformer")).Log("msg", "transformer debug")
+	level.Warn(log.With(client, ComponentKey, "clientCache")).Log("msg", "cache warn")
+	level.Info(client).Log("msg", "client info")
+	level.Warn(client).Log("msg", "client warn")
+	logger.Log("msg", "no level")
+
+	output := buffer.String()
+	for _, msg := range []string{"transformer debug", "client warn"} {
+		if !strings.Contains(output, msg) {
+			t.Errorf("'%s' is missing from:\n%s", msg, output)
+		}
+	}
+	for _, msg := range []string{"cache warn", "client info", "no level"} {
+		if strings.Contains(output, msg) {
+			t.Errorf("'%s' should have been dropped:\n%s", msg, output)
+		}
+	}
+
+	// changed while logging
+	if err := levels.Set("revo.Client", LevelInfo); err != nil {
+		t.Fatal(err)
+	}
+	level.Info(client).Log("msg", "client info again")
+	if !strings.Contains(buffer.String(), "client info again") {
+		t.Error("The new level of revo.Client wasn't applied")
+	}
+}
+
+func TestLevelsErrors(t *testing.T) {
+	if _, err := NewLevels("verbose"); err == nil {
+		t.Error("Expected an unknown level error")
+	}
+
+	levels, _ := NewLevels(LevelInfo)
+	for _, list := range []string{"transformer", "transformer=loud", "=debug"} {
+		if err := levels.SetAll(list); err == nil {
+			t.Errorf("Expected an error for '%s'", list)
+		}
+	}
+}
+
+func TestLevelsAll(t *testing.T) {
+	levels, _ := NewLevels(LevelInfo)
+	levels.Set("notifier", LevelDebug)
+	levels.Set(DefaultComponent, LevelError)
+
+	all := levels.All()
+	if all["notifier"] != LevelDebug || all["server"] != LevelError || all[DefaultComponent] != LevelError {
+		t.Errorf("Unexpected levels %v", all)
+	}
+	if !strings.HasPrefix(levels.String(), "clientCache=error,default=error,notifier=debug") {
+		t.Errorf("Unexpected levels %s", levels)
+	}
+}
+
+func TestLevelsUpdate(t *testing.T) {
+	levels, _ := NewLevels(LevelInfo)
+	levels.Set("notifier", LevelDebug)
+
+	if err := levels.Update(LevelWarn, "server=error"); err != nil {
+		t.Fatal(err)
+	}
+	all := levels.All()
+	if all["notifier"] != LevelWarn || all["server"] != LevelError || all[DefaultComponent] != LevelWarn {
+		t.Errorf("Unexpected levels %v", all)
+	}
+
+	if err := levels.Update(LevelDebug, "server=loud"); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if levels.All()[DefaultComponent] != LevelWarn {
+		t.Error("The levels changed despite the error")
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	levels, _ := NewLevels(LevelDebug)
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer, FormatJSON, levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level.Info(log.With(logger, ComponentKey, "server")).Log("msg", "started")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "info" || record["component"] != "server" || record["msg"] != "started" {
+		t.Errorf("Unexpected record %v", record)
+	}
+
+	if _, err := NewLogger(&buffer, "xml", levels); err == nil {
+		t.Error("Expected an unknown format error")
+	}
+}
+
+func TestOpenFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "charon.log")
+	if err := ioutil.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	file, err := OpenFile(ctx, FileConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("appended\n")); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "existing\nappended\n" {
+		t.Errorf("Unexpected log file content %q", content)
+	}
+}
+
+func TestOpenFileFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "charon.log")
+	if _, err := OpenFile(context.Background(), FileConfig{Path: path}); err == nil {
+		t.Error("Expected an error for a file in a missing directory")
+	}
+}
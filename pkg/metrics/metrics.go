@@ -0,0 +1,227 @@
+// Package metrics holds charon's Prometheus metrics, the packages doing the work update them and the server exposes
+// Registry at /metrics
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "charon"
+
+// label of ETH methods charon doesn't implement, they would otherwise give clients control of the label values
+const UnknownMethod = "unknown"
+
+// cache lookup results
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+var (
+	// Registry holds every charon metric along with the Go runtime and process metrics
+	Registry = prometheus.NewRegistry()
+
+	ETHRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "eth_requests_total",
+		Help:      "ETH JSON-RPC requests handled, by method.",
+	}, []string{"method"})
+	ETHErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "eth_errors_total",
+		Help:      "ETH JSON-RPC requests answered with an error, by method and error code.",
+	}, []string{"method", "code"})
+	ETHRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "eth_request_duration_seconds",
+		Help:      "Time taken to answer ETH JSON-RPC requests, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	RevodCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_calls_total",
+		Help:      "RPC calls made to revod, by method. Retries are counted separately.",
+	}, []string{"method"})
+	RevodErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_errors_total",
+		Help:      "RPC calls to revod which failed after their retries, by method.",
+	}, []string{"method"})
+	RevodRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_retries_total",
+		Help:      "RPC calls to revod retried because revod was busy, by method.",
+	}, []string{"method"})
+	RevodCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "revod_call_duration_seconds",
+		Help:      "Time taken by RPC calls to revod including their retries, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	RevodCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_cache_requests_total",
+		Help:      "Lookups of cacheable revod calls in the response cache, by method and result (hit or miss).",
+	}, []string{"method", "result"})
+	RevodCoalesced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_coalesced_calls_total",
+		Help:      "Calls to revod answered by an identical call in flight instead of being made again, by method.",
+	}, []string{"method"})
+	RevodCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "revod_cache_entries",
+		Help:      "Responses held by the revod response cache.",
+	})
+	RevodCacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "revod_cache_bytes",
+		Help:      "Approximate size of the responses held by the revod response cache.",
+	})
+	RevodCacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_cache_evictions_total",
+		Help:      "Responses removed from the revod response cache, by reason (expired, size, reorg, block or context).",
+	}, []string{"reason"})
+	RevodUpstreamHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "revod_upstream_height",
+		Help:      "Block height of each revod upstream, by host.",
+	}, []string{"upstream"})
+	RevodUpstreamHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "revod_upstream_healthy",
+		Help:      "Whether each revod upstream is used, 1, or only as a last resort, 0, by host.",
+	}, []string{"upstream"})
+	RevodUpstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_upstream_errors_total",
+		Help:      "Calls to each revod upstream which couldn't reach it, by host.",
+	}, []string{"upstream"})
+	RevodFailovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_failovers_total",
+		Help:      "Calls to revod made again on another upstream, by method.",
+	}, []string{"method"})
+	RevodBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "revod_breaker_state",
+		Help:      "State of the circuit breaker of each revod upstream, 0 closed, 1 half-open, 2 open, by host.",
+	}, []string{"upstream"})
+	RevodBreakerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revod_breaker_rejections_total",
+		Help:      "Calls to each revod upstream failed at once by its circuit breaker, by host.",
+	}, []string{"upstream"})
+
+	WebsocketConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Open websocket connections.",
+	})
+	Subscriptions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "subscriptions",
+		Help:      "Active eth_subscribe subscriptions, by type.",
+	}, []string{"type"})
+	Filters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "filters",
+		Help:      "Installed eth_newFilter, eth_newBlockFilter and eth_newPendingTransactionFilter filters, by type.",
+	}, []string{"type"})
+
+	BlockHashLatestBlock = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "blockhash_latest_block",
+		Help:      "Latest block seen by the block hash indexer.",
+	})
+	BlockHashMissingBlocks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "blockhash_missing_blocks",
+		Help:      "Blocks the block hash indexer has yet to index.",
+	})
+	BlockHashIndexedBlocks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blockhash_indexed_blocks_total",
+		Help:      "Blocks indexed by the block hash indexer.",
+	})
+	BlockHashLastIndexedBlock = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "blockhash_last_indexed_block",
+		Help:      "Last block indexed by the block hash indexer.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ETHRequests,
+		ETHErrors,
+		ETHRequestDuration,
+		RevodCalls,
+		RevodErrors,
+		RevodRetries,
+		RevodCallDuration,
+		RevodCache,
+		RevodCoalesced,
+		RevodCacheEntries,
+		RevodCacheBytes,
+		RevodCacheEvictions,
+		RevodUpstreamHeight,
+		RevodUpstreamHealthy,
+		RevodUpstreamErrors,
+		RevodFailovers,
+		RevodBreakerState,
+		RevodBreakerRejections,
+		WebsocketConnections,
+		Subscriptions,
+		Filters,
+		BlockHashLatestBlock,
+		BlockHashMissingBlocks,
+		BlockHashIndexedBlocks,
+		BlockHashLastIndexedBlock,
+	)
+}
+
+// Handler serves Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveETHRequest records an ETH request to 'method' which took 'duration', 'errorCode' is 0 when it succeeded
+func ObserveETHRequest(method string, duration time.Duration, errorCode int) {
+	ETHRequests.WithLabelValues(method).Inc()
+	ETHRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if errorCode != 0 {
+		ETHErrors.WithLabelValues(method, strconv.Itoa(errorCode)).Inc()
+	}
+}
+
+// ObserveRevodCall records a revod call to 'method' which took 'duration' including its 'retries'
+func ObserveRevodCall(method string, duration time.Duration, retries int, err error) {
+	RevodCalls.WithLabelValues(method).Inc()
+	RevodCallDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if retries > 0 {
+		RevodRetries.WithLabelValues(method).Add(float64(retries))
+	}
+	if err != nil {
+		RevodErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ObserveCacheLookup records a lookup of a cacheable revod call to 'method'
+func ObserveCacheLookup(method string, hit bool) {
+	result := CacheMiss
+	if hit {
+		result = CacheHit
+	}
+	RevodCache.WithLabelValues(method, result).Inc()
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveETHRequest(t *testing.T) {
+	ObserveETHRequest("eth_chainId", time.Millisecond, 0)
+	ObserveETHRequest("eth_chainId", time.Millisecond, -32005)
+
+	if got := testutil.ToFloat64(ETHRequests.WithLabelValues("eth_chainId")); got != 2 {
+		t.Errorf("Unexpected request count %v", got)
+	}
+	if got := testutil.ToFloat64(ETHErrors.WithLabelValues("eth_chainId", "-32005")); got != 1 {
+		t.Errorf("Unexpected error count %v", got)
+	}
+}
+
+func TestObserveRevodCall(t *testing.T) {
+	ObserveRevodCall("getblockcount", time.Millisecond, 2, nil)
+	ObserveRevodCall("getblockcount", time.Millisecond, 0, errors.New("revod is down"))
+	ObserveCacheLookup("getblockcount", true)
+	ObserveCacheLookup("getblockcount", false)
+
+	if got := testutil.ToFloat64(RevodCalls.WithLabelValues("getblockcount")); got != 2 {
+		t.Errorf("Unexpected call count %v", got)
+	}
+	if got := testutil.ToFloat64(RevodRetries.WithLabelValues("getblockcount")); got != 2 {
+		t.Errorf("Unexpected retry count %v", got)
+	}
+	if got := testutil.ToFloat64(RevodErrors.WithLabelValues("getblockcount")); got != 1 {
+		t.Errorf("Unexpected error count %v", got)
+	}
+	if got := testutil.ToFloat64(RevodCache.WithLabelValues("getblockcount", CacheHit)); got != 1 {
+		t.Errorf("Unexpected cache hit count %v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	WebsocketConnections.Set(3)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, want := range []string{"charon_websocket_connections 3", "go_goroutines"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("%q is missing from the metrics", want)
+		}
+	}
+}
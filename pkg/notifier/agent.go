@@ -11,6 +11,7 @@ import (
 	"github.com/labstack/echo"
 	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/metrics"
 	"github.com/revolutionchain/charon/pkg/revo"
 	"github.com/revolutionchain/charon/pkg/utils"
 )
@@ -42,10 +43,10 @@ func newAgentWithConfiguration(ctx context.Context, revo *revo.Revo, transformer
 		running:       false,
 		config:        configuration,
 		stop:          make(chan interface{}, 1000),
-		newHeads:      newSubscriptionRegistry(),
-		logs:          newSubscriptionRegistry(),
-		newPendingTxs: newSubscriptionRegistry(),
-		syncing:       newSubscriptionRegistry(),
+		newHeads:      newSubscriptionRegistry("newHeads"),
+		logs:          newSubscriptionRegistry("logs"),
+		newPendingTxs: newSubscriptionRegistry("newPendingTransactions"),
+		syncing:       newSubscriptionRegistry("syncing"),
 	}
 
 	go agent.run()
@@ -53,13 +54,16 @@ func newAgentWithConfiguration(ctx context.Context, revo *revo.Revo, transformer
 }
 
 type subscriptionRegistry struct {
+	// the subscription type, see the charon_subscriptions metric
+	name              string
 	mutex             sync.RWMutex
 	subscriptionCount int
 	subscriptions     map[string]*subscriptionInformation
 }
 
-func newSubscriptionRegistry() *subscriptionRegistry {
+func newSubscriptionRegistry(name string) *subscriptionRegistry {
 	return &subscriptionRegistry{
+		name:              name,
 		mutex:             sync.RWMutex{},
 		subscriptionCount: 0,
 		subscriptions:     make(map[string]*subscriptionInformation),
@@ -215,6 +219,7 @@ func addSubscription(subscription *subscriptionInformation, registry *subscripti
 	registry.subscriptions[subscription.id] = subscription
 	if !collision {
 		registry.subscriptionCount = registry.subscriptionCount + 1
+		metrics.Subscriptions.WithLabelValues(registry.name).Inc()
 	}
 
 	go subscription.run()
@@ -230,6 +235,7 @@ func removeSubscription(id string, registry *subscriptionRegistry) {
 		if exists {
 			delete(registry.subscriptions, id)
 			registry.subscriptionCount = registry.subscriptionCount - 1
+			metrics.Subscriptions.WithLabelValues(registry.name).Dec()
 		}
 		registry.mutex.Unlock()
 	}
@@ -77,7 +77,7 @@ func NewNotifier(ctx context.Context, close func(), send func([]byte) error, log
 		ctx:                   ctx,
 		close:                 close,
 		send:                  send,
-		logger:                log.WithPrefix(logger, "component", "notifier"),
+		logger:                log.With(logger, "component", "notifier"),
 		queue:                 make(chan interface{}, 50),
 		subscriptionIdPending: &pending,
 		subscriptionsFlushed:  &flushed,
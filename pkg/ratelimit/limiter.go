@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// how often a Limiter forgets the clients whose bucket refilled
+const sweepInterval = time.Minute
+
+// Limiter is a TokenBucket per client, like a client IP
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mutex     sync.Mutex
+	buckets   map[string]*TokenBucket
+	lastSweep time.Time
+}
+
+// NewLimiter allows each client 'rate' events per second on average, in bursts of up to 'burst' events
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*TokenBucket),
+	}
+}
+
+// Allow takes a token from the bucket of 'client' if there is one
+func (l *Limiter) Allow(client string) bool {
+	return l.AllowAt(client, time.Now())
+}
+
+// AllowAt takes a token from the bucket of 'client' if there is one at 'now'
+func (l *Limiter) AllowAt(client string, now time.Time) bool {
+	l.mutex.Lock()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = NewTokenBucket(l.rate, l.burst)
+		l.buckets[client] = bucket
+	}
+	l.mutex.Unlock()
+
+	return bucket.AllowAt(now)
+}
+
+// Len returns the number of clients the limiter keeps a bucket for
+func (l *Limiter) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.buckets)
+}
+
+// sweep drops full buckets, a new bucket would be the same
+func (l *Limiter) sweep(now time.Time) {
+	for client, bucket := range l.buckets {
+		if bucket.Full(now) {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(1, 1)
+	now := time.Now()
+
+	if !limiter.AllowAt("10.0.0.1", now) || limiter.AllowAt("10.0.0.1", now) {
+		t.Fatal("expected a single call to be allowed")
+	}
+	if !limiter.AllowAt("10.0.0.2", now) {
+		t.Fatal("expected clients to have their own bucket")
+	}
+
+	// refilled buckets are dropped
+	limiter.AllowAt("10.0.0.3", now.Add(sweepInterval))
+	if limiter.Len() != 1 {
+		t.Errorf("expected only the last client to be kept, got %d", limiter.Len())
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		// untrusted peers can't spoof their IP
+		{"1.2.3.4:5000", "5.6.7.8", "1.2.3.4"},
+		{"192.168.1.1:5000", "5.6.7.8", "5.6.7.8"},
+		// a client can put anything on the left
+		{"10.1.2.3:5000", "9.9.9.9, 5.6.7.8, 10.0.0.2", "5.6.7.8"},
+		{"10.1.2.3:5000", "", "10.1.2.3"},
+		{"10.1.2.3:5000", "garbage, 5.6.7.8", "5.6.7.8"},
+	} {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if ip := ClientIP(r, trusted); ip != test.expected {
+			t.Errorf("%s forwarding %q: expected %s, got %s", test.remoteAddr, test.forwarded, test.expected, ip)
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"not an ip"}); err == nil {
+		t.Error("expected an invalid proxy to be refused")
+	}
+}
@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !bucket.AllowAt(now) {
+			t.Fatalf("expected a burst of 3 to be allowed, call %d was refused", i+1)
+		}
+	}
+	if bucket.AllowAt(now) {
+		t.Fatal("expected the bucket to be empty")
+	}
+
+	// 2 tokens per second
+	now = now.Add(500 * time.Millisecond)
+	if !bucket.AllowAt(now) {
+		t.Fatal("expected a token after half a second")
+	}
+	if bucket.AllowAt(now) {
+		t.Fatal("expected only one token after half a second")
+	}
+
+	if !bucket.Full(now.Add(2 * time.Second)) {
+		t.Error("expected the bucket to refill")
+	}
+}
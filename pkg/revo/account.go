@@ -56,3 +56,18 @@ func (a *Account) ToBase58Address(isMain bool) (string, error) {
 
 	return addr.AddressPubKeyHash().String(), nil
 }
+
+// PubKeyHashToBase58Address returns the base58 address of the public key hash 'pkh', the decoded hex address
+func PubKeyHashToBase58Address(pkh []byte, isMain bool) (string, error) {
+	params := &revoMainNetParams
+	if !isMain {
+		params = &revoTestNetParams
+	}
+
+	addr, err := btcutil.NewAddressPubKeyHash(pkh, params)
+	if err != nil {
+		return "", err
+	}
+
+	return addr.String(), nil
+}
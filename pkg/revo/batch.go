@@ -0,0 +1,338 @@
+package revo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/metrics"
+	"github.com/revolutionchain/charon/pkg/tracing"
+)
+
+// DefaultBatchSize is how many calls are sent to revod in one JSON-RPC batch
+const DefaultBatchSize = 100
+
+var errMissingBatchResult = errors.New("revod didn't answer the call in its batch")
+
+// BatchCall is a call made to revod in a batch, Result is filled when it succeeds and Err is set when it fails
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+
+	rawResult     json.RawMessage
+	handledErrors map[error]bool
+	retries       int
+}
+
+// NewBatchCall returns a call to 'method' with 'params' whose result is unmarshalled into 'result'
+func NewBatchCall(method string, params interface{}, result interface{}) *BatchCall {
+	return &BatchCall{Method: method, Params: params, Result: result}
+}
+
+// The calls made by the Method functions of the same name, with the same params
+func NewGetTransactionCall(txID string) *BatchCall {
+	return NewBatchCall(MethodGetTransaction, &GetTransactionRequest{TxID: txID}, new(GetTransactionResponse))
+}
+
+func NewGetRawTransactionCall(txID string, hexEncoded bool) *BatchCall {
+	return NewBatchCall(MethodGetRawTransaction, &GetRawTransactionRequest{TxID: txID, Verbose: !hexEncoded}, new(GetRawTransactionResponse))
+}
+
+func NewDecodeRawTransactionCall(hex string) *BatchCall {
+	return NewBatchCall(MethodDecodeRawTransaction, DecodeRawTransactionRequest(hex), new(DecodedRawTransactionResponse))
+}
+
+func NewGetTransactionOutCall(hash string, voutNumber int, mempoolIncluded bool) *BatchCall {
+	req := GetTransactionOutRequest{Hash: hash, VoutNumber: voutNumber, MempoolIncluded: mempoolIncluded}
+	return NewBatchCall(MethodGetTransactionOut, req, new(GetTransactionOutResponse))
+}
+
+func NewGetBlockHashCall(b *big.Int) *BatchCall {
+	return NewBatchCall(MethodGetBlockHash, &GetBlockHashRequest{Int: b}, new(GetBlockHashResponse))
+}
+
+func NewGetBlockCall(hash string) *BatchCall {
+	return NewBatchCall(MethodGetBlock, &GetBlockRequest{Hash: hash}, new(GetBlockResponse))
+}
+
+// Batch makes 'calls' to revod with as few HTTP requests as it can, in JSON-RPC batches of at most the client's batch
+// size. Each call gets its own result or error, with revod's errors mapped as for a single call, and only the calls
+// which failed because revod was busy are made again, after backing off. Cached calls aren't sent. The error returned
+// is set when calls couldn't be sent at all, those calls have it as their error
+func (c *Client) Batch(ctx context.Context, calls []*BatchCall) error {
+	if ctx == nil {
+		ctx = c.GetContext()
+	}
+
+	if c.batchSize <= 1 {
+		// one call at a time
+		for _, call := range calls {
+			call.rawResult = nil
+			call.Err = c.RequestWithContext(ctx, call.Method, call.Params, &call.rawResult)
+			if call.Err == nil && call.Result != nil {
+				call.Err = c.unmarshalResult(call.Method, call.Params, call.rawResult, call.Result)
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	var sent []*BatchCall
+	for _, call := range calls {
+		call.Err, call.rawResult, call.retries = nil, nil, 0
+		if cachedResult, ok := c.cachedResult(ctx, call.Method, call.Params); ok {
+			call.setResult(c, cachedResult)
+			continue
+		}
+		sent = append(sent, call)
+	}
+	defer func() {
+		for _, call := range sent {
+			metrics.ObserveRevodCall(call.Method, time.Since(start), call.retries, call.Err)
+			recordCall(ctx, Call{Method: call.Method, Duration: time.Since(start), Retries: call.retries, Err: call.Err})
+		}
+	}()
+
+	var err error
+	pending := sent
+	max := maxTries()
+	for i := 0; len(pending) > 0; i++ {
+		var retry []*BatchCall
+		for first := 0; first < len(pending); first += c.batchSize {
+			last := first + c.batchSize
+			if last > len(pending) {
+				last = len(pending)
+			}
+			batch := pending[first:last]
+			if batchErr := c.doBatch(ctx, batch); batchErr != nil {
+				for _, call := range batch {
+					call.Err = batchErr
+				}
+				if batchErr != ErrRevoWorkQueueDepth {
+					err = batchErr
+				}
+			}
+			for _, call := range batch {
+				if call.Err == nil {
+					continue
+				}
+				if call.handledErrors == nil {
+					call.handledErrors = make(map[error]bool)
+				}
+				if c.retryable(ctx, call.Err, call.handledErrors) && i != max-1 {
+					retry = append(retry, call)
+				}
+			}
+		}
+		if len(retry) == 0 {
+			break
+		}
+
+		backoffTime := computeBackoff(i, true)
+		recordBackoff(ctx, "batch", i+1, backoffTime)
+		c.GetLogger().Log("msg", fmt.Sprintf("REVO process busy, backing off for %f seconds", backoffTime.Seconds()), "calls", len(retry))
+		var done <-chan struct{}
+		if c.ctx != nil {
+			done = c.ctx.Done()
+		}
+		select {
+		case <-time.After(backoffTime):
+		case <-done:
+			err = errors.WithMessage(c.ctx.Err(), "context cancelled")
+			for _, call := range retry {
+				call.Err = err
+			}
+			return err
+		}
+		for _, call := range retry {
+			call.retries++
+		}
+		c.GetLogger().Log("msg", "Retrying REVO commands", "calls", len(retry))
+		pending = retry
+	}
+
+	return err
+}
+
+// doBatch sends 'calls' to revod in one JSON-RPC batch, setting the result or error of each of them. The error
+// returned is set when the batch failed as a whole
+func (c *Client) doBatch(ctx context.Context, calls []*BatchCall) error {
+	ctx, span := startRevodBatchSpan(ctx, len(calls))
+	defer span.End()
+
+	requests := make([]*JSONRPCRequest, 0, len(calls))
+	byID := make(map[string]*BatchCall, len(calls))
+	method := ""
+	for _, call := range calls {
+		req, err := c.NewRPCRequest(call.Method, call.Params)
+		if err != nil {
+			call.Err = errors.WithMessage(err, "couldn't make new rpc request")
+			continue
+		}
+		call.Err = errMissingBatchResult
+		requests = append(requests, req)
+		byID[string(req.ID)] = call
+		// wallet calls take the batch to a wallet node
+		if method == "" || pinnedMethods[call.Method] {
+			method = call.Method
+		}
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	reqBody, err := json.MarshalIndent(requests, "", "  ")
+	if err != nil {
+		return err
+	}
+	c.GetDebugLogger().Log("msg", "revod batch", "calls", len(requests))
+	if c.IsDebugEnabled() && !c.GetFlagBool(FLAG_HIDE_REVOD_LOGS) && c.logWriter != nil {
+		fmt.Fprintf(c.logWriter, "=> revo RPC batch request\n%s\n", reqBody)
+	}
+
+	respBody, err := c.do(ctx, method, reqBody)
+	if err != nil {
+		c.failure()
+		tracing.RecordError(span, err)
+		return errors.Wrap(err, "Client#do")
+	}
+	if c.IsDebugEnabled() && !c.GetFlagBool(FLAG_HIDE_REVOD_LOGS) && c.logWriter != nil {
+		if formattedBody, err := ReformatJSON(respBody); err == nil {
+			fmt.Fprintf(c.logWriter, "<= revo RPC batch response\n%s\n", formattedBody)
+		}
+	}
+
+	var results []*JSONRPCResult
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		c.failure()
+		if string(respBody) == ErrRevoWorkQueueDepth.Error() {
+			// REVO http server queue depth reached, need to retry
+			return ErrRevoWorkQueueDepth
+		}
+		// revod answers a batch it can't parse with a single error
+		var result *JSONRPCResult
+		if json.Unmarshal(respBody, &result) == nil && result != nil && result.Error != nil {
+			err = result.Error.TryGetKnownError()
+		} else {
+			err = errors.Wrap(err, "couldn't parse batch response")
+		}
+		tracing.RecordError(span, err)
+		return err
+	}
+	c.success()
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		call, ok := byID[string(result.ID)]
+		if !ok {
+			continue
+		}
+		delete(byID, string(result.ID))
+		if result.Error != nil {
+			knownError := result.Error.TryGetKnownError()
+			if knownError != result.Error {
+				c.GetDebugLogger().Log("msg", fmt.Sprintf("Got error code %d with message '%s' mapped to %s", result.Error.Code, result.Error.Message, knownError.Error()))
+			}
+			call.Err = knownError
+			continue
+		}
+
+		call.Err = nil
+		call.setResult(c, result.RawResult)
+		if c.cache.isCachable(call.Method) {
+			c.cache.storeResponse(call.Method, call.Params, result.RawResult)
+		}
+		c.cache.observe(call.Method, call.Params, result.RawResult)
+	}
+	return nil
+}
+
+func (call *BatchCall) setResult(c *Client, rawResult json.RawMessage) {
+	call.rawResult = rawResult
+	if call.Result != nil {
+		call.Err = c.unmarshalResult(call.Method, call.Params, rawResult, call.Result)
+	}
+}
+
+// prefetched holds the results of the calls made ahead for a request
+type prefetched struct {
+	mutex   sync.RWMutex
+	results map[string]prefetchedCall
+}
+
+type prefetchedCall struct {
+	rawResult json.RawMessage
+	err       error
+}
+
+type prefetchedKey struct{}
+
+// WithPrefetch returns 'ctx' keeping the results of the calls prefetched with it, for the calls made with it later.
+// A context which already keeps them is returned as it is
+func WithPrefetch(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(prefetchedKey{}).(*prefetched); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, prefetchedKey{}, &prefetched{results: make(map[string]prefetchedCall)})
+}
+
+// Prefetch makes 'calls' in batches ahead of the calls needing them, which get their result or revod's error
+// instead of calling revod when they are made with 'ctx' from WithPrefetch. The results of calls made with another
+// context are only cached. Calls which couldn't be sent aren't kept, they are made again when needed
+func (c *Client) Prefetch(ctx context.Context, calls []*BatchCall) {
+	if len(calls) == 0 {
+		return
+	}
+	if ctx == nil {
+		ctx = c.GetContext()
+	}
+	c.Batch(ctx, calls)
+
+	if ctx == nil {
+		return
+	}
+	store, ok := ctx.Value(prefetchedKey{}).(*prefetched)
+	if !ok {
+		return
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, call := range calls {
+		var revodErr *JSONRPCError
+		if call.Err != nil && !IsKnownError(call.Err) && !errors.As(call.Err, &revodErr) {
+			continue
+		}
+		key, err := cacheKey(call.Method, call.Params)
+		if err != nil {
+			continue
+		}
+		store.results[key] = prefetchedCall{rawResult: call.rawResult, err: call.Err}
+	}
+}
+
+// prefetchedResult returns the result of the call to 'method' with 'params' prefetched for 'ctx', if it was
+func prefetchedResult(ctx context.Context, method string, params interface{}) (prefetchedCall, bool) {
+	if ctx == nil {
+		return prefetchedCall{}, false
+	}
+	store, ok := ctx.Value(prefetchedKey{}).(*prefetched)
+	if !ok {
+		return prefetchedCall{}, false
+	}
+	key, err := cacheKey(method, params)
+	if err != nil {
+		return prefetchedCall{}, false
+	}
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	call, ok := store.results[key]
+	return call, ok
+}
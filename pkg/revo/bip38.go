@@ -0,0 +1,173 @@
+package revo
+
+import (
+	"bytes"
+	"crypto/aes"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	bip38Prefix      = 0x01
+	bip38NonECPrefix = 0x42
+	bip38ECPrefix    = 0x43
+	bip38Compressed  = 0x20
+	bip38Length      = 39
+)
+
+var ErrUnsupportedBIP38 = errors.New("only non EC-multiplied BIP38 keys are supported")
+
+// IsBIP38 reports whether 's' looks like a BIP38 passphrase-encrypted private key (they start with "6P")
+func IsBIP38(s string) bool {
+	decoded := base58.Decode(s)
+	return len(decoded) == bip38Length+4 && decoded[0] == bip38Prefix && (decoded[1] == bip38NonECPrefix || decoded[1] == bip38ECPrefix)
+}
+
+// DecryptBIP38 decrypts a BIP38 encrypted private key, returning it as a WIF for the Revo network its address hash matches
+func DecryptBIP38(encrypted string, passphrase string) (*btcutil.WIF, error) {
+	decoded, err := decodeBIP38(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed := decoded[2]&bip38Compressed != 0
+	addressHash := decoded[3:7]
+
+	derived, err := scrypt.Key([]byte(passphrase), addressHash, 16384, 8, 8, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := make([]byte, 32)
+	block.Decrypt(keyBytes[:16], decoded[7:23])
+	block.Decrypt(keyBytes[16:], decoded[23:39])
+	for i := range keyBytes {
+		keyBytes[i] ^= derived[i]
+	}
+
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyBytes)
+	for _, params := range []*chaincfg.Params{&revoMainNetParams, &revoTestNetParams} {
+		wif, err := btcutil.NewWIF(key, params, compressed)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bip38AddressHash(wif, params)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(hash, addressHash) {
+			return wif, nil
+		}
+	}
+
+	return nil, ErrInvalidPassphrase
+}
+
+// EncryptBIP38 encrypts 'wif' with 'passphrase' as a non EC-multiplied BIP38 key
+func EncryptBIP38(wif *btcutil.WIF, isMain bool, passphrase string) (string, error) {
+	params := &revoMainNetParams
+	if !isMain {
+		params = &revoTestNetParams
+	}
+
+	addressHash, err := bip38AddressHash(wif, params)
+	if err != nil {
+		return "", err
+	}
+
+	derived, err := scrypt.Key([]byte(passphrase), addressHash, 16384, 8, 8, 64)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return "", err
+	}
+
+	keyBytes := paddedKeyBytes(wif.PrivKey)
+	for i := range keyBytes {
+		keyBytes[i] ^= derived[i]
+	}
+
+	flag := byte(0xc0)
+	if wif.CompressPubKey {
+		flag |= bip38Compressed
+	}
+
+	encrypted := make([]byte, bip38Length, bip38Length+4)
+	encrypted[0] = bip38Prefix
+	encrypted[1] = bip38NonECPrefix
+	encrypted[2] = flag
+	copy(encrypted[3:7], addressHash)
+	block.Encrypt(encrypted[7:23], keyBytes[:16])
+	block.Encrypt(encrypted[23:39], keyBytes[16:])
+	encrypted = append(encrypted, chainhash.DoubleHashB(encrypted)[:4]...)
+
+	return base58.Encode(encrypted), nil
+}
+
+// bip38MatchesAddress reports whether 'encrypted' could be the key for the Revo hex address 'hexAddress' without decrypting it
+func bip38MatchesAddress(encrypted string, hexAddress string) bool {
+	decoded, err := decodeBIP38(encrypted)
+	if err != nil {
+		return false
+	}
+
+	pubKeyHash, err := hexToPubKeyHash(hexAddress)
+	if err != nil {
+		return false
+	}
+
+	for _, params := range []*chaincfg.Params{&revoMainNetParams, &revoTestNetParams} {
+		addr, err := btcutil.NewAddressPubKeyHash(pubKeyHash, params)
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(chainhash.DoubleHashB([]byte(addr.EncodeAddress()))[:4], decoded[3:7]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func decodeBIP38(encrypted string) ([]byte, error) {
+	decoded := base58.Decode(encrypted)
+	if len(decoded) != bip38Length+4 {
+		return nil, errors.New("invalid BIP38 key length")
+	}
+	checksum := chainhash.DoubleHashB(decoded[:bip38Length])[:4]
+	if !bytes.Equal(checksum, decoded[bip38Length:]) {
+		return nil, errors.New("invalid BIP38 key checksum")
+	}
+	if decoded[0] != bip38Prefix {
+		return nil, errors.New("invalid BIP38 key prefix")
+	}
+	if decoded[1] == bip38ECPrefix {
+		return nil, ErrUnsupportedBIP38
+	}
+	if decoded[1] != bip38NonECPrefix {
+		return nil, errors.New("invalid BIP38 key prefix")
+	}
+	return decoded[:bip38Length], nil
+}
+
+func bip38AddressHash(wif *btcutil.WIF, params *chaincfg.Params) ([]byte, error) {
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(wif.SerializePubKey()), params)
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.DoubleHashB([]byte(addr.EncodeAddress()))[:4], nil
+}
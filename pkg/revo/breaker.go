@@ -0,0 +1,103 @@
+package revo
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/metrics"
+	"github.com/sony/gobreaker"
+)
+
+// ErrUpstreamUnavailable is returned without calling revod while the circuit breaker of every upstream the call could
+// go to is open
+var ErrUpstreamUnavailable = errors.New("upstream unavailable")
+
+// BreakerConfig sets when the circuit breaker of a revod upstream opens. An open breaker fails the calls to its
+// upstream at once instead of adding to its load, until it lets a few probe calls through after OpenTimeout and
+// closes again if they all succeed
+type BreakerConfig struct {
+	// consecutive failed calls opening the breaker, 0 disables it. Calls fail when revod can't be reached or times
+	// out, when it or a proxy in front of it answers it's unavailable and when its work queue is full
+	Failures uint32
+	// how long the breaker stays open before probing the upstream
+	OpenTimeout time.Duration
+	// calls let through while probing the upstream
+	Probes uint32
+}
+
+// DefaultBreakerConfig opens after 5 failed calls in a row and probes with 3 calls after 30 seconds
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Failures:    5,
+		OpenTimeout: 30 * time.Second,
+		Probes:      3,
+	}
+}
+
+// breaker states as reported in metrics and health checks
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+func newBreaker(host string, config BreakerConfig, logger log.Logger) *gobreaker.TwoStepCircuitBreaker {
+	if config.Failures == 0 {
+		return nil
+	}
+	metrics.RevodBreakerState.WithLabelValues(host).Set(float64(gobreaker.StateClosed))
+	return gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:        host,
+		MaxRequests: config.Probes,
+		Timeout:     config.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= config.Failures
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			metrics.RevodBreakerState.WithLabelValues(name).Set(float64(to))
+			logger.Log("msg", "revod upstream circuit breaker "+breakerState(to), "upstream", name, "from", breakerState(from))
+		},
+	})
+}
+
+func breakerState(state gobreaker.State) string {
+	switch state {
+	case gobreaker.StateHalfOpen:
+		return BreakerHalfOpen
+	case gobreaker.StateOpen:
+		return BreakerOpen
+	default:
+		return BreakerClosed
+	}
+}
+
+// overloaded tells whether an upstream which answered 'respBody' with 'status' is struggling
+func overloaded(status int, respBody []byte) bool {
+	return upstreamUnavailable(status) || bytes.Equal(bytes.TrimSpace(respBody), []byte(ErrRevoWorkQueueDepth.Error()))
+}
+
+// BreakerStates returns the state of the circuit breaker of every revod upstream, by host. Upstreams without a
+// breaker are always closed
+func (c *Client) BreakerStates() map[string]string {
+	states := make(map[string]string, len(c.upstreams.nodes))
+	for _, node := range c.upstreams.nodes {
+		states[node.host] = BreakerClosed
+		if node.breaker != nil {
+			states[node.host] = breakerState(node.breaker.State())
+		}
+	}
+	return states
+}
+
+// ReadBreakersOpen tells whether the circuit breaker of every revod upstream used for reads is open, calls then
+// fail at once
+func (c *Client) ReadBreakersOpen() bool {
+	for _, node := range c.upstreams.nodes {
+		if node.read && (node.breaker == nil || node.breaker.State() != gobreaker.StateOpen) {
+			return false
+		}
+	}
+	return true
+}
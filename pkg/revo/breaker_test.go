@@ -0,0 +1,134 @@
+package revo
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/revolutionchain/charon/pkg/metrics"
+)
+
+// switchDoer answers like an overloaded revod while 'down' is set, counting the calls it got
+func switchDoer(calls *int32, down *int32) doerFunc {
+	return doerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(calls, 1)
+		if atomic.LoadInt32(down) == 1 {
+			return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(bytes.NewBufferString("503 Service Unavailable"))}, nil
+		}
+		body := `{"result":"0a","error":null,"id":"1"}`
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(body))}, nil
+	})
+}
+
+func testBreakerConfig() BreakerConfig {
+	return BreakerConfig{Failures: 3, OpenTimeout: 50 * time.Millisecond, Probes: 2}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	var calls, down int32 = 0, 1
+	client := newBatchClient(t, switchDoer(&calls, &down), SetBreakerConfig(testBreakerConfig()))
+
+	var result string
+	for i := 0; i < 3; i++ {
+		if err := client.RequestWithContext(context.Background(), MethodGetBlockHash, []interface{}{i}, &result); err == nil {
+			t.Fatal("Expected an error from an overloaded revod")
+		}
+	}
+	if states := client.BreakerStates(); states["mocked"] != BreakerOpen {
+		t.Fatalf("Expected the breaker to be open, got %v", states)
+	}
+	if !client.ReadBreakersOpen() {
+		t.Error("Expected every read breaker to be open")
+	}
+	if got := testutil.ToFloat64(metrics.RevodBreakerState.WithLabelValues("mocked")); got != 2 {
+		t.Errorf("Unexpected breaker state metric %v", got)
+	}
+
+	// fails at once
+	err := client.RequestWithContext(context.Background(), MethodGetBlockHash, []interface{}{3}, &result)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("Expected %v, got %v", ErrUpstreamUnavailable, err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls to revod, got %d", calls)
+	}
+}
+
+func TestBreakerProbes(t *testing.T) {
+	var calls, down int32 = 0, 1
+	client := newBatchClient(t, switchDoer(&calls, &down), SetBreakerConfig(testBreakerConfig()))
+
+	var result string
+	for i := 0; i < 3; i++ {
+		client.RequestWithContext(context.Background(), MethodGetBlockHash, []interface{}{i}, &result)
+	}
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(60 * time.Millisecond)
+	if states := client.BreakerStates(); states["mocked"] != BreakerHalfOpen {
+		t.Fatalf("Expected the breaker to be half-open, got %v", states)
+	}
+
+	// a failed probe opens it again
+	atomic.StoreInt32(&down, 1)
+	if err := client.RequestWithContext(context.Background(), MethodGetBlockHash, []interface{}{3}, &result); errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatal("Expected the probe to be sent")
+	}
+	if states := client.BreakerStates(); states["mocked"] != BreakerOpen {
+		t.Fatalf("Expected the breaker to be open, got %v", states)
+	}
+
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := client.RequestWithContext(context.Background(), MethodGetBlockHash, []interface{}{4 + i}, &result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if states := client.BreakerStates(); states["mocked"] != BreakerClosed {
+		t.Errorf("Expected the breaker to be closed, got %v", states)
+	}
+}
+
+func TestBreakerIgnoresCallersGivingUp(t *testing.T) {
+	var calls int32
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	client := newBatchClient(t, doer, SetBreakerConfig(testBreakerConfig()))
+
+	var result string
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		client.RequestWithContext(ctx, MethodGetBlockHash, []interface{}{i}, &result)
+		cancel()
+	}
+	if states := client.BreakerStates(); states["mocked"] != BreakerClosed {
+		t.Errorf("Expected the breaker to stay closed, got %v", states)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	var calls, down int32 = 0, 1
+	client := newBatchClient(t, switchDoer(&calls, &down), SetBreakerConfig(BreakerConfig{}))
+
+	var result string
+	for i := 0; i < 10; i++ {
+		if err := client.RequestWithContext(context.Background(), MethodGetBlockHash, []interface{}{i}, &result); errors.Is(err, ErrUpstreamUnavailable) {
+			t.Fatal("Expected every call to be sent")
+		}
+	}
+	if calls != 10 {
+		t.Errorf("Expected 10 calls to revod, got %d", calls)
+	}
+	if client.ReadBreakersOpen() {
+		t.Error("Expected no breaker to be open")
+	}
+}
@@ -0,0 +1,55 @@
+package revo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Call is a call made to revod while answering a request
+type Call struct {
+	Method   string
+	Duration time.Duration
+	// answered from the client's cache
+	Cached bool
+	// answered by an identical call made at the same time for another caller
+	Shared bool
+	// times revod was busy and the call was made again
+	Retries int
+	Err     error
+}
+
+// CallLog collects the revod calls made with a context, the calls of a proxy may run concurrently
+type CallLog struct {
+	mutex sync.Mutex
+	calls []Call
+}
+
+type callLogKey struct{}
+
+// WithCallLog returns 'ctx' with a log of the revod calls made with it
+func WithCallLog(ctx context.Context) (context.Context, *CallLog) {
+	calls := &CallLog{}
+	return context.WithValue(ctx, callLogKey{}, calls), calls
+}
+
+// Calls returns the calls made so far, in the order they ended
+func (l *CallLog) Calls() []Call {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]Call(nil), l.calls...)
+}
+
+// recordCall adds 'call' to the call log of 'ctx', if it has one
+func recordCall(ctx context.Context, call Call) {
+	if ctx == nil {
+		return
+	}
+	calls, ok := ctx.Value(callLogKey{}).(*CallLog)
+	if !ok {
+		return
+	}
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+	calls.calls = append(calls.calls, call)
+}
@@ -1,27 +1,30 @@
 package revo
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/btcsuite/btcutil"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/analytics"
 	"github.com/revolutionchain/charon/pkg/blockhash"
+	"github.com/revolutionchain/charon/pkg/metrics"
+	"github.com/revolutionchain/charon/pkg/tracing"
+	"github.com/revolutionchain/charon/pkg/utils"
 )
 
 var FLAG_GENERATE_ADDRESS_TO = "REGTEST_GENERATE_ADDRESS_TO"
@@ -42,8 +45,15 @@ type Client struct {
 	ctx      context.Context
 	DbConfig blockhash.DatabaseConfig
 
-	// hex addresses to return for eth_accounts
-	Accounts Accounts
+	// hex addresses to return for eth_accounts, read with GetAccounts once the client is in use
+	Accounts      Accounts
+	accountsMutex sync.RWMutex
+	// passphrase-encrypted accounts, also returned by eth_accounts
+	KeyStore *KeyStore
+	// signs for accounts whose keys charon never holds, also returned by eth_accounts
+	Signer Signer
+	// the addresses Signer last listed
+	signerAccounts signerAccounts
 
 	logWriter io.Writer
 	logger    log.Logger
@@ -60,9 +70,21 @@ type Client struct {
 	flags map[string]interface{}
 
 	cache *clientCache
+	// identical calls being made
+	inflight *inflight
+	// most calls sent in one JSON-RPC batch
+	batchSize int
 
 	analytics    *analytics.Analytics
 	errorHandler ErrorHandler
+
+	// revod nodes, the first one is URL
+	upstreams             *upstreams
+	readUpstreams         []string
+	walletUpstreams       []string
+	upstreamMaxLag        int64
+	upstreamCheckInterval time.Duration
+	breakerConfig         BreakerConfig
 }
 
 func ReformatJSON(input []byte) ([]byte, error) {
@@ -113,6 +135,12 @@ func NewClient(isMain bool, rpcURL string, opts ...func(*Client) error) (*Client
 		mutex:  &sync.RWMutex{},
 		flags:  make(map[string]interface{}),
 		cache:  newClientCache(),
+
+		inflight:  newInflight(),
+		batchSize: DefaultBatchSize,
+
+		upstreamCheckInterval: DefaultUpstreamCheckInterval,
+		breakerConfig:         DefaultBreakerConfig(),
 	}
 
 	for _, opt := range opts {
@@ -121,7 +149,20 @@ func NewClient(isMain bool, rpcURL string, opts ...func(*Client) error) (*Client
 		}
 	}
 
-	c.cache.configLogger(c.logWriter, c.debug)
+	c.cache.configLogger(c.logger)
+	c.cache.setContext(c.ctx)
+
+	c.upstreams, err = newUpstreams(append([]string{rpcURL}, c.readUpstreams...), c.walletUpstreams)
+	if err != nil {
+		return nil, err
+	}
+	c.upstreams.maxLag = c.upstreamMaxLag
+	for _, node := range c.upstreams.nodes {
+		node.breaker = newBreaker(node.host, c.breakerConfig, c.logger)
+	}
+	if len(c.upstreams.nodes) > 1 && c.ctx != nil {
+		go c.checkUpstreams(c.ctx, c.upstreamCheckInterval)
+	}
 
 	return c, nil
 }
@@ -146,55 +187,133 @@ func (c *Client) Request(method string, params interface{}, result interface{})
 	return c.RequestWithContext(c.GetContext(), method, params, result)
 }
 
-func (c *Client) RequestWithContext(ctx context.Context, method string, params interface{}, result interface{}) error {
+func (c *Client) RequestWithContext(ctx context.Context, method string, params interface{}, result interface{}) (err error) {
 	if ctx == nil {
 		ctx = c.GetContext()
 	}
 
+	// made ahead for the request
+	if prefetched, ok := prefetchedResult(ctx, method, params); ok {
+		if prefetched.err != nil {
+			return prefetched.err
+		}
+		return c.unmarshalResult(method, params, prefetched.rawResult, result)
+	}
+
 	// check if method is cacheable first
-	if c.cache.isCachable(method) {
-		c.cache.setContext(ctx)
-		// check if we have a cached result
-		cachedResult, err := c.cache.getResponse(method, params)
-		if cachedResult != nil && err == nil {
-			// we have a cached result, return it
-			err := json.Unmarshal(cachedResult, result)
-			if err != nil {
-				c.GetDebugLogger().Log("method", method, "params", params, "result", result, "error", err)
-				return errors.Wrap(err, "couldn't unmarshal response result field")
-			}
-			if c.IsDebugEnabled() && !c.GetFlagBool(FLAG_HIDE_REVOD_LOGS) {
-				c.printRPCRequest(method, params)
-				c.printCachedRPCResponse(cachedResult)
-			}
-			return nil
+	if cachedResult, ok := c.cachedResult(ctx, method, params); ok {
+		// we have a cached result, return it
+		return c.unmarshalResult(method, params, cachedResult, result)
+	}
+
+	// we don't have a cached result, so we need to make a request, or wait for the same one to return
+	var rawResult json.RawMessage
+	if pinnedMethods[method] {
+		// wallet calls and broadcasts are made for each caller
+		rawResult, err = c.request(ctx, method, params)
+	} else {
+		rawResult, err = c.coalescedRequest(ctx, method, params)
+	}
+	if err != nil {
+		return err
+	}
+	return c.unmarshalResult(method, params, rawResult, result)
+}
+
+// cachedResult returns the cached result of 'method' with 'params', if it's cacheable and cached
+func (c *Client) cachedResult(ctx context.Context, method string, params interface{}) (json.RawMessage, bool) {
+	if !c.cache.isCachable(method) {
+		return nil, false
+	}
+	lookupStart := time.Now()
+	cachedResult, err := c.cache.getResponse(method, params)
+	metrics.ObserveCacheLookup(method, cachedResult != nil && err == nil)
+	if cachedResult == nil || err != nil {
+		return nil, false
+	}
+
+	_, span := startRevodSpan(ctx, method, true)
+	span.End()
+	recordCall(ctx, Call{Method: method, Duration: time.Since(lookupStart), Cached: true})
+	if c.IsDebugEnabled() && !c.GetFlagBool(FLAG_HIDE_REVOD_LOGS) {
+		c.printRPCRequest(method, params)
+		c.printCachedRPCResponse(cachedResult)
+	}
+	return cachedResult, true
+}
+
+func (c *Client) unmarshalResult(method string, params interface{}, rawResult json.RawMessage, result interface{}) error {
+	if err := json.Unmarshal(rawResult, result); err != nil {
+		c.GetDebugLogger().Log("method", method, "params", params, "result", result, "error", err)
+		return errors.Wrap(err, "couldn't unmarshal response result field")
+	}
+	return nil
+}
+
+// coalescedRequest makes the call to 'method' with 'params' once for the callers asking for it at the same time.
+// Only callers whose sessions have the same floor share a call, so that none is served from behind its view
+func (c *Client) coalescedRequest(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	key, err := cacheKey(method, params)
+	if err != nil {
+		return c.request(ctx, method, params)
+	}
+	var floor int64
+	session := sessionFromContext(ctx)
+	if session != nil {
+		floor = session.Height()
+		key += "@" + strconv.FormatInt(floor, 10)
+	}
+
+	start := time.Now()
+	result, shared, err := c.inflight.do(ctx, key, func() (json.RawMessage, *Session, error) {
+		callCtx := c.GetContext()
+		if callCtx == nil {
+			callCtx = context.Background()
 		}
+		if ctx != nil {
+			callCtx = sharedContext{Context: callCtx, values: ctx}
+		}
+		var callSession *Session
+		if session != nil {
+			callSession = &Session{height: floor}
+			callCtx = WithSession(callCtx, callSession)
+		}
+		result, err := c.request(callCtx, method, params)
+		return result, callSession, err
+	})
+	if shared {
+		metrics.RevodCoalesced.WithLabelValues(method).Inc()
+		recordSharedCall(ctx, method, start)
+		recordCall(ctx, Call{Method: method, Duration: time.Since(start), Shared: true, Err: err})
 	}
-	// we don't have a cached result, so we need to make a request
+	return result, err
+}
+
+// request makes the call to 'method' with 'params', retrying while revod is busy, and caches its result
+func (c *Client) request(ctx context.Context, method string, params interface{}) (rawResult json.RawMessage, err error) {
+	start := time.Now()
+	retries := 0
+	defer func() {
+		metrics.ObserveRevodCall(method, time.Since(start), retries, err)
+		recordCall(ctx, Call{Method: method, Duration: time.Since(start), Retries: retries, Err: err})
+	}()
+
 	req, err := c.NewRPCRequest(method, params)
 	if err != nil {
-		return errors.WithMessage(err, "couldn't make new rpc request")
+		return nil, errors.WithMessage(err, "couldn't make new rpc request")
 	}
 
 	handledErrors := make(map[error]bool)
 
 	var resp *SuccessJSONRPCResult
-	max := int(math.Floor(math.Max(float64(maximumRequestTime/int(maximumBackoff)), 1)))
+	max := maxTries()
 	for i := 0; i < max; i++ {
 		resp, err = c.Do(ctx, req)
 		if err != nil {
-			errorHandlerErr := c.errorHandler(ctx, err)
-			retry := false
-			if errorHandlerErr != nil && i != max-1 {
-				// only allow recovering from a specific error once
-				if _, ok := handledErrors[errorHandlerErr]; !ok {
-					handledErrors[err] = true
-					retry = true
-				}
-			}
-			if (retry || strings.Contains(err.Error(), ErrRevoWorkQueueDepth.Error())) && i != max-1 {
+			if c.retryable(ctx, err, handledErrors) && i != max-1 {
 				requestString := marshalToString(req)
 				backoffTime := computeBackoff(i, true)
+				recordBackoff(ctx, method, i+1, backoffTime)
 				c.GetLogger().Log("msg", fmt.Sprintf("REVO process busy, backing off for %f seconds", backoffTime.Seconds()), "request", requestString)
 				// TODO check if this works as expected
 				var done <-chan struct{}
@@ -206,34 +325,67 @@ func (c *Client) RequestWithContext(ctx context.Context, method string, params i
 				select {
 				case <-time.After(backoffTime):
 				case <-done:
-					return errors.WithMessage(ctx.Err(), "context cancelled")
+					return nil, errors.WithMessage(ctx.Err(), "context cancelled")
 				}
+				retries++
 				c.GetLogger().Log("msg", "Retrying REVO command")
 			} else {
 				if i != 0 {
 					c.GetLogger().Log("msg", fmt.Sprintf("Giving up on REVO RPC call after %d tries since its busy", i+1))
 				}
-				return err
+				return nil, err
 			}
 		} else {
 			break
 		}
 	}
 
-	err = json.Unmarshal(resp.RawResult, result)
-	if err != nil {
-		c.GetDebugLogger().Log("method", method, "params", params, "result", result, "error", err)
-		return errors.Wrap(err, "couldn't unmarshal response result field")
-	}
-
 	if c.cache.isCachable(method) {
 		c.cache.storeResponse(method, params, resp.RawResult)
 	}
+	c.cache.observe(method, params, resp.RawResult)
 
-	return nil
+	return resp.RawResult, nil
+}
+
+// maxTries is how many times a call is made while revod is busy
+func maxTries() int {
+	return int(math.Floor(math.Max(float64(maximumRequestTime/int(maximumBackoff)), 1)))
+}
+
+// retryable tells whether a call which failed with 'err' should be made again, because revod is busy or the error
+// handler is recovering from it. 'handledErrors' are the errors the call already recovered from
+func (c *Client) retryable(ctx context.Context, err error, handledErrors map[error]bool) bool {
+	if strings.Contains(err.Error(), ErrRevoWorkQueueDepth.Error()) {
+		return true
+	}
+	if c.errorHandler == nil {
+		return false
+	}
+	errorHandlerErr := c.errorHandler(ctx, err)
+	if errorHandlerErr == nil {
+		return false
+	}
+	// only allow recovering from a specific error once
+	if _, ok := handledErrors[errorHandlerErr]; ok {
+		return false
+	}
+	handledErrors[err] = true
+	return true
 }
 
 func (c *Client) Do(ctx context.Context, req *JSONRPCRequest) (*SuccessJSONRPCResult, error) {
+	ctx, span := startRevodSpan(ctx, req.Method, false)
+	defer span.End()
+
+	res, err := c.doRequest(ctx, req)
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+	return res, err
+}
+
+func (c *Client) doRequest(ctx context.Context, req *JSONRPCRequest) (*SuccessJSONRPCResult, error) {
 	reqBody, err := json.MarshalIndent(req, "", "  ")
 	if err != nil {
 		defer c.failure()
@@ -248,7 +400,7 @@ func (c *Client) Do(ctx context.Context, req *JSONRPCRequest) (*SuccessJSONRPCRe
 		fmt.Fprintf(c.logWriter, "=> revo RPC request\n%s\n", reqBody)
 	}
 
-	respBody, err := c.do(ctx, bytes.NewReader(reqBody))
+	respBody, err := c.do(ctx, req.Method, reqBody)
 	if err != nil {
 		defer c.failure()
 		return nil, errors.Wrap(err, "Client#do")
@@ -316,48 +468,17 @@ func (c *Client) NewRPCRequest(method string, params interface{}) (*JSONRPCReque
 
 	c.idMutex.Lock()
 	c.id = c.id.Add(c.id, c.idStep)
+	id := c.id.String()
 	c.idMutex.Unlock()
 
 	return &JSONRPCRequest{
 		JSONRPC: RPCVersion,
-		ID:      json.RawMessage(`"` + c.id.String() + `"`),
+		ID:      json.RawMessage(`"` + id + `"`),
 		Method:  method,
 		Params:  paramsJSON,
 	}, nil
 }
 
-func (c *Client) do(ctx context.Context, body io.Reader) ([]byte, error) {
-	var req *http.Request
-	var err error
-	if ctx != nil {
-		req, err = http.NewRequestWithContext(ctx, http.MethodPost, c.URL, body)
-	} else {
-		req, err = http.NewRequest(http.MethodPost, c.URL, body)
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	req.Close = false
-
-	resp, err := c.doer.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if resp != nil {
-			io.Copy(ioutil.Discard, resp.Body)
-			resp.Body.Close()
-		}
-	}()
-
-	reader, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "ioutil error in revo client package")
-	}
-	return reader, nil
-}
-
 func (c *Client) SetFlag(key string, value interface{}) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -422,6 +543,75 @@ func SetDoer(d doer) func(*Client) error {
 	}
 }
 
+// SetUpstreams adds revod nodes reads are spread over along with the client's URL
+func SetUpstreams(rawURLs []string) func(*Client) error {
+	return func(c *Client) error {
+		c.readUpstreams = rawURLs
+		return nil
+	}
+}
+
+// SetWalletUpstreams sets the revod nodes, in order of preference, wallet-dependent and broadcast calls go to. They
+// don't get reads unless they are the client's URL or in SetUpstreams. The client's URL is the only one by default
+func SetWalletUpstreams(rawURLs []string) func(*Client) error {
+	return func(c *Client) error {
+		c.walletUpstreams = rawURLs
+		return nil
+	}
+}
+
+// SetUpstreamMaxLag sets how many blocks an upstream may be behind the highest one and still get reads
+func SetUpstreamMaxLag(blocks int64) func(*Client) error {
+	return func(c *Client) error {
+		c.upstreamMaxLag = blocks
+		return nil
+	}
+}
+
+// SetUpstreamCheckInterval sets how often the height of each upstream is checked, with several of them
+func SetUpstreamCheckInterval(interval time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if interval <= 0 {
+			return errors.New("upstream check interval must be positive")
+		}
+		c.upstreamCheckInterval = interval
+		return nil
+	}
+}
+
+// SetBreakerConfig sets when the circuit breaker of each revod upstream opens, Failures of 0 disables them
+func SetBreakerConfig(config BreakerConfig) func(*Client) error {
+	return func(c *Client) error {
+		if config.Failures > 0 && (config.OpenTimeout <= 0 || config.Probes == 0) {
+			return errors.New("circuit breaker open timeout and probes must be positive")
+		}
+		c.breakerConfig = config
+		return nil
+	}
+}
+
+// SetCacheConfig sets how revod responses are cached, DefaultCacheConfig by default
+func SetCacheConfig(config CacheConfig) func(*Client) error {
+	return func(c *Client) error {
+		if config.MaxBytes <= 0 {
+			return errors.New("cache size must be positive")
+		}
+		c.cache.configure(config)
+		return nil
+	}
+}
+
+// SetBatchSize sets how many calls are sent to revod in one JSON-RPC batch, 1 sends them one at a time
+func SetBatchSize(n int) func(*Client) error {
+	return func(c *Client) error {
+		if n < 1 {
+			return errors.New("batch size must be at least 1")
+		}
+		c.batchSize = n
+		return nil
+	}
+}
+
 func SetDebug(debug bool) func(*Client) error {
 	return func(c *Client) error {
 		c.debug = debug
@@ -450,6 +640,83 @@ func SetAccounts(accounts Accounts) func(*Client) error {
 	}
 }
 
+// GetAccounts returns the plaintext accounts
+func (c *Client) GetAccounts() Accounts {
+	c.accountsMutex.RLock()
+	defer c.accountsMutex.RUnlock()
+	return c.Accounts
+}
+
+// UpdateAccounts replaces the plaintext accounts while the client is in use
+func (c *Client) UpdateAccounts(accounts Accounts) {
+	c.accountsMutex.Lock()
+	defer c.accountsMutex.Unlock()
+	c.Accounts = accounts
+}
+
+func SetKeyStore(keyStore *KeyStore) func(*Client) error {
+	return func(c *Client) error {
+		c.KeyStore = keyStore
+		return nil
+	}
+}
+
+func SetSigner(signer Signer) func(*Client) error {
+	return func(c *Client) error {
+		c.Signer = signer
+		return nil
+	}
+}
+
+// FindAccount returns the hosted key for the hex address 'addr'. Keys from the key store are only returned while unlocked,
+// otherwise ErrAccountLocked is returned. Returns ErrUnknownAccount if 'addr' isn't hosted
+func (c *Client) FindAccount(addr string) (*btcutil.WIF, error) {
+	addr = strings.ToLower(utils.RemoveHexPrefix(addr))
+	if acc := c.GetAccounts().FindByHexAddress(addr); acc != nil {
+		return acc, nil
+	}
+	return c.KeyStore.Find(addr)
+}
+
+// IsHostedAccount reports whether the key for the hex address 'addr' is hosted, locked or not
+func (c *Client) IsHostedAccount(addr string) bool {
+	_, err := c.FindAccount(addr)
+	return err != ErrUnknownAccount
+}
+
+// AccountAddresses returns the hex addresses of every hosted account, locked or not
+func (c *Client) AccountAddresses() []string {
+	accounts := c.GetAccounts()
+	addresses := make([]string, 0, len(accounts))
+	for _, acc := range accounts {
+		addresses = append(addresses, (&Account{acc}).ToHexAddress())
+	}
+	return append(addresses, c.KeyStore.Addresses()...)
+}
+
+// SignerAccountAddresses returns the hex addresses the external signer signs for, nil without an external signer
+func (c *Client) SignerAccountAddresses(ctx context.Context) ([]string, error) {
+	if c.Signer == nil {
+		return nil, nil
+	}
+	return c.signerAccounts.get(ctx, c.Signer)
+}
+
+// IsSignerAccount reports whether the external signer signs for the hex address 'addr'
+func (c *Client) IsSignerAccount(ctx context.Context, addr string) (bool, error) {
+	addresses, err := c.SignerAccountAddresses(ctx)
+	if err != nil {
+		return false, err
+	}
+	addr = strings.ToLower(utils.RemoveHexPrefix(addr))
+	for _, address := range addresses {
+		if address == addr {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func SetGenerateToAddress(address string) func(*Client) error {
 	return func(c *Client) error {
 		if address != "" {
@@ -564,11 +831,9 @@ func (c *Client) GetLogger() log.Logger {
 	return c.logger
 }
 
+// GetDebugLogger returns a logger for debug records, the logger's levels decide whether they are written
 func (c *Client) GetDebugLogger() log.Logger {
-	if !c.IsDebugEnabled() {
-		return log.NewNopLogger()
-	}
-	return log.With(level.Debug(c.logger))
+	return level.Debug(c.logger)
 }
 
 func (c *Client) GetErrorLogger() log.Logger {
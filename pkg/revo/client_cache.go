@@ -1,15 +1,17 @@
 package revo
 
 import (
+	"container/heap"
+	"container/list"
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/revolutionchain/charon/pkg/metrics"
 )
 
 // sets the timeout for flushing out the cashed memory
@@ -39,23 +41,123 @@ var cachable_methods = []string{
 	RevoMethodDecoderawtransaction,
 }
 
-// stores the rpc response for 'method' and 'params' in the cache
-// 'methods' is a map where keys are method names and values are maps of rpc responses
-type clientCache struct {
-	mu        sync.RWMutex
-	ctx       context.Context
-	logger    log.Logger
-	logWriter io.Writer
-	debug     bool
-	methods   map[string]responses
+// immutableMethods answer the same whatever the chain's state, their responses are always final
+var immutableMethods = map[string]bool{
+	RevoMethodGethexaddress:        true,
+	RevoMethodDecoderawtransaction: true,
 }
 
-// 'responses' is a map where keys are rpc param bytes, and values are response bytes (for the given method)
-type responses map[string][]byte
+// estimated memory held by an entry on top of its key and response
+const cacheEntryOverhead = 200
+
+// heights of the blocks seen which are remembered to notice reorgs
+const cacheBlockHeightsKept = 1000
+
+// cache eviction reasons
+const (
+	evictedExpired = "expired"
+	evictedSize    = "size"
+	evictedReorg   = "reorg"
+	evictedBlock   = "block"
+	evictedContext = "context"
+)
+
+// CacheConfig sets how much of revod's responses are cached and for how long
+type CacheConfig struct {
+	// approximate memory used by cached responses, the least recently used ones are evicted past it
+	MaxBytes int64
+	// how long responses are cached, responses which aren't final are also dropped when the next block shows up
+	TTL time.Duration
+	// TTL by method, a method with a TTL of 0 isn't cached
+	TTLs map[string]time.Duration
+	// how long final responses are cached: blocks and transactions with FinalityDepth confirmations, and responses
+	// which can't change
+	FinalTTL time.Duration
+	// confirmations after which a block or transaction is final
+	FinalityDepth int64
+}
+
+// DefaultCacheConfig caches up to 64MB of responses for 15 seconds, or an hour once they are 10 blocks deep
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		MaxBytes:      64 * 1024 * 1024,
+		TTL:           CACHABLE_METHOD_CACHE_TIMEOUT,
+		FinalTTL:      time.Hour,
+		FinalityDepth: 10,
+	}
+}
+
+type cacheEntry struct {
+	key      string
+	method   string
+	response []byte
+	expires  time.Time
+	// the block the response is about, if any, entries are dropped when it's reorged
+	blockHash string
+
+	element *list.Element
+	// position in the expiry heap
+	index int
+}
+
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.response) + cacheEntryOverhead)
+}
+
+// cacheExpiries is a heap of entries by expiry
+type cacheExpiries []*cacheEntry
+
+func (h cacheExpiries) Len() int           { return len(h) }
+func (h cacheExpiries) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h cacheExpiries) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *cacheExpiries) Push(x interface{}) {
+	entry := x.(*cacheEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+func (h *cacheExpiries) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// clientCache holds revod responses by method and params in a least recently used list bounded by memory. Expired
+// responses are removed as the cache is used, so no goroutine is needed per entry
+type clientCache struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	logger log.Logger
+	config CacheConfig
+	now    func() time.Time
+
+	entries  map[string]*cacheEntry
+	lru      *list.List
+	expiries cacheExpiries
+	bytes    int64
+
+	// entries by the block they are about, and the blocks seen by height
+	byBlock      map[string]map[*cacheEntry]struct{}
+	blocks       map[int64]string
+	blocksLatest int64
+	// entries which aren't final, their confirmations change with the next block
+	unsettled map[*cacheEntry]struct{}
+}
 
 func newClientCache() *clientCache {
 	return &clientCache{
-		methods: make(map[string]responses),
+		config:    DefaultCacheConfig(),
+		now:       time.Now,
+		entries:   make(map[string]*cacheEntry),
+		lru:       list.New(),
+		byBlock:   make(map[string]map[*cacheEntry]struct{}),
+		blocks:    make(map[int64]string),
+		unsettled: make(map[*cacheEntry]struct{}),
 	}
 }
 
@@ -63,98 +165,297 @@ func newClientCache() *clientCache {
 func (cache *clientCache) isCachable(method string) bool {
 	for _, m := range cachable_methods {
 		if m == method {
-			return true
+			cache.mu.Lock()
+			defer cache.mu.Unlock()
+			return cache.ttl(method) > 0
 		}
 	}
 	return false
 }
 
+func (cache *clientCache) ttl(method string) time.Duration {
+	if ttl, ok := cache.config.TTLs[method]; ok {
+		return ttl
+	}
+	return cache.config.TTL
+}
+
+func cacheKey(method string, params interface{}) (string, error) {
+	parambytes, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return method + " " + string(parambytes), nil
+}
+
 // stores the rpc response for 'method' and 'params' in the cache
 func (cache *clientCache) storeResponse(method string, params interface{}, response []byte) error {
-	parambytes, err := json.Marshal(params)
+	key, err := cacheKey(method, params)
 	if err != nil {
 		return errors.New("failed to marshal params")
 	}
+
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	responses, ok := cache.methods[method]
-	if !ok {
-		responses = make(map[string][]byte)
-		cache.methods[method] = responses
+
+	now := cache.now()
+	cache.expire(now)
+
+	ttl := cache.ttl(method)
+	if ttl <= 0 || includesMempool(params) {
+		return nil
+	}
+	if _, ok := cache.entries[key]; ok {
+		return nil
+	}
+
+	entry := &cacheEntry{key: key, method: method, response: response}
+	final := cache.final(entry)
+	if final {
+		ttl = cache.config.FinalTTL
+	} else {
+		cache.unsettled[entry] = struct{}{}
 	}
-	if _, ok := responses[string(parambytes)]; !ok {
-		responses[string(parambytes)] = response
-		cache.setFlushResponseTimer(method, parambytes)
+	entry.expires = now.Add(ttl)
+
+	entry.element = cache.lru.PushFront(entry)
+	heap.Push(&cache.expiries, entry)
+	cache.entries[key] = entry
+	cache.bytes += entry.size()
+	if entry.blockHash != "" {
+		entries, ok := cache.byBlock[entry.blockHash]
+		if !ok {
+			entries = make(map[*cacheEntry]struct{})
+			cache.byBlock[entry.blockHash] = entries
+		}
+		entries[entry] = struct{}{}
+	}
+
+	for cache.bytes > cache.config.MaxBytes && cache.lru.Len() > 0 {
+		cache.remove(cache.lru.Back().Value.(*cacheEntry), evictedSize)
 	}
+	cache.updateMetrics()
 	return nil
 }
 
+// includesMempool tells whether a call's response depends on the mempool, which changes with every transaction so it
+// isn't cached
+func includesMempool(params interface{}) bool {
+	switch req := params.(type) {
+	case GetTransactionOutRequest:
+		return req.MempoolIncluded
+	case *GetTransactionOutRequest:
+		return req != nil && req.MempoolIncluded
+	}
+	return false
+}
+
+// final tells whether the response of 'entry' won't change, setting the block it's about
+func (cache *clientCache) final(entry *cacheEntry) bool {
+	if immutableMethods[entry.method] {
+		return true
+	}
+
+	switch entry.method {
+	case RevoMethodGetblock, RevoMethodGetrawtransaction:
+		// without verbosity a block or transaction is serialized, which can't change for its hash
+		var serialized string
+		if json.Unmarshal(entry.response, &serialized) == nil {
+			return true
+		}
+
+		var response struct {
+			Hash          string `json:"hash"`
+			BlockHash     string `json:"blockhash"`
+			Height        int64  `json:"height"`
+			Confirmations int64  `json:"confirmations"`
+		}
+		if err := json.Unmarshal(entry.response, &response); err != nil || response.Confirmations <= 0 {
+			// unconfirmed, or a block out of the main chain
+			return false
+		}
+		if entry.method == RevoMethodGetblock {
+			entry.blockHash = response.Hash
+			cache.observeBlock(response.Height, response.Hash)
+		} else {
+			entry.blockHash = response.BlockHash
+		}
+		return response.Confirmations >= cache.config.FinalityDepth
+	}
+	return false
+}
+
 // returns the cached rpc response for 'method' and 'params'
 func (cache *clientCache) getResponse(method string, params interface{}) ([]byte, error) {
-	parambytes, err := json.Marshal(params)
+	key, err := cacheKey(method, params)
 	if err != nil {
 		return nil, errors.New("failed to marshal param")
 	}
-	cache.mu.RLock()
-	defer cache.mu.RUnlock()
-	if resp, ok := cache.methods[method]; ok {
-		if r, ok := resp[string(parambytes)]; ok {
-			return r, nil
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.expire(cache.now())
+	entry, ok := cache.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	cache.lru.MoveToFront(entry.element)
+	return entry.response, nil
+}
+
+// observe looks at the response of any revod call for the blocks in the main chain
+func (cache *clientCache) observe(method string, params interface{}, response []byte) {
+	var height int64
+	var hash string
+	switch method {
+	case MethodGetBlockHash:
+		var heights []int64
+		paramBytes, err := json.Marshal(params)
+		if err != nil || json.Unmarshal(paramBytes, &heights) != nil || len(heights) == 0 {
+			return
+		}
+		if json.Unmarshal(response, &hash) != nil {
+			return
+		}
+		height = heights[0]
+	case RevoMethodGetblockheader:
+		var header struct {
+			Hash          string `json:"hash"`
+			Height        int64  `json:"height"`
+			Confirmations int64  `json:"confirmations"`
+		}
+		if json.Unmarshal(response, &header) != nil || header.Confirmations <= 0 {
+			return
+		}
+		height, hash = header.Height, header.Hash
+	case MethodGetBlockCount:
+		if json.Unmarshal(response, &height) != nil {
+			return
+		}
+	case MethodGetBlockChainInfo:
+		var info struct {
+			Blocks        int64  `json:"blocks"`
+			BestBlockHash string `json:"bestblockhash"`
 		}
+		if json.Unmarshal(response, &info) != nil {
+			return
+		}
+		height, hash = info.Blocks, info.BestBlockHash
+	default:
+		return
 	}
-	return nil, nil
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.observeBlock(height, hash)
+	cache.updateMetrics()
 }
 
-// set a timer to flush the cached rpc response for 'method' and 'parambytes'
-func (cache *clientCache) setFlushResponseTimer(method string, parambytes []byte) {
-	go func() {
-		// TODO check if this works as expected
-		var done <-chan struct{}
-		if cache.ctx != nil {
-			done = cache.ctx.Done()
-		} else {
-			done = context.Background().Done()
+// observeBlock records 'hash' as the block at 'height' in the main chain, when another block was there it and the
+// blocks above it were reorged and the responses about them are dropped. The responses which aren't final are dropped
+// when 'height' is a new block, 'hash' can be empty when only the height is known
+func (cache *clientCache) observeBlock(height int64, hash string) {
+	if height > cache.blocksLatest && len(cache.unsettled) > 0 {
+		cache.getDebugLogger().Log("msg", "flushing cache", "reason", "new block", "height", height, "entries", len(cache.unsettled))
+		for entry := range cache.unsettled {
+			cache.remove(entry, evictedBlock)
+		}
+	}
+	if height > cache.blocksLatest {
+		cache.blocksLatest = height
+		for h := range cache.blocks {
+			if h <= height-cacheBlockHeightsKept {
+				delete(cache.blocks, h)
+			}
 		}
-		select {
-		case <-time.After(CACHABLE_METHOD_CACHE_TIMEOUT):
-			cache.getDebugLogger().Log("msg", "flushing cache", "reason", "cache timeout", "method", method)
-		case <-done:
-			cache.getDebugLogger().Log("msg", "flushing cache", "reason", "context canceled", "method", method)
+	}
+	if hash == "" {
+		return
+	}
+	if known, ok := cache.blocks[height]; ok && known != hash {
+		for h, reorged := range cache.blocks {
+			if h < height {
+				continue
+			}
+			cache.getDebugLogger().Log("msg", "flushing cache", "reason", "reorg", "height", h, "block", reorged)
+			for entry := range cache.byBlock[reorged] {
+				cache.remove(entry, evictedReorg)
+			}
+			delete(cache.blocks, h)
 		}
-		cache.mu.Lock()
-		defer cache.mu.Unlock()
-		delete(cache.methods[method], string(parambytes))
-	}()
+	}
+	cache.blocks[height] = hash
 }
 
-func (cache *clientCache) setContext(ctx context.Context) {
+// expire removes the entries expired at 'now', or all of them once the client's context is done
+func (cache *clientCache) expire(now time.Time) {
+	if cache.ctx != nil && cache.ctx.Err() != nil && len(cache.entries) > 0 {
+		cache.getDebugLogger().Log("msg", "flushing cache", "reason", "context canceled")
+		for _, entry := range cache.entries {
+			cache.remove(entry, evictedContext)
+		}
+	}
+	for len(cache.expiries) > 0 && !cache.expiries[0].expires.After(now) {
+		entry := cache.expiries[0]
+		cache.getDebugLogger().Log("msg", "flushing cache", "reason", "cache timeout", "method", entry.method)
+		cache.remove(entry, evictedExpired)
+	}
+	cache.updateMetrics()
+}
+
+func (cache *clientCache) remove(entry *cacheEntry, reason string) {
+	if _, ok := cache.entries[entry.key]; !ok {
+		return
+	}
+	delete(cache.entries, entry.key)
+	delete(cache.unsettled, entry)
+	cache.lru.Remove(entry.element)
+	heap.Remove(&cache.expiries, entry.index)
+	cache.bytes -= entry.size()
+	if entries, ok := cache.byBlock[entry.blockHash]; ok {
+		delete(entries, entry)
+		if len(entries) == 0 {
+			delete(cache.byBlock, entry.blockHash)
+		}
+	}
+	metrics.RevodCacheEvictions.WithLabelValues(reason).Inc()
+}
+
+func (cache *clientCache) updateMetrics() {
+	metrics.RevodCacheEntries.Set(float64(len(cache.entries)))
+	metrics.RevodCacheBytes.Set(float64(cache.bytes))
+}
+
+// configure replaces the cache's settings, emptying it
+func (cache *clientCache) configure(config CacheConfig) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	if cache.ctx == nil {
-		cache.ctx = ctx
+	cache.config = config
+	for _, entry := range cache.entries {
+		cache.remove(entry, evictedSize)
 	}
+	cache.updateMetrics()
 }
 
-func (cache *clientCache) configLogger(logWriter io.Writer, debug bool) {
+// setContext flushes the cache once 'ctx', the client's context, is done
+func (cache *clientCache) setContext(ctx context.Context) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	if cache.logger == nil {
-		cache.debug = debug
-		cache.logWriter = logWriter
-	}
+	cache.ctx = ctx
+}
+
+// configLogger makes the cache log through the client's logger, with a level of its own
+func (cache *clientCache) configLogger(logger log.Logger) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.logger = log.With(logger, "component", "clientCache")
 }
 
+// getDebugLogger is called with the cache locked
 func (cache *clientCache) getDebugLogger() log.Logger {
-	if !cache.isDebugEnabled() {
-		return log.NewNopLogger()
-	}
 	if cache.logger == nil {
-		cache.logger = log.NewLogfmtLogger(cache.logWriter)
-		cache.logger = log.With(level.Debug(cache.logger), "component", "clientCache")
+		return log.NewNopLogger()
 	}
-	return cache.logger
-}
-
-func (cache *clientCache) isDebugEnabled() bool {
-	return cache.debug
+	return level.Debug(cache.logger)
 }
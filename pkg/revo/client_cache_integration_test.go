@@ -70,8 +70,8 @@ func TestCacheWithClient(t *testing.T) {
 	})
 	t.Run("Cache for getblock is flushed after timeout", func(t *testing.T) {
 		logBuffer.Reset()
-		// wait for the cache to be flushed
-		time.Sleep(CACHABLE_METHOD_CACHE_TIMEOUT + time.Millisecond*100)
+		// the block is deep enough to be final
+		advanceCache(client.cache, client.cache.config.FinalTTL)
 		cached_response, err := client.cache.getResponse(test_method, test_params)
 		if err != nil {
 			t.Fatal("No error expected: ", err)
@@ -99,10 +99,9 @@ func TestCacheWithClient(t *testing.T) {
 			t.Errorf("\nexpected: %s\n\n, got: %s", string(test_expectedResult), string(cached_response))
 		}
 	})
-	t.Run("Cache for getblock is flushed again after timeout", func(t *testing.T) {
+	t.Run("Cache for getblock is flushed when the block is reorged", func(t *testing.T) {
 		logBuffer.Reset()
-		// wait for the cache to be flushed
-		time.Sleep(CACHABLE_METHOD_CACHE_TIMEOUT + time.Millisecond*100)
+		client.cache.observe(MethodGetBlockHash, []interface{}{1458070}, []byte(`"ba0d74b5d2f8bd6ba80594b124ecc2771334876a5cd058aafd32123a6177285c"`))
 		cached_response, err := client.cache.getResponse(test_method, test_params)
 		if err != nil {
 			t.Fatal("No error expected: ", err)
@@ -111,7 +110,7 @@ func TestCacheWithClient(t *testing.T) {
 			t.Errorf("\nexpected: nil\n\n, got: %s", string(cached_response))
 		}
 		outputLog := logBuffer.String()
-		if !strings.Contains(outputLog, `msg="flushing cache" reason="cache timeout"`) {
+		if !strings.Contains(outputLog, `msg="flushing cache" reason=reorg`) {
 			t.Errorf("expected log message not found: %s", outputLog)
 		}
 	})
@@ -126,10 +125,10 @@ func TestCacheWithClient(t *testing.T) {
 		SetLogger(logger),
 		SetContext(ctx),
 	)
-	client.URL = revoMockServer.URL
 	if err != nil {
 		t.Fatal(err)
 	}
+	client.URL = revoMockServer.URL
 	t.Run("Cache should be flushed when ctx is canceled", func(t *testing.T) {
 		logBuffer.Reset()
 		err = client.Request(test_method, test_params, &result)
@@ -147,8 +146,6 @@ func TestCacheWithClient(t *testing.T) {
 		}
 
 		canceFunc()
-		// wait for the cache to be flushed after canceling the context
-		time.Sleep(time.Millisecond * 100)
 		cached_response, err = client.cache.getResponse(test_method, test_params)
 		if err != nil {
 			t.Fatal("No error expected: ", err)
@@ -156,8 +153,6 @@ func TestCacheWithClient(t *testing.T) {
 		if !bytes.Equal(cached_response, nil) {
 			t.Errorf("\nexpected: %v\n, got: %s", nil, string(cached_response))
 		}
-		// wait for the 'flushing' go routine to write to the log
-		time.Sleep(time.Millisecond * 100)
 		outputLog := logBuffer.String()
 		if !strings.Contains(outputLog, `msg="flushing cache" reason="context canceled"`) {
 			t.Errorf("expected log message not found: %s", outputLog)
@@ -165,7 +160,8 @@ func TestCacheWithClient(t *testing.T) {
 	})
 
 	// create http client with context.WithTimeout to test cache flushing on cancelation
-	ctx, _ = context.WithTimeout(context.Background(), time.Second*2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
 	client, err = NewClient(
 		true,
 		URL,
@@ -174,10 +170,10 @@ func TestCacheWithClient(t *testing.T) {
 		SetLogger(logger),
 		SetContext(ctx),
 	)
-	client.URL = revoMockServer.URL
 	if err != nil {
 		t.Fatal(err)
 	}
+	client.URL = revoMockServer.URL
 	t.Run("Cache should be flushed when ctx times out", func(t *testing.T) {
 		logBuffer.Reset()
 		err = client.Request(test_method, test_params, &result)
@@ -194,7 +190,7 @@ func TestCacheWithClient(t *testing.T) {
 			t.Errorf("\nexpected: %s\n\n, got: %s\n", string(test_expectedResult), string(cached_response))
 		}
 
-		time.Sleep(time.Second * 3)
+		<-ctx.Done()
 
 		cached_response, err = client.cache.getResponse(test_method, test_params)
 		if err != nil {
@@ -203,14 +199,11 @@ func TestCacheWithClient(t *testing.T) {
 		if !bytes.Equal(cached_response, nil) {
 			t.Errorf("\nexpected: %v\n, got: %s", nil, string(cached_response))
 		}
-		// wait for the 'flushing' go routine to write to the log
-		time.Sleep(time.Millisecond * 200)
 		outputLog := logBuffer.String()
 		if !strings.Contains(outputLog, `msg="flushing cache" reason="context canceled"`) {
 			t.Errorf("expected log message not found: %s", outputLog)
 		}
 	})
-
 }
 
 func NewRevoMockServer(body []byte) *httptest.Server {
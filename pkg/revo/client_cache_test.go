@@ -108,7 +108,7 @@ func TestClientCache(t *testing.T) {
 		}
 	})
 	t.Run("cached response should be flushed after timeout", func(t *testing.T) {
-		time.Sleep(CACHABLE_METHOD_CACHE_TIMEOUT + 5*time.Millisecond)
+		advanceCache(cache, cache.config.FinalTTL)
 		cachedResp, err := cache.getResponse(test_method, test_params)
 		if err != nil {
 			t.Fatal("no error expected")
@@ -130,3 +130,171 @@ func TestClientCache(t *testing.T) {
 	})
 
 }
+
+// advanceCache moves the clock of 'cache' forward by 'by'
+func advanceCache(cache *clientCache, by time.Duration) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := cache.now()
+	cache.now = func() time.Time { return now.Add(by) }
+}
+
+func TestClientCacheTTLs(t *testing.T) {
+	cache := newClientCache()
+	cache.configure(CacheConfig{
+		MaxBytes:      1024 * 1024,
+		TTL:           10 * time.Second,
+		TTLs:          map[string]time.Duration{RevoMethodGettxout: time.Second, RevoMethodGetrawtransaction: time.Minute},
+		FinalTTL:      time.Hour,
+		FinalityDepth: 10,
+	})
+
+	store := func(method string, params interface{}, response string) {
+		if err := cache.storeResponse(method, params, []byte(response)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store(RevoMethodGetblock, []interface{}{"deep"}, `{"hash":"deep","height":1,"confirmations":10}`)
+	store(RevoMethodGetblock, []interface{}{"tip"}, `{"hash":"tip","height":10,"confirmations":1}`)
+	store(RevoMethodGetblock, []interface{}{"hex"}, `"0100"`)
+	store(RevoMethodGetrawtransaction, []interface{}{"mempool", true}, `{"txid":"mempool"}`)
+	store(RevoMethodGetrawtransaction, []interface{}{"confirmed", true}, `{"txid":"confirmed","blockhash":"deep","confirmations":10}`)
+	store(RevoMethodGettxout, []interface{}{"utxo", 0}, `{"value":1}`)
+	store(RevoMethodGethexaddress, []interface{}{"address"}, `"00"`)
+
+	tests := []struct {
+		method string
+		params interface{}
+		ttl    time.Duration
+	}{
+		{RevoMethodGettxout, []interface{}{"utxo", 0}, time.Second},
+		{RevoMethodGetblock, []interface{}{"tip"}, 10 * time.Second},
+		{RevoMethodGetrawtransaction, []interface{}{"mempool", true}, time.Minute},
+		{RevoMethodGetblock, []interface{}{"deep"}, time.Hour},
+		{RevoMethodGetblock, []interface{}{"hex"}, time.Hour},
+		{RevoMethodGetrawtransaction, []interface{}{"confirmed", true}, time.Hour},
+		{RevoMethodGethexaddress, []interface{}{"address"}, time.Hour},
+	}
+	start := cache.now()
+	for i, test := range tests {
+		cache.now = func() time.Time { return start.Add(test.ttl - time.Millisecond) }
+		if response, _ := cache.getResponse(test.method, test.params); response == nil {
+			t.Errorf("%s %v expired before %s", test.method, test.params, test.ttl)
+		}
+		for _, shorter := range tests[:i] {
+			if response, _ := cache.getResponse(shorter.method, shorter.params); response != nil && shorter.ttl < test.ttl {
+				t.Errorf("%s %v not expired after %s", shorter.method, shorter.params, shorter.ttl)
+			}
+		}
+	}
+	cache.now = func() time.Time { return start.Add(time.Hour) }
+	cache.getResponse(RevoMethodGetblock, []interface{}{"deep"})
+	if len(cache.entries) != 0 || cache.expiries.Len() != 0 || cache.lru.Len() != 0 || cache.bytes != 0 {
+		t.Errorf("Expired entries left: %d entries, %d bytes", len(cache.entries), cache.bytes)
+	}
+
+	cache.config.TTLs[RevoMethodGettxout] = 0
+	if cache.isCachable(RevoMethodGettxout) {
+		t.Error("A method with a TTL of 0 is cached")
+	}
+}
+
+func TestClientCacheSkipsMempool(t *testing.T) {
+	cache := newClientCache()
+	for _, includeMempool := range []bool{false, true} {
+		req := GetTransactionOutRequest{Hash: "a", MempoolIncluded: includeMempool}
+		cache.storeResponse(RevoMethodGettxout, req, []byte(`null`))
+		if response, _ := cache.getResponse(RevoMethodGettxout, req); (response != nil) == includeMempool {
+			t.Errorf("Expected gettxout with include_mempool %v to be cached: %v", includeMempool, !includeMempool)
+		}
+	}
+}
+
+func TestClientCacheNextBlock(t *testing.T) {
+	cache := newClientCache()
+	cache.observe(MethodGetBlockCount, nil, []byte(`100`))
+	cache.storeResponse(RevoMethodGetblock, []string{"a100"}, []byte(`{"hash":"a100","height":100,"confirmations":1}`))
+	cache.storeResponse(RevoMethodGettxout, []interface{}{"utxo", 0}, []byte(`{"confirmations":1}`))
+	cache.storeResponse(RevoMethodGetblock, []string{"a90"}, []byte(`{"hash":"a90","height":90,"confirmations":11}`))
+
+	// the same block doesn't change anything
+	cache.observe(MethodGetBlockCount, nil, []byte(`100`))
+	if len(cache.entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(cache.entries))
+	}
+
+	cache.observe(MethodGetBlockCount, nil, []byte(`101`))
+	if response, _ := cache.getResponse(RevoMethodGetblock, []string{"a100"}); response != nil {
+		t.Error("Block with fewer confirmations than the finality depth still cached after the next block")
+	}
+	if response, _ := cache.getResponse(RevoMethodGettxout, []interface{}{"utxo", 0}); response != nil {
+		t.Error("Output still cached after the next block")
+	}
+	if response, _ := cache.getResponse(RevoMethodGetblock, []string{"a90"}); response == nil {
+		t.Error("Final block flushed by the next block")
+	}
+
+	// the next entries are cached again until the following block
+	cache.storeResponse(RevoMethodGetblock, []string{"a100"}, []byte(`{"hash":"a100","height":100,"confirmations":2}`))
+	if response, _ := cache.getResponse(RevoMethodGetblock, []string{"a100"}); string(response) != `{"hash":"a100","height":100,"confirmations":2}` {
+		t.Errorf("Unexpected refreshed block %s", response)
+	}
+	cache.observe(MethodGetBlockChainInfo, nil, []byte(`{"blocks":102,"bestblockhash":"a102"}`))
+	if response, _ := cache.getResponse(RevoMethodGetblock, []string{"a100"}); response != nil {
+		t.Error("Block still cached after getblockchaininfo showed the next block")
+	}
+}
+
+func TestClientCacheEviction(t *testing.T) {
+	cache := newClientCache()
+	config := DefaultCacheConfig()
+	key, _ := cacheKey(RevoMethodGethexaddress, []string{"a"})
+	config.MaxBytes = 3 * (&cacheEntry{key: key, response: []byte(`"0000"`)}).size()
+	cache.configure(config)
+
+	for _, hash := range []string{"a", "b", "c"} {
+		cache.storeResponse(RevoMethodGethexaddress, []string{hash}, []byte(`"0000"`))
+	}
+	// a was used last
+	cache.getResponse(RevoMethodGethexaddress, []string{"a"})
+	cache.storeResponse(RevoMethodGethexaddress, []string{"d"}, []byte(`"0000"`))
+
+	for hash, cached := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if response, _ := cache.getResponse(RevoMethodGethexaddress, []string{hash}); (response != nil) != cached {
+			t.Errorf("%s cached: %v, expected %v", hash, response != nil, cached)
+		}
+	}
+	if cache.bytes > config.MaxBytes {
+		t.Errorf("Cache holds %d bytes, more than %d", cache.bytes, config.MaxBytes)
+	}
+}
+
+func TestClientCacheReorg(t *testing.T) {
+	cache := newClientCache()
+	cache.storeResponse(RevoMethodGetblock, []string{"a100"}, []byte(`{"hash":"a100","height":100,"confirmations":20}`))
+	cache.storeResponse(RevoMethodGetblock, []string{"a101"}, []byte(`{"hash":"a101","height":101,"confirmations":19}`))
+	cache.storeResponse(RevoMethodGetrawtransaction, []interface{}{"tx", true}, []byte(`{"txid":"tx","blockhash":"a101","confirmations":19}`))
+	cache.storeResponse(RevoMethodGetblock, []string{"a99"}, []byte(`{"hash":"a99","height":99,"confirmations":21}`))
+
+	// the same block doesn't change anything
+	cache.observe(MethodGetBlockHash, []int64{100}, []byte(`"a100"`))
+	if len(cache.entries) != 4 {
+		t.Fatalf("Expected 4 entries, got %d", len(cache.entries))
+	}
+
+	cache.observe(MethodGetBlockHash, []int64{100}, []byte(`"b100"`))
+	for _, key := range []interface{}{[]string{"a100"}, []string{"a101"}} {
+		if response, _ := cache.getResponse(RevoMethodGetblock, key); response != nil {
+			t.Errorf("Reorged block %v still cached", key)
+		}
+	}
+	if response, _ := cache.getResponse(RevoMethodGetrawtransaction, []interface{}{"tx", true}); response != nil {
+		t.Error("Transaction of a reorged block still cached")
+	}
+	if response, _ := cache.getResponse(RevoMethodGetblock, []string{"a99"}); response == nil {
+		t.Error("Block below the reorg flushed")
+	}
+	if cache.blocks[100] != "b100" {
+		t.Errorf("Unexpected block at 100: %s", cache.blocks[100])
+	}
+}
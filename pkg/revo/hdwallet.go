@@ -0,0 +1,101 @@
+package revo
+
+import (
+	"crypto/sha512"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultDerivationPath is the BIP44 Ethereum path Hardhat and Ganache derive their accounts from,
+// the account index is appended to it
+const DefaultDerivationPath = "m/44'/60'/0'/0"
+
+// MnemonicToSeed computes the BIP39 seed of 'mnemonic'.
+// The mnemonic checksum isn't verified, charon doesn't ship the BIP39 word lists, only the number of words is checked
+func MnemonicToSeed(mnemonic string, passphrase string) ([]byte, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, errors.Errorf("a mnemonic has 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(strings.Join(words, " ")), []byte(salt), 2048, 64, sha512.New), nil
+}
+
+// ParseDerivationPath parses a BIP32 path like "m/44'/60'/0'/0", hardened indexes end with ' or h
+func ParseDerivationPath(path string) ([]uint32, error) {
+	components := strings.Split(strings.TrimSpace(path), "/")
+	if len(components) == 0 || components[0] != "m" {
+		return nil, errors.Errorf("derivation path %q must start with m/", path)
+	}
+
+	indexes := make([]uint32, 0, len(components)-1)
+	for _, component := range components[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(component, "'") || strings.HasSuffix(component, "h") {
+			offset = hdkeychain.HardenedKeyStart
+			component = component[:len(component)-1]
+		}
+
+		index, err := strconv.ParseUint(component, 10, 31)
+		if err != nil {
+			return nil, errors.Errorf("invalid derivation path component %q in %q", component, path)
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+
+	return indexes, nil
+}
+
+// DeriveAccounts derives 'count' accounts from 'mnemonic', the keys at 'path'/0 to 'path'/'count'-1.
+// With DefaultDerivationPath they match the accounts Hardhat and Ganache derive from the same mnemonic
+func DeriveAccounts(mnemonic string, passphrase string, path string, count int) (Accounts, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	// the network only matters for serializing extended keys, which we never do
+	parent, err := hdkeychain.NewMaster(seed, &revoMainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		if parent, err = parent.Derive(index); err != nil {
+			return nil, errors.Wrapf(err, "couldn't derive %s", path)
+		}
+	}
+
+	accounts := make(Accounts, 0, count)
+	for i := 0; i < count; i++ {
+		child, err := parent.Derive(uint32(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't derive %s/%d", path, i)
+		}
+		key, err := child.ECPrivKey()
+		if err != nil {
+			return nil, err
+		}
+		// Revo uses compressed public keys
+		wif, err := btcutil.NewWIF(key, &revoMainNetParams, true)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, wif)
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,57 @@
+package revo
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveAccountsMatchesHardhat(t *testing.T) {
+	// Hardhat's default accounts
+	accounts, err := DeriveAccounts("test test test test test test test test test test test junk", "", DefaultDerivationPath, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(accounts))
+	}
+
+	for i, expected := range []string{
+		"ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+		"59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+	} {
+		if got := hex.EncodeToString(paddedKeyBytes(accounts[i].PrivKey)); got != expected {
+			t.Errorf("account %d: expected private key %s, got %s", i, expected, got)
+		}
+		if !accounts[i].CompressPubKey {
+			t.Errorf("account %d: expected a compressed public key", i)
+		}
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	indexes, err := ParseDerivationPath("m/44'/60h/0'/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint32{0x8000002c, 0x8000003c, 0x80000000, 0}
+	if len(indexes) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, indexes)
+	}
+	for i := range expected {
+		if indexes[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, indexes)
+		}
+	}
+
+	for _, invalid := range []string{"44'/60'", "m/x", "m/2147483648"} {
+		if _, err := ParseDerivationPath(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestMnemonicToSeedChecksWordCount(t *testing.T) {
+	if _, err := MnemonicToSeed("test test test", ""); err == nil {
+		t.Error("expected a 3 word mnemonic to be rejected")
+	}
+}
@@ -0,0 +1,72 @@
+package revo
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// inflight coalesces identical revod calls made at the same time, the callers asking for a call already being made
+// wait for its result instead of making it again
+type inflight struct {
+	mutex sync.Mutex
+	calls map[string]*inflightCall
+}
+
+type inflightCall struct {
+	done   chan struct{}
+	result json.RawMessage
+	err    error
+	// the session the call was made for, if any
+	session *Session
+}
+
+func newInflight() *inflight {
+	return &inflight{calls: make(map[string]*inflightCall)}
+}
+
+// do returns the result of 'call' for 'key', made once for all the callers asking for 'key' until it returns.
+// 'call' runs on its own so that a caller giving up on 'ctx' doesn't stop it for the others, 'shared' tells whether
+// the caller waited for a call made for another one. The session of each caller sees what the session 'call' made
+// its call for saw
+func (f *inflight) do(ctx context.Context, key string, call func() (json.RawMessage, *Session, error)) (result json.RawMessage, shared bool, err error) {
+	f.mutex.Lock()
+	c, shared := f.calls[key]
+	if !shared {
+		c = &inflightCall{done: make(chan struct{})}
+		f.calls[key] = c
+		go func() {
+			c.result, c.session, c.err = call()
+			f.mutex.Lock()
+			delete(f.calls, key)
+			f.mutex.Unlock()
+			close(c.done)
+		}()
+	}
+	f.mutex.Unlock()
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case <-c.done:
+		if session := sessionFromContext(ctx); session != nil && c.session != nil {
+			session.observe(c.session.Height())
+		}
+		return c.result, shared, c.err
+	case <-done:
+		return nil, shared, ctx.Err()
+	}
+}
+
+// sharedContext is the context of a call made for several callers, it carries the values of the caller which made
+// it, such as its trace, but is only cancelled with the client's context
+type sharedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c sharedContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
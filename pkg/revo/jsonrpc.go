@@ -80,6 +80,36 @@ func (err *JSONRPCError) Error() string {
 	return fmt.Sprintf("revo [code: %d] %s", err.Code, err.Message)
 }
 
+// RPCError returns the code and message revod returned
+func (err *JSONRPCError) RPCError() (int, string) {
+	return err.Code, err.Message
+}
+
+// KnownError is a revod error associated with one of the known errors below, it keeps the code and message revod
+// returned. Compare it to the known errors with errors.Is or errors.Cause
+type KnownError struct {
+	known    error
+	original *JSONRPCError
+}
+
+func (err *KnownError) Error() string {
+	return err.known.Error()
+}
+
+func (err *KnownError) Unwrap() error {
+	return err.known
+}
+
+// Cause is for github.com/pkg/errors.Cause
+func (err *KnownError) Cause() error {
+	return err.known
+}
+
+// RPCError returns the code and message revod returned
+func (err *KnownError) RPCError() (int, string) {
+	return err.original.RPCError()
+}
+
 // Tries to associate returned error with one of already known (implemented) errors,
 // in which we may be interesting. If returned error is unknown, returns original
 // error value
@@ -88,22 +118,31 @@ func (err *JSONRPCError) TryGetKnownError() error {
 	if knownError == nil {
 		return err
 	}
-	return knownError
+	return &KnownError{known: knownError, original: err}
 }
 
 func IsKnownError(err error) bool {
-	_, contains := errorToCodeMap[err]
+	_, contains := errorToCodeMap[knownErrorCause(err)]
 	return contains
 }
 
 func GetErrorCode(err error) int {
-	errorCode, contains := errorToCodeMap[err]
+	errorCode, contains := errorToCodeMap[knownErrorCause(err)]
 	if !contains {
 		return 0
 	}
 	return errorCode
 }
 
+// knownErrorCause returns the known error a *KnownError stands for
+func knownErrorCause(err error) error {
+	var known *KnownError
+	if errors.As(err, &known) {
+		return known.known
+	}
+	return err
+}
+
 func GetErrorResponse(err error) eth.JSONRPCError {
 	errorCode := GetErrorCode(err)
 	if errorCode == 0 {
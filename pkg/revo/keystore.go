@@ -0,0 +1,390 @@
+package revo
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+var (
+	ErrAccountLocked  = errors.New("authentication needed: password or unlock")
+	ErrUnknownAccount = errors.New("unknown account")
+	ErrAccountExists  = errors.New("account already exists")
+	ErrNoKeyStoreDir  = errors.New("no key store directory configured")
+)
+
+// keyStoreEntry is an encrypted hosted key, either keystore V3 JSON or a BIP38 encrypted WIF
+type keyStoreEntry struct {
+	// Revo hex address, empty until the key is first decrypted if it can't be known before that
+	address string
+	// false while 'address' is only what the key file claims
+	verified bool
+	// address stored in a key file written by an Ethereum tool, the key can be unlocked with it
+	alias string
+
+	keyJSON []byte
+	bip38   string
+	path    string
+
+	// set while unlocked
+	wif           *btcutil.WIF
+	unlockedUntil time.Time
+}
+
+func (e *keyStoreEntry) decrypt(passphrase string) (*btcutil.WIF, error) {
+	if e.bip38 != "" {
+		return DecryptBIP38(e.bip38, passphrase)
+	}
+
+	key, err := DecryptKeyV3(e.keyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	// Revo uses compressed public keys, the network only matters for WIF.String()
+	return btcutil.NewWIF(key, &revoMainNetParams, true)
+}
+
+// mayBe reports whether the entry could hold the key for 'address' without decrypting it
+func (e *keyStoreEntry) mayBe(address string) bool {
+	if e.address == address || (e.alias != "" && e.alias == address) {
+		return true
+	}
+	if !e.verified && e.bip38 != "" {
+		return bip38MatchesAddress(e.bip38, address)
+	}
+	return false
+}
+
+func (e *keyStoreEntry) isUnlocked(now time.Time) bool {
+	if e.wif == nil {
+		return false
+	}
+	if !e.unlockedUntil.IsZero() && now.After(e.unlockedUntil) {
+		e.lock()
+		return false
+	}
+	return true
+}
+
+func (e *keyStoreEntry) lock() {
+	e.wif = nil
+	e.unlockedUntil = time.Time{}
+}
+
+// KeyStore holds passphrase-encrypted hosted keys, they can only be used for signing while unlocked
+type KeyStore struct {
+	mutex   sync.Mutex
+	dir     string
+	entries []*keyStoreEntry
+	now     func() time.Time
+
+	// scrypt parameters for new key files
+	scryptN int
+	scryptP int
+}
+
+// NewKeyStore loads every keystore V3 JSON file in 'dir', 'dir' may be empty for a key store that only holds BIP38 keys
+func NewKeyStore(dir string) (*KeyStore, error) {
+	ks := &KeyStore{
+		dir:     dir,
+		now:     time.Now,
+		scryptN: StandardScryptN,
+		scryptP: StandardScryptP,
+	}
+
+	if dir == "" {
+		return ks, nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ks, nil
+		}
+		return nil, errors.Wrapf(err, "couldn't read key store %s", dir)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, file.Name())
+		keyJSON, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't read key file %s", path)
+		}
+		if err := ks.AddKeyJSON(keyJSON, path); err != nil {
+			return nil, errors.Wrapf(err, "couldn't load key file %s", path)
+		}
+	}
+
+	return ks, nil
+}
+
+// SetLightKDF makes new key files cheaper to decrypt, and therefore to brute force, at the cost of security
+func (ks *KeyStore) SetLightKDF(light bool) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	if light {
+		ks.scryptN, ks.scryptP = LightScryptN, LightScryptP
+	} else {
+		ks.scryptN, ks.scryptP = StandardScryptN, StandardScryptP
+	}
+}
+
+// NewAccount generates a key, stores it encrypted with 'passphrase' in the key store directory and returns its hex address.
+// The new account starts locked
+func (ks *KeyStore) NewAccount(passphrase string) (string, error) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return "", err
+	}
+	return ks.Import(key, passphrase)
+}
+
+// Import stores 'key' encrypted with 'passphrase' in the key store directory and returns its hex address.
+// The imported account starts locked
+func (ks *KeyStore) Import(key *btcec.PrivateKey, passphrase string) (string, error) {
+	if ks == nil || ks.dir == "" {
+		return "", ErrNoKeyStoreDir
+	}
+
+	wif, err := btcutil.NewWIF(key, &revoMainNetParams, true)
+	if err != nil {
+		return "", err
+	}
+	address := (&Account{wif}).ToHexAddress()
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	for _, entry := range ks.entries {
+		if entry.address == address {
+			return "", ErrAccountExists
+		}
+	}
+
+	keyJSON, err := EncryptKeyV3(key, address, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return "", errors.Wrapf(err, "couldn't create key store %s", ks.dir)
+	}
+	// same naming scheme as geth
+	now := ks.now().UTC()
+	name := fmt.Sprintf("UTC--%s--%s", now.Format("2006-01-02T15-04-05.000000000Z"), address)
+	path := filepath.Join(ks.dir, name)
+	if err := writeKeyFile(path, keyJSON); err != nil {
+		return "", err
+	}
+
+	ks.entries = append(ks.entries, &keyStoreEntry{
+		address:  address,
+		verified: true,
+		keyJSON:  keyJSON,
+		path:     path,
+	})
+
+	return address, nil
+}
+
+// writeKeyFile writes 'keyJSON' readable only by the current user, through a temporary file so a crash never leaves a partial key file
+func writeKeyFile(path string, keyJSON []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "couldn't write key file")
+	}
+	if _, err := tmp.Write(keyJSON); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "couldn't write key file")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "couldn't write key file")
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// AddKeyJSON adds keystore V3 JSON, 'path' is the file it was read from. Key files written by Ethereum tools store the
+// Ethereum address of the key, they are unlocked with it and only listed under their Revo hex address from then on
+func (ks *KeyStore) AddKeyJSON(keyJSON []byte, path string) error {
+	address, revoAddress, err := keyV3Address(keyJSON)
+	if err != nil {
+		return err
+	}
+
+	entry := &keyStoreEntry{
+		keyJSON: keyJSON,
+		path:    path,
+	}
+	if revoAddress {
+		entry.address = normalizeHexAddress(address)
+	} else {
+		entry.alias = normalizeHexAddress(address)
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	ks.entries = append(ks.entries, entry)
+	return nil
+}
+
+// AddBIP38 adds a BIP38 passphrase-encrypted WIF
+func (ks *KeyStore) AddBIP38(encrypted string) error {
+	if _, err := decodeBIP38(encrypted); err != nil {
+		return err
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	for _, entry := range ks.entries {
+		if entry.bip38 == encrypted {
+			// added again when the accounts are reloaded
+			return nil
+		}
+	}
+	ks.entries = append(ks.entries, &keyStoreEntry{bip38: encrypted})
+	return nil
+}
+
+// Unlock decrypts the key for 'address' and keeps it usable for 'duration', or until Lock is called if 'duration' is zero.
+// Decrypting is slow on purpose, so it is done without holding the key store lock
+func (ks *KeyStore) Unlock(address string, passphrase string, duration time.Duration) error {
+	if ks == nil {
+		return ErrUnknownAccount
+	}
+
+	address = normalizeHexAddress(address)
+
+	ks.mutex.Lock()
+	var candidates []*keyStoreEntry
+	for _, entry := range ks.entries {
+		if entry.mayBe(address) {
+			candidates = append(candidates, entry)
+		}
+	}
+	ks.mutex.Unlock()
+
+	for _, entry := range candidates {
+		// the encrypted key of an entry never changes
+		wif, err := entry.decrypt(passphrase)
+		if err != nil {
+			if err == ErrInvalidPassphrase {
+				continue
+			}
+			return err
+		}
+
+		derived := (&Account{wif}).ToHexAddress()
+
+		ks.mutex.Lock()
+		if !entry.verified {
+			// now we know which address the key is for
+			entry.address = derived
+			entry.verified = true
+		}
+		if derived != address && entry.alias != address {
+			ks.mutex.Unlock()
+			continue
+		}
+		entry.wif = wif
+		entry.unlockedUntil = time.Time{}
+		if duration > 0 {
+			entry.unlockedUntil = ks.now().Add(duration)
+		}
+		ks.mutex.Unlock()
+		return nil
+	}
+
+	if len(candidates) > 0 {
+		return ErrInvalidPassphrase
+	}
+	return ErrUnknownAccount
+}
+
+// Lock forgets the decrypted key for 'address'
+func (ks *KeyStore) Lock(address string) error {
+	if ks == nil {
+		return ErrUnknownAccount
+	}
+
+	address = normalizeHexAddress(address)
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	for _, entry := range ks.entries {
+		if entry.address == address || (entry.alias != "" && entry.alias == address) {
+			entry.lock()
+			return nil
+		}
+	}
+	return ErrUnknownAccount
+}
+
+// Find returns the decrypted key for 'address', ErrAccountLocked if it is locked and ErrUnknownAccount if it isn't in the key store
+func (ks *KeyStore) Find(address string) (*btcutil.WIF, error) {
+	if ks == nil {
+		return nil, ErrUnknownAccount
+	}
+
+	address = normalizeHexAddress(address)
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	for _, entry := range ks.entries {
+		if entry.address != address {
+			continue
+		}
+		if entry.isUnlocked(ks.now()) {
+			return entry.wif, nil
+		}
+		return nil, ErrAccountLocked
+	}
+	return nil, ErrUnknownAccount
+}
+
+// Addresses returns the Revo hex addresses of the keys whose address is known, locked or not
+func (ks *KeyStore) Addresses() []string {
+	if ks == nil {
+		return nil
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	addresses := make([]string, 0, len(ks.entries))
+	for _, entry := range ks.entries {
+		if entry.address != "" {
+			addresses = append(addresses, entry.address)
+		}
+	}
+	return addresses
+}
+
+func normalizeHexAddress(address string) string {
+	return strings.ToLower(utils.RemoveHexPrefix(address))
+}
+
+func hexToPubKeyHash(address string) ([]byte, error) {
+	pubKeyHash, err := hex.DecodeString(normalizeHexAddress(address))
+	if err != nil {
+		return nil, err
+	}
+	if len(pubKeyHash) != 20 {
+		return nil, errors.Errorf("invalid address length %d", len(pubKeyHash))
+	}
+	return pubKeyHash, nil
+}
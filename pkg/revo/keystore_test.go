@@ -0,0 +1,243 @@
+package revo
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+)
+
+func TestDecryptKeyV3Pbkdf2(t *testing.T) {
+	// test vector from the Web3 Secret Storage definition
+	keyJSON := []byte(`{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf": "pbkdf2",
+			"kdfparams": {"c": 262144, "dklen": 32, "prf": "hmac-sha256", "salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},
+			"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`)
+
+	key, err := DecryptKeyV3(keyJSON, "testpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(paddedKeyBytes(key)); got != "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d" {
+		t.Errorf("unexpected private key %s", got)
+	}
+
+	if _, err := DecryptKeyV3(keyJSON, "wrong"); err != ErrInvalidPassphrase {
+		t.Errorf("expected %v, got %v", ErrInvalidPassphrase, err)
+	}
+}
+
+func TestKeyStoreUnlocksKeyFile(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testTxBuilderWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := (&Account{wif}).ToHexAddress()
+
+	keyJSON, err := EncryptKeyV3(wif.PrivKey, address, "passphrase", LightScryptN, LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.json"), keyJSON, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := NewKeyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+
+	if addresses := ks.Addresses(); len(addresses) != 1 || addresses[0] != address {
+		t.Fatalf("unexpected addresses %v", addresses)
+	}
+	if _, err := ks.Find(address); err != ErrAccountLocked {
+		t.Fatalf("expected %v, got %v", ErrAccountLocked, err)
+	}
+	if err := ks.Unlock(address, "wrong", time.Minute); err != ErrInvalidPassphrase {
+		t.Fatalf("expected %v, got %v", ErrInvalidPassphrase, err)
+	}
+	if err := ks.Unlock("0x"+address, "passphrase", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	unlocked, err := ks.Find(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(paddedKeyBytes(unlocked.PrivKey)) != hex.EncodeToString(paddedKeyBytes(wif.PrivKey)) {
+		t.Errorf("unlocked the wrong key")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := ks.Find(address); err != ErrAccountLocked {
+		t.Errorf("expected the account to lock again after the unlock duration, got %v", err)
+	}
+}
+
+func TestKeyStoreUnlocksBIP38(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testTxBuilderWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := (&Account{wif}).ToHexAddress()
+
+	encrypted, err := EncryptBIP38(wif, false, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBIP38(encrypted) {
+		t.Fatalf("%s isn't recognized as BIP38", encrypted)
+	}
+
+	ks, err := NewKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.AddBIP38(encrypted); err != nil {
+		t.Fatal(err)
+	}
+	// reloading the accounts adds it again
+	if err := ks.AddBIP38(encrypted); err != nil {
+		t.Fatal(err)
+	}
+	if len(ks.entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(ks.entries))
+	}
+
+	// the address of a BIP38 key isn't known until it is decrypted
+	if _, err := ks.Find(address); err != ErrUnknownAccount {
+		t.Fatalf("expected %v, got %v", ErrUnknownAccount, err)
+	}
+	if err := ks.Unlock("7e22630f90e6db16283af2c6b04f688117a55db4", "passphrase", 0); err != ErrUnknownAccount {
+		t.Fatalf("expected %v, got %v", ErrUnknownAccount, err)
+	}
+	if err := ks.Unlock(address, "passphrase", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Find(address); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ks.Lock(address); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Find(address); err != ErrAccountLocked {
+		t.Errorf("expected %v, got %v", ErrAccountLocked, err)
+	}
+}
+
+func TestKeyStoreUsableWhileUnlocking(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testTxBuilderWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := (&Account{wif}).ToHexAddress()
+
+	ks, err := NewKeyStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.SetLightKDF(true)
+	if _, err := ks.Import(wif.PrivKey, "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		if err := ks.Unlock(address, "wrong", 0); err != ErrInvalidPassphrase {
+			done <- err
+			return
+		}
+		done <- ks.Unlock(address, "passphrase", 0)
+	}()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ks.Find(address); err != nil {
+				t.Fatal(err)
+			}
+			return
+		default:
+			ks.Addresses()
+			ks.Find(address)
+		}
+	}
+}
+
+func TestKeyStoreUnlocksEthereumKeyFile(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testTxBuilderWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := (&Account{wif}).ToHexAddress()
+	ethAddress := hex.EncodeToString(keccak256(wif.PrivKey.PubKey().SerializeUncompressed()[1:])[12:])
+
+	// as written by geth
+	keyJSON, err := EncryptKeyV3(wif.PrivKey, address, "passphrase", LightScryptN, LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyFile map[string]interface{}
+	if err := json.Unmarshal(keyJSON, &keyFile); err != nil {
+		t.Fatal(err)
+	}
+	keyFile["address"] = ethAddress
+	delete(keyFile, "addressType")
+	if keyJSON, err = json.Marshal(keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := NewKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.AddKeyJSON(keyJSON, "key.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	// charon can't sign for the Ethereum address
+	if addresses := ks.Addresses(); len(addresses) != 0 {
+		t.Fatalf("expected no address before the key is unlocked, got %v", addresses)
+	}
+	if err := ks.Unlock(address, "passphrase", 0); err != ErrUnknownAccount {
+		t.Fatalf("expected %v, got %v", ErrUnknownAccount, err)
+	}
+	if err := ks.Unlock(ethAddress, "wrong", 0); err != ErrInvalidPassphrase {
+		t.Fatalf("expected %v, got %v", ErrInvalidPassphrase, err)
+	}
+	if err := ks.Unlock("0x"+ethAddress, "passphrase", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if addresses := ks.Addresses(); len(addresses) != 1 || addresses[0] != address {
+		t.Fatalf("unexpected addresses %v", addresses)
+	}
+	if _, err := ks.Find(address); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Lock(address); err != nil {
+		t.Fatal(err)
+	}
+	// its Revo hex address is known from now on
+	if err := ks.Unlock(address, "passphrase", 0); err != nil {
+		t.Fatal(err)
+	}
+}
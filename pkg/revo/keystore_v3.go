@@ -0,0 +1,221 @@
+package revo
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+// scrypt parameters used by geth, StandardScrypt* for key files written to disk
+const (
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12
+	LightScryptP    = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+var ErrInvalidPassphrase = errors.New("could not decrypt key with given password")
+
+// keyV3RevoAddress marks the key files whose address is a Revo hex address, other tools store the Ethereum address
+// of the key
+const keyV3RevoAddress = "revo"
+
+// encryptedKeyJSONV3 is the Web3 Secret Storage (keystore V3) format
+type encryptedKeyJSONV3 struct {
+	Address     string     `json:"address"`
+	AddressType string     `json:"addressType,omitempty"`
+	Crypto      cryptoJSON `json:"crypto"`
+	ID          string     `json:"id"`
+	Version     int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams cipherParamsJSON       `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type cipherParamsJSON struct {
+	IV string `json:"iv"`
+}
+
+// EncryptKeyV3 encrypts 'key' into keystore V3 JSON. 'address' is the Revo hex address stored unencrypted in the file,
+// so the account can be listed before it is unlocked
+func EncryptKeyV3(key *btcec.PrivateKey, address string, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	id := make([]byte, 16)
+	for _, buf := range [][]byte{salt, iv, id} {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherText, err := aesCTRXOR(derivedKey[:16], paddedKeyBytes(key), iv)
+	if err != nil {
+		return nil, err
+	}
+
+	// random (version 4) UUID
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+
+	return json.Marshal(encryptedKeyJSONV3{
+		Address:     address,
+		AddressType: keyV3RevoAddress,
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParamsJSON{IV: hex.EncodeToString(iv)},
+			KDF:          "scrypt",
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     scryptR,
+				"p":     scryptP,
+				"dklen": scryptDKLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(keccak256(derivedKey[16:32], cipherText)),
+		},
+		ID:      fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Version: 3,
+	})
+}
+
+// DecryptKeyV3 decrypts keystore V3 JSON written by charon, geth or any other Web3 Secret Storage implementation
+func DecryptKeyV3(keyJSON []byte, passphrase string) (*btcec.PrivateKey, error) {
+	var k encryptedKeyJSONV3
+	if err := json.Unmarshal(keyJSON, &k); err != nil {
+		return nil, errors.Wrap(err, "invalid key file")
+	}
+	if k.Version != 3 {
+		return nil, errors.Errorf("unsupported key file version %d", k.Version)
+	}
+	if k.Crypto.Cipher != "aes-128-ctr" {
+		return nil, errors.Errorf("unsupported cipher %s", k.Crypto.Cipher)
+	}
+
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mac")
+	}
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid iv")
+	}
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid ciphertext")
+	}
+
+	derivedKey, err := deriveKeyV3(k.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrInvalidPassphrase
+	}
+
+	plainText, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), plainText)
+	return key, nil
+}
+
+// keyV3Address returns the address stored unencrypted in keystore V3 JSON and whether it is a Revo hex address
+func keyV3Address(keyJSON []byte) (string, bool, error) {
+	var k encryptedKeyJSONV3
+	if err := json.Unmarshal(keyJSON, &k); err != nil {
+		return "", false, errors.Wrap(err, "invalid key file")
+	}
+	if k.Version != 3 {
+		return "", false, errors.Errorf("unsupported key file version %d", k.Version)
+	}
+	return k.Address, k.AddressType == keyV3RevoAddress, nil
+}
+
+func deriveKeyV3(c cryptoJSON, passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(kdfParamString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid salt")
+	}
+	dkLen := kdfParamInt(c.KDFParams, "dklen")
+	if dkLen < 32 {
+		return nil, errors.Errorf("invalid dklen %d", dkLen)
+	}
+
+	switch c.KDF {
+	case "scrypt":
+		return scrypt.Key([]byte(passphrase), salt, kdfParamInt(c.KDFParams, "n"), kdfParamInt(c.KDFParams, "r"), kdfParamInt(c.KDFParams, "p"), dkLen)
+	case "pbkdf2":
+		if prf := kdfParamString(c.KDFParams, "prf"); prf != "hmac-sha256" {
+			return nil, errors.Errorf("unsupported pbkdf2 prf %s", prf)
+		}
+		return pbkdf2.Key([]byte(passphrase), salt, kdfParamInt(c.KDFParams, "c"), dkLen, sha256.New), nil
+	default:
+		return nil, errors.Errorf("unsupported kdf %s", c.KDF)
+	}
+}
+
+func kdfParamInt(params map[string]interface{}, name string) int {
+	// numbers decode as float64
+	value, _ := params[name].(float64)
+	return int(value)
+}
+
+func kdfParamString(params map[string]interface{}, name string) string {
+	value, _ := params[name].(string)
+	return value
+}
+
+func aesCTRXOR(key, in, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		hash.Write(d)
+	}
+	return hash.Sum(nil)
+}
+
+// paddedKeyBytes returns the 32 byte big endian private key
+func paddedKeyBytes(key *btcec.PrivateKey) []byte {
+	keyBytes := key.D.Bytes()
+	padded := make([]byte, 32)
+	copy(padded[32-len(keyBytes):], keyBytes)
+	return padded
+}
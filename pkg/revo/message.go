@@ -0,0 +1,51 @@
+package revo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+var revoSignMessagePrefix = []byte("\u0015Revo Signed Message:\n")
+
+// SignMessage signs 'msg' prefixed with Revo's signed message header, returning a compact recoverable signature
+func SignMessage(key *btcec.PrivateKey, msg []byte) ([]byte, error) {
+	return btcec.SignCompact(btcec.S256(), key, MessageHash(msg), true)
+}
+
+// MessageHash returns the hash signed by SignMessage
+func MessageHash(msg []byte) []byte {
+	return chainhash.DoubleHashB(paddedMessage(msg))
+}
+
+// RecoverCompactAddress returns the hex address of the key that made the compact signature 'sig' of 'hash'
+func RecoverCompactAddress(sig []byte, hash []byte) (string, error) {
+	pubKey, compressed, err := btcec.RecoverCompact(btcec.S256(), sig, hash)
+	if err != nil {
+		return "", err
+	}
+
+	serialized := pubKey.SerializeUncompressed()
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	}
+	return hex.EncodeToString(btcutil.Hash160(serialized)), nil
+}
+
+func paddedMessage(msg []byte) []byte {
+	var wbuf bytes.Buffer
+
+	wbuf.Write(revoSignMessagePrefix)
+
+	var msglenbuf [binary.MaxVarintLen64]byte
+	msglen := binary.PutUvarint(msglenbuf[:], uint64(len(msg)))
+
+	wbuf.Write(msglenbuf[:msglen])
+	wbuf.Write(msg)
+
+	return wbuf.Bytes()
+}
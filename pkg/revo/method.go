@@ -247,12 +247,13 @@ func (m *Method) Generate(ctx context.Context, blockNum int, maxTries *int) (res
 	generateToAccount := m.GetFlagString(FLAG_GENERATE_ADDRESS_TO)
 	var qAddress string
 
-	if len(m.Accounts) == 0 && generateToAccount == nil {
+	accounts := m.GetAccounts()
+	if len(accounts) == 0 && generateToAccount == nil {
 		// return nil, errors.New("you must specify REVO accounts")
 		qAddress = "qW28njWueNpBXYWj2KDmtFG2gbLeALeHfV"
 	} else {
 		if generateToAccount == nil {
-			acc := Account{m.Accounts[0]}
+			acc := Account{accounts[0]}
 
 			qAddress, err = acc.ToBase58Address(m.isMain)
 			if err != nil {
@@ -48,7 +48,7 @@ func New(c *Client, chain string) (*Revo, error) {
 	}
 
 	c.SetErrorHandler(func(ctx context.Context, err error) error {
-		if errorHandler, ok := errorHandlers[err]; ok {
+		if errorHandler, ok := errorHandlers[errors.Cause(err)]; ok {
 			return errorHandler(ctx, revo, revo.errorState, revo.Method)
 		}
 		return nil
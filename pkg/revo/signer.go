@@ -0,0 +1,399 @@
+package revo
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/btcd/txscript"
+	"github.com/revolutionchain/btcd/wire"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+// JSON-RPC methods of an external signer, named after geth's Clef API
+const (
+	SignerMethodList            = "account_list"
+	SignerMethodSignData        = "account_signData"
+	SignerMethodSignTransaction = "account_signTransaction"
+
+	// account_signData content type of messages signed like eth_sign, with Revo's signed message header
+	SignerContentTypeRevoMessage = "text/x-revo-message"
+)
+
+const DefaultSignerTimeout = 30 * time.Second
+
+// how long the accounts listed by a signer are used before they are listed again
+const signerAccountsTTL = 10 * time.Second
+
+// Signer signs for accounts whose keys are held outside of charon
+type Signer interface {
+	// Accounts returns the hex addresses the signer signs for
+	Accounts(ctx context.Context) ([]string, error)
+	// SignMessage returns the compact signature of 'message' by the key of the hex address 'address', see SignMessage
+	SignMessage(ctx context.Context, address string, message []byte) ([]byte, error)
+	// SignTransaction returns the transaction built by an unsigned TxBuilder signed by the key of 'tx.From'
+	SignTransaction(ctx context.Context, tx *SignerTransaction) (*wire.MsgTx, error)
+}
+
+// signerAccounts keeps the accounts listed by a signer for signerAccountsTTL, as they are looked up for every
+// transaction and message signed
+type signerAccounts struct {
+	mutex     sync.Mutex
+	addresses []string
+	expires   time.Time
+}
+
+// get returns the accounts 'signer' signs for, listing them again once they expired. A failed listing isn't kept
+func (a *signerAccounts) get(ctx context.Context, signer Signer) ([]string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if time.Now().Before(a.expires) {
+		return a.addresses, nil
+	}
+
+	addresses, err := signer.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.addresses, a.expires = addresses, time.Now().Add(signerAccountsTTL)
+	return addresses, nil
+}
+
+// SignerTransaction is the account_signTransaction parameter. Alongside the Ethereum transaction fields (for the signer
+// to check what it is signing) it holds the Revo transaction built by charon, left unsigned, and the outputs it spends
+type SignerTransaction struct {
+	From     string `json:"from"`
+	To       string `json:"to,omitempty"`
+	Gas      string `json:"gas,omitempty"`
+	GasPrice string `json:"gasPrice,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Data     string `json:"data,omitempty"`
+
+	UnsignedTx string        `json:"unsignedTx"`
+	Inputs     []SignerInput `json:"inputs"`
+}
+
+// SignerInput is an output spent by a SignerTransaction
+type SignerInput struct {
+	TxID   string `json:"txid"`
+	Vout   uint32 `json:"vout"`
+	Value  int64  `json:"value"`
+	Script string `json:"script"`
+}
+
+// NewSignerTransaction returns the account_signTransaction parameter for the transaction built by 'builder'
+func NewSignerTransaction(builder *TxBuilder) (*SignerTransaction, error) {
+	unsigned, err := builder.Unsigned()
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := SerializeTx(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &SignerTransaction{
+		From:       utils.AddHexPrefix(hex.EncodeToString(builder.SenderPubKeyHash())),
+		UnsignedTx: utils.AddHexPrefix(rawTx),
+	}
+	for _, input := range builder.inputs {
+		tx.Inputs = append(tx.Inputs, SignerInput{
+			TxID:   input.TxID,
+			Vout:   input.Vout,
+			Value:  input.Satoshis,
+			Script: hex.EncodeToString(input.PkScript),
+		})
+	}
+
+	return tx, nil
+}
+
+// SignerTransactionResult is the account_signTransaction result
+type SignerTransactionResult struct {
+	Raw string `json:"raw"`
+}
+
+// SignerError is a JSON-RPC error returned by an external signer, for example when it refuses to sign
+type SignerError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *SignerError) Error() string {
+	return fmt.Sprintf("external signer: %s (code %d)", e.Message, e.Code)
+}
+
+type signerRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type signerResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *SignerError    `json:"error"`
+}
+
+// ExternalSigner is a Signer that sends signing requests to a signer daemon over HTTP, or over a unix socket
+// with one JSON-RPC request per connection like geth's IPC
+type ExternalSigner struct {
+	endpoint string
+	socket   string
+	client   *http.Client
+	timeout  time.Duration
+	id       uint64
+}
+
+var _ Signer = (*ExternalSigner)(nil)
+
+// NewExternalSigner connects to the signer at 'endpoint', an http(s):// URL, a unix:// URL or a unix socket path
+func NewExternalSigner(endpoint string, timeout time.Duration) (*ExternalSigner, error) {
+	if timeout <= 0 {
+		timeout = DefaultSignerTimeout
+	}
+
+	s := &ExternalSigner{
+		endpoint: endpoint,
+		timeout:  timeout,
+	}
+
+	u, err := url.Parse(endpoint)
+	switch {
+	case err == nil && (u.Scheme == "http" || u.Scheme == "https"):
+		s.client = &http.Client{Timeout: timeout}
+	case err == nil && u.Scheme == "unix":
+		s.socket = u.Path
+	case strings.HasPrefix(endpoint, "/") || strings.HasPrefix(endpoint, "."):
+		s.socket = endpoint
+	default:
+		return nil, errors.Errorf("external signer endpoint %q must be an http(s):// URL or a unix socket", endpoint)
+	}
+
+	return s, nil
+}
+
+func (s *ExternalSigner) Accounts(ctx context.Context) ([]string, error) {
+	var accounts []string
+	if err := s.call(ctx, SignerMethodList, nil, &accounts); err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		accounts[i] = normalizeHexAddress(accounts[i])
+	}
+	return accounts, nil
+}
+
+func (s *ExternalSigner) SignMessage(ctx context.Context, address string, message []byte) ([]byte, error) {
+	var result string
+	params := []interface{}{
+		SignerContentTypeRevoMessage,
+		utils.AddHexPrefix(normalizeHexAddress(address)),
+		utils.AddHexPrefix(hex.EncodeToString(message)),
+	}
+	if err := s.call(ctx, SignerMethodSignData, params, &result); err != nil {
+		return nil, err
+	}
+
+	sig, err := hex.DecodeString(utils.RemoveHexPrefix(result))
+	if err != nil {
+		return nil, errors.Wrap(err, "external signer returned an invalid signature")
+	}
+	if len(sig) != 65 {
+		return nil, errors.Errorf("external signer returned a %d byte signature, expected 65", len(sig))
+	}
+	return sig, nil
+}
+
+func (s *ExternalSigner) SignTransaction(ctx context.Context, tx *SignerTransaction) (*wire.MsgTx, error) {
+	var result SignerTransactionResult
+	if err := s.call(ctx, SignerMethodSignTransaction, []interface{}{tx}, &result); err != nil {
+		return nil, err
+	}
+
+	unsigned, err := DeserializeTx(tx.UnsignedTx)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := DeserializeTx(result.Raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "external signer returned an invalid transaction")
+	}
+	if err := checkSignedTx(unsigned, signed); err != nil {
+		return nil, errors.Wrap(err, "external signer returned a different transaction")
+	}
+
+	return signed, nil
+}
+
+// checkSignedTx makes sure signing only changed the scripts of 'unsigned': besides the input scripts and the signature
+// of the OP_SENDER output, the signed transaction must serialize byte for byte like the unsigned one
+func checkSignedTx(unsigned, signed *wire.MsgTx) error {
+	if len(signed.TxIn) != len(unsigned.TxIn) || len(signed.TxOut) != len(unsigned.TxOut) {
+		return errors.New("inputs or outputs were added or removed")
+	}
+	if signed.Version != unsigned.Version || signed.LockTime != unsigned.LockTime {
+		return errors.New("the version or the lock time changed")
+	}
+	for i := range unsigned.TxIn {
+		if signed.TxIn[i].PreviousOutPoint != unsigned.TxIn[i].PreviousOutPoint {
+			return errors.Errorf("input %d spends a different output", i)
+		}
+		if signed.TxIn[i].Sequence != unsigned.TxIn[i].Sequence {
+			return errors.Errorf("input %d has a different sequence", i)
+		}
+	}
+	for i := range unsigned.TxOut {
+		if signed.TxOut[i].Value != unsigned.TxOut[i].Value {
+			return errors.Errorf("output %d has a different value", i)
+		}
+		// only OP_SENDER outputs get a signature, which comes before the contract script
+		if !bytes.Equal(signed.TxOut[i].PkScript, unsigned.TxOut[i].PkScript) && !sameContractScript(signed.TxOut[i].PkScript, unsigned.TxOut[i].PkScript) {
+			return errors.Errorf("output %d has a different script", i)
+		}
+	}
+
+	// anything else, like witnesses, with the signatures taken out
+	stripped := signed.Copy()
+	for i, in := range stripped.TxIn {
+		in.SignatureScript = unsigned.TxIn[i].SignatureScript
+	}
+	for i, out := range stripped.TxOut {
+		out.PkScript = unsigned.TxOut[i].PkScript
+	}
+	var want, got bytes.Buffer
+	if err := unsigned.Serialize(&want); err != nil {
+		return err
+	}
+	if err := stripped.Serialize(&got); err != nil {
+		return err
+	}
+	if !bytes.Equal(want.Bytes(), got.Bytes()) {
+		return errors.New("the transaction changed besides its signatures")
+	}
+	return nil
+}
+
+// sameContractScript reports whether 'signed' is the unsigned OP_SENDER script 'unsigned' with its empty signature
+// replaced by a single push of the signature
+func sameContractScript(signed, unsigned []byte) bool {
+	// an unsigned sender script is 2 + 21 + 1 + 1 bytes long: address type, public key hash, empty signature and OP_SENDER
+	const unsignedSenderLen = 25
+	if len(unsigned) < unsignedSenderLen || unsigned[23] != 0 || len(signed) < len(unsigned) {
+		return false
+	}
+	if !bytes.Equal(signed[:23], unsigned[:23]) || !bytes.HasSuffix(signed, unsigned[unsignedSenderLen-1:]) {
+		return false
+	}
+	signature := signed[23 : len(signed)-len(unsigned)+unsignedSenderLen-1]
+	pushed, err := txscript.PushedData(signature)
+	return err == nil && len(pushed) == 1 && bytes.Equal(appendPushData(nil, pushed[0]), signature)
+}
+
+func (s *ExternalSigner) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(&signerRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&s.id, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var respBody []byte
+	if s.client != nil {
+		respBody, err = s.postHTTP(ctx, body)
+	} else {
+		respBody, err = s.roundTripSocket(ctx, body)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "external signer %s", method)
+	}
+
+	var resp signerResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return errors.Wrapf(err, "external signer %s returned an invalid response", method)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (s *ExternalSigner) postHTTP(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && len(respBody) == 0 {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return respBody, nil
+}
+
+func (s *ExternalSigner) roundTripSocket(ctx context.Context, body []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", s.socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(append(body, '\n')); err != nil {
+		return nil, err
+	}
+
+	var respBody json.RawMessage
+	if err := json.NewDecoder(conn).Decode(&respBody); err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
+
+// DeserializeTx decodes a hex encoded transaction
+func DeserializeTx(rawTx string) (*wire.MsgTx, error) {
+	txBytes, err := hex.DecodeString(utils.RemoveHexPrefix(rawTx))
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
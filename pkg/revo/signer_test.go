@@ -0,0 +1,274 @@
+package revo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/btcd/txscript"
+	"github.com/revolutionchain/btcd/wire"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+)
+
+// the key and the output spent by the transactions of the tests
+const testSignerWIF = "cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk"
+
+func testSignerInput(t *testing.T, pkh []byte) revo.UnspentOutput {
+	pkScript, err := revo.PayToPubKeyHashScript(pkh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revo.UnspentOutput{
+		TxID:     "7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d",
+		Vout:     1,
+		Satoshis: 100000000,
+		PkScript: pkScript,
+	}
+}
+
+// signerProxy answers like the test signer for 'wif', counting the account_list calls in 'lists' and changing the
+// transactions it signs with 'tamper'
+func signerProxy(t *testing.T, wif *btcutil.WIF, lists *int32, tamper func(*wire.MsgTx)) *httptest.Server {
+	signer := internal.NewSignerServer(revo.Accounts{wif})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req revo.JSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Method == revo.SignerMethodList && lists != nil {
+			atomic.AddInt32(lists, 1)
+		}
+		body, _ := json.Marshal(&req)
+		recorder := httptest.NewRecorder()
+		signer.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+		var resp struct {
+			JSONRPC string            `json:"jsonrpc"`
+			ID      json.RawMessage   `json:"id"`
+			Result  json.RawMessage   `json:"result,omitempty"`
+			Error   *revo.SignerError `json:"error,omitempty"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Error(err)
+		}
+		var result revo.SignerTransactionResult
+		if req.Method == revo.SignerMethodSignTransaction && tamper != nil && json.Unmarshal(resp.Result, &result) == nil {
+			tx, err := revo.DeserializeTx(result.Raw)
+			if err != nil {
+				t.Error(err)
+			}
+			tamper(tx)
+			if result.Raw, err = revo.SerializeTx(tx); err != nil {
+				t.Error(err)
+			}
+			resp.Result, _ = json.Marshal(&result)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testSignerTransaction is a contract call paying change back to its sender, built without the key of 'wif'
+func testSignerTransaction(t *testing.T, wif *btcutil.WIF) *revo.SignerTransaction {
+	builder := revo.NewUnsignedTxBuilder(btcutil.Hash160(wif.SerializePubKey()))
+	builder.AddInput(testSignerInput(t, builder.SenderPubKeyHash()))
+	contract, _ := hex.DecodeString("9e11fba86ee5d0ba4996b0d1973de6b694f4fc95")
+	if err := builder.AddContractCall(contract, []byte{0x60, 0xfe}, 250000, 40, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.AddPayToPubKeyHash(builder.SenderPubKeyHash(), 80000000); err != nil {
+		t.Fatal(err)
+	}
+	signerTx, err := revo.NewSignerTransaction(builder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signerTx
+}
+
+func TestExternalSignerSignsTransaction(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testSignerWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(internal.NewSignerServer(revo.Accounts{wif}))
+	defer server.Close()
+
+	signer, err := revo.NewExternalSigner(server.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same transaction, built with and without the key
+	withKey := revo.NewTxBuilder(wif)
+	withoutKey := revo.NewUnsignedTxBuilder(withKey.SenderPubKeyHash())
+	contract, _ := hex.DecodeString("9e11fba86ee5d0ba4996b0d1973de6b694f4fc95")
+	for _, builder := range []*revo.TxBuilder{withKey, withoutKey} {
+		builder.AddInput(testSignerInput(t, withKey.SenderPubKeyHash()))
+		if err := builder.AddContractCall(contract, []byte{0x60, 0xfe}, 250000, 40, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := builder.AddPayToPubKeyHash(builder.SenderPubKeyHash(), 80000000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := withoutKey.Sign(); err == nil {
+		t.Fatal("expected a builder without a key to refuse to sign")
+	}
+
+	signerTx, err := revo.NewSignerTransaction(withoutKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.SignTransaction(context.Background(), signerTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := withKey.Sign()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signatures are deterministic
+	if signed.TxHash() != expected.TxHash() {
+		t.Errorf("expected transaction %s, got %s", expected.TxHash(), signed.TxHash())
+	}
+
+	signerTx.From = "7e22630f90e6db16283af2c6b04f688117a55db4"
+	if _, err := signer.SignTransaction(context.Background(), signerTx); err == nil {
+		t.Error("expected the signer to refuse signing for an account it doesn't hold")
+	}
+}
+
+func TestExternalSignerOverUnixSocket(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testSignerWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := (&revo.Account{WIF: wif}).ToHexAddress()
+
+	socket := filepath.Join(t.TempDir(), "signer.ipc")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go internal.NewSignerServer(revo.Accounts{wif}).Serve(listener)
+
+	signer, err := revo.NewExternalSigner(socket, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := signer.Accounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0] != address {
+		t.Fatalf("unexpected accounts %v", accounts)
+	}
+
+	message := []byte("hello")
+	sig, err := signer.SignMessage(context.Background(), address, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, _, err := btcec.RecoverCompact(btcec.S256(), sig, revo.MessageHash(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(btcutil.Hash160(pubKey.SerializeCompressed())) != address {
+		t.Error("message was signed by the wrong key")
+	}
+}
+
+func TestExternalSignerRefusesChangedTransaction(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testSignerWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampers := map[string]func(*wire.MsgTx){
+		"version":   func(tx *wire.MsgTx) { tx.Version++ },
+		"lock time": func(tx *wire.MsgTx) { tx.LockTime = 1 },
+		"sequence":  func(tx *wire.MsgTx) { tx.TxIn[0].Sequence-- },
+		"value":     func(tx *wire.MsgTx) { tx.TxOut[1].Value-- },
+		"script": func(tx *wire.MsgTx) {
+			script := append([]byte{}, tx.TxOut[1].PkScript...)
+			script[3] ^= 1
+			tx.TxOut[1].PkScript = script
+		},
+		"sender script": func(tx *wire.MsgTx) {
+			// another push before the signature
+			script := append([]byte{}, tx.TxOut[0].PkScript[:23]...)
+			script = append(script, txscript.OP_0)
+			tx.TxOut[0].PkScript = append(script, tx.TxOut[0].PkScript[23:]...)
+		},
+		"witness": func(tx *wire.MsgTx) { tx.TxIn[0].Witness = wire.TxWitness{{1}} },
+	}
+	for name, tamper := range tampers {
+		signer, err := revo.NewExternalSigner(signerProxy(t, wif, nil, tamper).URL, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := signer.SignTransaction(context.Background(), testSignerTransaction(t, wif)); err == nil {
+			t.Errorf("expected a transaction with a different %s to be refused", name)
+		}
+	}
+
+	// untouched
+	signer, err := revo.NewExternalSigner(signerProxy(t, wif, nil, func(*wire.MsgTx) {}).URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.SignTransaction(context.Background(), testSignerTransaction(t, wif)); err != nil {
+		t.Errorf("unexpected error for the signed transaction: %v", err)
+	}
+}
+
+func TestSignerAccountsAreCached(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(testSignerWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := (&revo.Account{WIF: wif}).ToHexAddress()
+
+	revoClient, err := internal.CreateMockedClient(internal.NewDoerMappedMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lists int32
+	revoClient.Signer, err = revo.NewExternalSigner(signerProxy(t, wif, &lists, nil).URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		signs, err := revoClient.IsSignerAccount(context.Background(), address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !signs {
+			t.Errorf("expected the signer to sign for %s", address)
+		}
+	}
+	if lists != 1 {
+		t.Errorf("expected the accounts to be listed once, they were listed %d times", lists)
+	}
+}
@@ -0,0 +1,60 @@
+package revo
+
+import (
+	"context"
+	"time"
+
+	"github.com/revolutionchain/charon/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRevodSpan starts the span of a call to revod, 'cached' calls were answered from the client's cache
+func startRevodSpan(ctx context.Context, method string, cached bool, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		// callers without a context of their own, on a client without one
+		ctx = context.Background()
+	}
+	opts = append([]trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("jsonrpc"),
+			semconv.RPCService("revod"),
+			semconv.RPCMethod(method),
+			attribute.Bool("charon.cache_hit", cached),
+		),
+	}, opts...)
+	return tracing.Tracer().Start(ctx, "revod "+method, opts...)
+}
+
+// startRevodBatchSpan starts the span of a JSON-RPC batch of 'calls' calls to revod
+func startRevodBatchSpan(ctx context.Context, calls int) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracing.Tracer().Start(ctx, "revod batch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("jsonrpc"),
+			semconv.RPCService("revod"),
+			attribute.Int("charon.batch_size", calls),
+		),
+	)
+}
+
+// recordSharedCall adds a span for a call to revod answered by an identical call made for another caller, whose
+// span is in the other caller's trace, from 'start' when the caller began waiting for it
+func recordSharedCall(ctx context.Context, method string, start time.Time) {
+	_, span := startRevodSpan(ctx, method, false, trace.WithTimestamp(start), trace.WithAttributes(attribute.Bool("charon.coalesced", true)))
+	span.End()
+}
+
+// recordBackoff adds the wait before retrying a busy revod to the span in 'ctx'
+func recordBackoff(ctx context.Context, method string, attempt int, backoff time.Duration) {
+	trace.SpanFromContext(ctx).AddEvent("revod busy, backing off", trace.WithAttributes(
+		semconv.RPCMethod(method),
+		attribute.Int("charon.attempt", attempt),
+		attribute.String("charon.backoff", backoff.String()),
+	))
+}
@@ -0,0 +1,411 @@
+package revo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/btcd/btcec/v2"
+	"github.com/revolutionchain/btcd/btcec/v2/ecdsa"
+	"github.com/revolutionchain/btcd/chaincfg/chainhash"
+	"github.com/revolutionchain/btcd/txscript"
+	"github.com/revolutionchain/btcd/wire"
+)
+
+const (
+	// EVM version pushed into OP_CREATE/OP_CALL scripts
+	ContractVMVersion = 4
+	// address type used in OP_SENDER scripts for a public key hash sender
+	SenderAddressTypePubKeyHash = 1
+
+	// revod's default minimum relay fee (satoshis per kB)
+	DefaultFeeRatePerKB = int64(400000)
+
+	// rough sizes used to estimate the fee before the transaction is signed
+	p2pkhInputSize      = 148
+	p2pkhOutputSize     = 34
+	senderSignatureSize = 107
+	txOverheadSize      = 10
+)
+
+var ErrInsufficientUTXOs = errors.New("Insufficient UTXO value attempted to be sent")
+var ErrMultipleSenderOutputs = errors.New("only one OP_SENDER output per transaction is supported")
+
+// UnspentOutput is an output owned by the signing key that can be spent by a TxBuilder
+type UnspentOutput struct {
+	TxID     string
+	Vout     uint32
+	Satoshis int64
+	PkScript []byte
+}
+
+// TxBuilder builds and signs P2PKH Revo transactions locally, including
+// OP_CREATE/OP_CALL outputs that carry an OP_SENDER signature, so that revod's wallet
+// is never involved
+type TxBuilder struct {
+	key    *btcec.PrivateKey
+	pubKey []byte
+	pkh    []byte
+
+	inputs  []UnspentOutput
+	outputs []*wire.TxOut
+
+	// index into outputs of the output that needs an OP_SENDER signature
+	senderOutput int
+}
+
+func NewTxBuilder(wif *btcutil.WIF) *TxBuilder {
+	key, _ := btcec.PrivKeyFromBytes(wif.PrivKey.Serialize())
+	pubKey := key.PubKey().SerializeCompressed()
+	if !wif.CompressPubKey {
+		pubKey = key.PubKey().SerializeUncompressed()
+	}
+
+	return &TxBuilder{
+		key:          key,
+		pubKey:       pubKey,
+		pkh:          btcutil.Hash160(pubKey),
+		senderOutput: -1,
+	}
+}
+
+// NewUnsignedTxBuilder builds transactions spending from the public key hash 'pkh' without holding its key,
+// they are signed elsewhere with SignTx
+func NewUnsignedTxBuilder(pkh []byte) *TxBuilder {
+	return &TxBuilder{
+		pkh:          pkh,
+		senderOutput: -1,
+	}
+}
+
+// SenderPubKeyHash returns the hash160 of the signing key, which is also its hex address
+func (b *TxBuilder) SenderPubKeyHash() []byte {
+	return b.pkh
+}
+
+func (b *TxBuilder) AddInput(utxo UnspentOutput) {
+	b.inputs = append(b.inputs, utxo)
+}
+
+func (b *TxBuilder) InputsValue() int64 {
+	var total int64
+	for _, input := range b.inputs {
+		total += input.Satoshis
+	}
+	return total
+}
+
+func (b *TxBuilder) AddPayToPubKeyHash(pkh []byte, satoshis int64) error {
+	script, err := PayToPubKeyHashScript(pkh)
+	if err != nil {
+		return err
+	}
+
+	b.outputs = append(b.outputs, wire.NewTxOut(satoshis, script))
+	return nil
+}
+
+// AddContractCall adds an OP_CALL output sending 'satoshis' to 'contract' that is signed by the builder's key via OP_SENDER
+func (b *TxBuilder) AddContractCall(contract []byte, data []byte, gasLimit uint64, gasPrice uint64, satoshis int64) error {
+	if len(contract) != 20 {
+		return errors.Errorf("invalid contract address length: %d", len(contract))
+	}
+
+	script := contractScript(data, gasLimit, gasPrice)
+	script = appendPushData(script, contract)
+	script = append(script, txscript.OP_CALL)
+
+	return b.addSenderOutput(script, satoshis)
+}
+
+// AddContractCreate adds an OP_CREATE output deploying 'bytecode' that is signed by the builder's key via OP_SENDER
+func (b *TxBuilder) AddContractCreate(bytecode []byte, gasLimit uint64, gasPrice uint64) error {
+	script := contractScript(bytecode, gasLimit, gasPrice)
+	script = append(script, txscript.OP_CREATE)
+
+	return b.addSenderOutput(script, 0)
+}
+
+func (b *TxBuilder) addSenderOutput(contractScript []byte, satoshis int64) error {
+	if b.senderOutput != -1 {
+		return ErrMultipleSenderOutputs
+	}
+
+	script := senderScript(b.pkh, nil, contractScript)
+
+	b.senderOutput = len(b.outputs)
+	b.outputs = append(b.outputs, wire.NewTxOut(satoshis, script))
+	return nil
+}
+
+// EstimateSize returns the expected size in bytes of the signed transaction, plus an optional change output
+func (b *TxBuilder) EstimateSize(withChange bool) int {
+	size := txOverheadSize + len(b.inputs)*p2pkhInputSize
+	for _, output := range b.outputs {
+		size += output.SerializeSize()
+	}
+	if b.senderOutput != -1 {
+		size += senderSignatureSize
+	}
+	if withChange {
+		size += p2pkhOutputSize
+	}
+	return size
+}
+
+// Unsigned returns the transaction with empty input scripts and, if there is one, an OP_SENDER output without its signature
+func (b *TxBuilder) Unsigned() (*wire.MsgTx, error) {
+	if len(b.inputs) == 0 {
+		return nil, errors.New("transaction has no inputs")
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, input := range b.inputs {
+		hash, err := chainhash.NewHashFromStr(input.TxID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid utxo txid %s", input.TxID)
+		}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, input.Vout), nil, nil))
+	}
+	for _, output := range b.outputs {
+		tx.AddTxOut(wire.NewTxOut(output.Value, output.PkScript))
+	}
+
+	return tx, nil
+}
+
+// Sign signs the OP_SENDER output (if any) and then every input, returning the finished transaction
+func (b *TxBuilder) Sign() (*wire.MsgTx, error) {
+	if b.key == nil {
+		return nil, errors.New("transaction builder has no key to sign with")
+	}
+
+	tx, err := b.Unsigned()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := signTx(tx, b.PrevScripts(), b.key, b.pubKey); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// PrevScripts returns the scripts of the outputs spent by the transaction's inputs, in input order
+func (b *TxBuilder) PrevScripts() [][]byte {
+	scripts := make([][]byte, 0, len(b.inputs))
+	for _, input := range b.inputs {
+		scripts = append(scripts, input.PkScript)
+	}
+	return scripts
+}
+
+// SignTx signs a transaction built by an unsigned TxBuilder with 'wif': the OP_SENDER output left
+// unsigned for the key's public key hash (if any) and then every input, 'prevScripts' being the scripts of the outputs they spend
+func SignTx(tx *wire.MsgTx, prevScripts [][]byte, wif *btcutil.WIF) error {
+	key, _ := btcec.PrivKeyFromBytes(wif.PrivKey.Serialize())
+	pubKey := key.PubKey().SerializeCompressed()
+	if !wif.CompressPubKey {
+		pubKey = key.PubKey().SerializeUncompressed()
+	}
+
+	return signTx(tx, prevScripts, key, pubKey)
+}
+
+func signTx(tx *wire.MsgTx, prevScripts [][]byte, key *btcec.PrivateKey, pubKey []byte) error {
+	if len(prevScripts) != len(tx.TxIn) {
+		return errors.Errorf("expected %d previous output scripts, got %d", len(tx.TxIn), len(prevScripts))
+	}
+
+	pkh := btcutil.Hash160(pubKey)
+
+	// outputs are signed first since the input signatures commit to them
+	unsignedPrefix := senderScript(pkh, nil, nil)
+	for i, output := range tx.TxOut {
+		if !bytes.HasPrefix(output.PkScript, unsignedPrefix) {
+			continue
+		}
+
+		subScript, err := PayToPubKeyHashScript(pkh)
+		if err != nil {
+			return err
+		}
+
+		hash, err := CalcOutputSignatureHash(subScript, txscript.SigHashAll, tx, i)
+		if err != nil {
+			return err
+		}
+
+		sig := append(ecdsa.Sign(key, hash).Serialize(), byte(txscript.SigHashAll))
+		scriptSig, err := txscript.NewScriptBuilder().AddData(sig).AddData(pubKey).Script()
+		if err != nil {
+			return err
+		}
+
+		tx.TxOut[i].PkScript = senderScript(pkh, scriptSig, output.PkScript[len(unsignedPrefix):])
+	}
+
+	for i, prevScript := range prevScripts {
+		scriptSig, err := txscript.SignatureScript(tx, i, prevScript, txscript.SigHashAll, key, len(pubKey) == btcec.PubKeyBytesLenCompressed)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign input %d", i)
+		}
+		tx.TxIn[i].SignatureScript = scriptSig
+	}
+
+	return nil
+}
+
+func senderScript(pkh []byte, scriptSig []byte, contractScript []byte) []byte {
+	script := appendPushData(nil, []byte{SenderAddressTypePubKeyHash})
+	script = appendPushData(script, pkh)
+	script = appendPushData(script, scriptSig)
+	script = append(script, txscript.OP_SENDER)
+
+	return append(script, contractScript...)
+}
+
+// CalcOutputSignatureHash computes the hash signed by an OP_SENDER scriptSig, see https://github.com/revolutionchain/qips/issues/6
+// The serialization is revod's SignatureHashOutput: it mirrors the legacy input sighash except that the output
+// being signed is committed to with 'subScript' (the sender's P2PKH script) in place of its own script and inputs
+// are committed to without their scripts
+func CalcOutputSignatureHash(subScript []byte, hashType txscript.SigHashType, tx *wire.MsgTx, idx int) ([]byte, error) {
+	if idx < 0 || idx >= len(tx.TxOut) {
+		return nil, errors.Errorf("output index %d out of range", idx)
+	}
+
+	anyoneCanPay := hashType&txscript.SigHashAnyOneCanPay != 0
+	hashNone := hashType&0x1f == txscript.SigHashNone
+	hashSingle := hashType&0x1f == txscript.SigHashSingle
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, tx.Version)
+
+	inputs := len(tx.TxIn)
+	if anyoneCanPay {
+		inputs = 0
+	}
+	wire.WriteVarInt(&buf, 0, uint64(inputs))
+	for i := 0; i < inputs; i++ {
+		txIn := tx.TxIn[i]
+		buf.Write(txIn.PreviousOutPoint.Hash[:])
+		binary.Write(&buf, binary.LittleEndian, txIn.PreviousOutPoint.Index)
+		binary.Write(&buf, binary.LittleEndian, txIn.Sequence)
+	}
+
+	outputs := len(tx.TxOut)
+	if hashNone {
+		outputs = 0
+	} else if hashSingle {
+		outputs = idx + 1
+	}
+	wire.WriteVarInt(&buf, 0, uint64(outputs))
+	for i := 0; i < outputs; i++ {
+		txOut := tx.TxOut[i]
+		if i == idx {
+			binary.Write(&buf, binary.LittleEndian, txOut.Value)
+			wire.WriteVarBytes(&buf, 0, subScript)
+		} else if hashSingle {
+			binary.Write(&buf, binary.LittleEndian, int64(-1))
+			wire.WriteVarBytes(&buf, 0, nil)
+		} else {
+			binary.Write(&buf, binary.LittleEndian, txOut.Value)
+			wire.WriteVarBytes(&buf, 0, txOut.PkScript)
+		}
+	}
+
+	binary.Write(&buf, binary.LittleEndian, tx.LockTime)
+	binary.Write(&buf, binary.LittleEndian, uint32(hashType))
+
+	return chainhash.DoubleHashB(buf.Bytes()), nil
+}
+
+func PayToPubKeyHashScript(pkh []byte) ([]byte, error) {
+	if len(pkh) != 20 {
+		return nil, errors.Errorf("invalid public key hash length: %d", len(pkh))
+	}
+
+	return txscript.NewScriptBuilder().
+		AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).
+		AddData(pkh).
+		AddOp(txscript.OP_EQUALVERIFY).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+}
+
+func SerializeTx(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// FeeForSize returns the fee in satoshis for a transaction of 'size' bytes
+func FeeForSize(size int, feeRatePerKB int64) int64 {
+	fee := int64(size) * feeRatePerKB / 1000
+	if fee == 0 && feeRatePerKB > 0 {
+		fee = feeRatePerKB
+	}
+	return fee
+}
+
+// DustThreshold returns the smallest P2PKH output value revod will relay for a fee rate
+func DustThreshold(feeRatePerKB int64) int64 {
+	return 3 * (p2pkhOutputSize + p2pkhInputSize) * feeRatePerKB / 1000
+}
+
+func contractScript(data []byte, gasLimit uint64, gasPrice uint64) []byte {
+	script := appendPushData(nil, []byte{ContractVMVersion})
+	script = appendPushData(script, scriptNum(gasLimit))
+	script = appendPushData(script, scriptNum(gasPrice))
+	return appendPushData(script, data)
+}
+
+// appendPushData pushes 'data' the way revod's CScript does: always as a data push, never as OP_N,
+// and without the element size limit txscript.ScriptBuilder enforces (contract bytecode is often larger)
+func appendPushData(script []byte, data []byte) []byte {
+	dataLen := len(data)
+	switch {
+	case dataLen < txscript.OP_PUSHDATA1:
+		script = append(script, byte(dataLen))
+	case dataLen <= 0xff:
+		script = append(script, txscript.OP_PUSHDATA1, byte(dataLen))
+	case dataLen <= 0xffff:
+		var buf [2]byte
+		binary.LittleEndian.PutUint16(buf[:], uint16(dataLen))
+		script = append(script, txscript.OP_PUSHDATA2)
+		script = append(script, buf[:]...)
+	default:
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], uint32(dataLen))
+		script = append(script, txscript.OP_PUSHDATA4)
+		script = append(script, buf[:]...)
+	}
+
+	return append(script, data...)
+}
+
+// scriptNum encodes 'n' as a minimal little endian script number
+func scriptNum(n uint64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	var result []byte
+	for n > 0 {
+		result = append(result, byte(n&0xff))
+		n >>= 8
+	}
+
+	// keep the number positive
+	if result[len(result)-1]&0x80 != 0 {
+		result = append(result, 0x00)
+	}
+
+	return result
+}
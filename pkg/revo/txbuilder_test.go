@@ -0,0 +1,225 @@
+package revo
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/btcd/btcec/v2"
+	"github.com/revolutionchain/btcd/btcec/v2/ecdsa"
+	"github.com/revolutionchain/btcd/chaincfg/chainhash"
+	"github.com/revolutionchain/btcd/txscript"
+	"github.com/revolutionchain/btcd/wire"
+)
+
+const testTxBuilderWIF = "cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk"
+
+func newTestTxBuilder(t *testing.T) (*TxBuilder, []byte) {
+	wif, err := btcutil.DecodeWIF(testTxBuilderWIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := NewTxBuilder(wif)
+	pkScript, err := PayToPubKeyHashScript(builder.SenderPubKeyHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder.AddInput(UnspentOutput{
+		TxID:     "7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d",
+		Vout:     1,
+		Satoshis: 100000000,
+		PkScript: pkScript,
+	})
+
+	return builder, pkScript
+}
+
+func TestTxBuilderSignsContractCall(t *testing.T) {
+	builder, pkScript := newTestTxBuilder(t)
+
+	contract, _ := hex.DecodeString("9e11fba86ee5d0ba4996b0d1973de6b694f4fc95")
+	data, _ := hex.DecodeString("60fe47b10000000000000000000000000000000000000000000000000000000000000319")
+	if err := builder.AddContractCall(contract, data, 250000, 40, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.AddPayToPubKeyHash(builder.SenderPubKeyHash(), 80000000); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := builder.Sign()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the input must pass script validation
+	vm, err := txscript.NewEngine(pkScript, tx, 0, txscript.StandardVerifyFlags, nil, nil, 100000000, txscript.NewCannedPrevOutputFetcher(pkScript, 100000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("input signature failed to verify: %s", err)
+	}
+
+	// the contract output must parse the same way transactions from revod do
+	asm, err := txscript.DisasmString(tx.TxOut[0].PkScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(asm, " ")
+	info, err := ParseCallSenderASM(parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.From != hex.EncodeToString(builder.SenderPubKeyHash()) {
+		t.Errorf("unexpected sender: %s", info.From)
+	}
+	if info.To != "9e11fba86ee5d0ba4996b0d1973de6b694f4fc95" {
+		t.Errorf("unexpected contract: %s", info.To)
+	}
+	if info.CallData != hex.EncodeToString(data) {
+		t.Errorf("unexpected call data: %s", info.CallData)
+	}
+	if info.GasLimit != "3d090" {
+		t.Errorf("unexpected gas limit: %s", info.GasLimit)
+	}
+	if info.GasPrice != "28" {
+		t.Errorf("unexpected gas price: %s", info.GasPrice)
+	}
+
+	// the OP_SENDER signature must verify against the output signature hash
+	scriptSig, _ := hex.DecodeString(parts[2])
+	pushes, err := txscript.PushedData(scriptSig)
+	if err != nil || len(pushes) != 2 {
+		t.Fatalf("unexpected OP_SENDER scriptSig: %s", parts[2])
+	}
+	sig, err := ecdsa.ParseDERSignature(pushes[0][:len(pushes[0])-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := btcec.ParsePubKey(pushes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := CalcOutputSignatureHash(pkScript, txscript.SigHashAll, tx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Verify(hash, pubKey) {
+		t.Error("OP_SENDER signature failed to verify")
+	}
+}
+
+func TestTxBuilderSignsContractCreate(t *testing.T) {
+	builder, _ := newTestTxBuilder(t)
+
+	// larger than the 520 byte limit txscript.ScriptBuilder enforces on pushes
+	bytecode := make([]byte, 2048)
+	for i := range bytecode {
+		bytecode[i] = byte(i)
+	}
+	if err := builder.AddContractCreate(bytecode, 2500000, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := builder.Sign()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asm, err := txscript.DisasmString(tx.TxOut[0].PkScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseCreateSenderASM(strings.Split(asm, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CallData != hex.EncodeToString(bytecode) {
+		t.Error("unexpected bytecode in OP_CREATE output")
+	}
+	if info.GasLimit != "2625a0" {
+		t.Errorf("unexpected gas limit: %s", info.GasLimit)
+	}
+}
+
+func TestTxBuilderRejectsMultipleSenderOutputs(t *testing.T) {
+	builder, _ := newTestTxBuilder(t)
+
+	if err := builder.AddContractCreate([]byte{0x60}, 250000, 40); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.AddContractCreate([]byte{0x60}, 250000, 40); err != ErrMultipleSenderOutputs {
+		t.Errorf("expected %v, got %v", ErrMultipleSenderOutputs, err)
+	}
+}
+
+func TestCalcOutputSignatureHash(t *testing.T) {
+	// a contract call output followed by change, spending two outputs
+	tx := wire.NewMsgTx(2)
+	for _, in := range []struct {
+		txID     string
+		vout     uint32
+		sequence uint32
+	}{
+		{"7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d", 1, wire.MaxTxInSequenceNum},
+		{"0425fa39feed4cd6c93998159901095c147f8b0043823067dc1d25dabf950ac9", 0, wire.MaxTxInSequenceNum - 1},
+	} {
+		hash, err := chainhash.NewHashFromStr(in.txID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txIn := wire.NewTxIn(wire.NewOutPoint(hash, in.vout), []byte{0x51}, nil)
+		txIn.Sequence = in.sequence
+		tx.AddTxIn(txIn)
+	}
+	callScript, _ := hex.DecodeString("01040390d0030128043d666e8b140000000000000000000000000000000000000086c2")
+	changeScript, _ := hex.DecodeString("76a9147926223070547d2d15b2ef5e7383e541c338ffe988ac")
+	tx.AddTxOut(wire.NewTxOut(0, callScript))
+	tx.AddTxOut(wire.NewTxOut(99000000, changeScript))
+	subScript, _ := hex.DecodeString("76a91493594441cb5de8b497ad8467d55412c2a0ef365988ac")
+
+	// the preimages as qtum's SignatureHashOutput (CTransactionSignatureOutputSerializer) writes them, which revod
+	// inherits: the inputs without their scripts, the signed output with the sender's script in place of its own
+	const (
+		version = "02000000"
+		inputs  = "02" +
+			"4d3c2b1a0f9e8d7c6b5a4f2e1d8d0b6c5b5a2a2face1a5a4a9a1f2fba33b6a7c" + "01000000" + "ffffffff" +
+			"c90a95bfda251ddc67308243008b7f145c090199159839c9d64cedfe39fa2504" + "00000000" + "feffffff"
+		signedCall   = "0000000000000000" + "19" + "76a91493594441cb5de8b497ad8467d55412c2a0ef365988ac"
+		signedChange = "c09ee60500000000" + "19" + "76a91493594441cb5de8b497ad8467d55412c2a0ef365988ac"
+		change       = "c09ee60500000000" + "19" + "76a9147926223070547d2d15b2ef5e7383e541c338ffe988ac"
+		lockTime     = "00000000"
+	)
+	cases := []struct {
+		hashType txscript.SigHashType
+		idx      int
+		preimage string
+	}{
+		{txscript.SigHashAll, 0, version + inputs + "02" + signedCall + change + lockTime + "01000000"},
+		{txscript.SigHashAll | txscript.SigHashAnyOneCanPay, 0, version + "00" + "02" + signedCall + change + lockTime + "81000000"},
+		{txscript.SigHashNone, 0, version + inputs + "00" + lockTime + "02000000"},
+		// the outputs before the signed one are blanked
+		{txscript.SigHashSingle, 1, version + inputs + "02" + "ffffffffffffffff" + "00" + signedChange + lockTime + "03000000"},
+	}
+	for _, c := range cases {
+		preimage, err := hex.DecodeString(c.preimage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := CalcOutputSignatureHash(subScript, c.hashType, tx, c.idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := chainhash.DoubleHashB(preimage); hex.EncodeToString(hash) != hex.EncodeToString(want) {
+			t.Errorf("hash type %v, output %d: expected %x, got %x", c.hashType, c.idx, want, hash)
+		}
+	}
+
+	if _, err := CalcOutputSignatureHash(subScript, txscript.SigHashAll, tx, 2); err == nil {
+		t.Error("expected an error for an output out of range")
+	}
+}
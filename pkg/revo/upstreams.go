@@ -0,0 +1,526 @@
+package revo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/analytics"
+	"github.com/revolutionchain/charon/pkg/metrics"
+	"github.com/sony/gobreaker"
+)
+
+// DefaultUpstreamCheckInterval is how often the height of every revod upstream is checked
+const DefaultUpstreamCheckInterval = 5 * time.Second
+
+// an upstream whose recent calls succeed less often than this is only used as a last resort
+const minUpstreamSuccessRate = 0.5
+
+// pinnedMethods need a node's wallet or broadcast transactions, they go to the wallet upstreams in order so that a
+// node's wallet state stays consistent
+var pinnedMethods = map[string]bool{
+	MethodSendToContract:        true,
+	MethodCreateContract:        true,
+	MethodSendToAddress:         true,
+	MethodGetTransaction:        true,
+	MethodGetAddressesByAccount: true,
+	MethodGenerateToAddress:     true,
+	MethodListUnspent:           true,
+	MethodSignRawTx:             true,
+	MethodSendRawTx:             true,
+	MethodCreateWallet:          true,
+	MethodLoadWallet:            true,
+	MethodUnloadWallet:          true,
+	MethodListWallets:           true,
+	MethodListWalletDir:         true,
+	"getnewaddress":             true,
+	"importaddress":             true,
+	"importprivkey":             true,
+	"dumpprivkey":               true,
+	"getbalance":                true,
+}
+
+// errUpstreamStatus is an upstream answering with a gateway or unavailable status
+var errUpstreamStatus = errors.New("revod upstream answered it's unavailable")
+
+// upstream is a revod node
+type upstream struct {
+	rawURL string
+	// for labels and logs, without credentials
+	host string
+	// used for reads, wallet upstreams which aren't are only used for pinned methods
+	read bool
+	// used for pinned methods
+	wallet bool
+
+	mutex  sync.RWMutex
+	height int64
+	// the last call or check reached the node
+	up bool
+	// recent calls
+	analytics *analytics.Analytics
+	// nil when disabled
+	breaker *gobreaker.TwoStepCircuitBreaker
+}
+
+func newUpstream(rawURL string) (*upstream, error) {
+	if err := checkRPCURL(rawURL); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse rpc url")
+	}
+	return &upstream{
+		rawURL:    rawURL,
+		host:      u.Host,
+		up:        true,
+		analytics: analytics.NewAnalytics(50),
+	}, nil
+}
+
+// healthy upstreams are reachable and their recent calls mostly succeed
+func (u *upstream) healthy() bool {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.up && u.analytics.GetSuccessRate() >= minUpstreamSuccessRate
+}
+
+func (u *upstream) getHeight() int64 {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.height
+}
+
+func (u *upstream) setHeight(height int64) {
+	u.mutex.Lock()
+	u.height = height
+	u.mutex.Unlock()
+	metrics.RevodUpstreamHeight.WithLabelValues(u.host).Set(float64(height))
+}
+
+func (u *upstream) reached(up bool) {
+	u.mutex.Lock()
+	u.up = up
+	u.mutex.Unlock()
+	if up {
+		u.analytics.Success()
+	} else {
+		u.analytics.Failure()
+		metrics.RevodUpstreamErrors.WithLabelValues(u.host).Inc()
+	}
+	healthy := 0.0
+	if u.healthy() {
+		healthy = 1
+	}
+	metrics.RevodUpstreamHealthy.WithLabelValues(u.host).Set(healthy)
+}
+
+// upstreams are the revod nodes a client calls. Reads go round robin to the healthy nodes at the tip, calls in
+// pinnedMethods to the first healthy wallet node
+type upstreams struct {
+	nodes []*upstream
+	// blocks a node may be behind the highest healthy node and still get reads
+	maxLag int64
+	next   uint32
+}
+
+func newUpstreams(rawURLs []string, walletURLs []string) (*upstreams, error) {
+	u := &upstreams{}
+	byURL := make(map[string]*upstream)
+	add := func(rawURL string) (*upstream, error) {
+		if node, ok := byURL[rawURL]; ok {
+			return node, nil
+		}
+		node, err := newUpstream(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		byURL[rawURL] = node
+		u.nodes = append(u.nodes, node)
+		return node, nil
+	}
+
+	for _, rawURL := range rawURLs {
+		node, err := add(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		node.read = true
+	}
+	if len(walletURLs) == 0 && len(rawURLs) > 0 {
+		// the first node handles the wallet
+		walletURLs = rawURLs[:1]
+	}
+	for _, rawURL := range walletURLs {
+		node, err := add(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		node.wallet = true
+	}
+
+	return u, nil
+}
+
+// route returns the upstreams to try for 'method', in order, the last ones are only there as a last resort
+func (u *upstreams) route(ctx context.Context, method string) []*upstream {
+	if len(u.nodes) == 1 {
+		return u.nodes
+	}
+
+	if pinnedMethods[method] {
+		var healthy, unhealthy []*upstream
+		for _, node := range u.nodes {
+			if !node.wallet {
+				continue
+			}
+			if node.healthy() {
+				healthy = append(healthy, node)
+			} else {
+				unhealthy = append(unhealthy, node)
+			}
+		}
+		return append(healthy, unhealthy...)
+	}
+
+	var tip int64
+	for _, node := range u.nodes {
+		if node.read && node.healthy() && node.getHeight() > tip {
+			tip = node.getHeight()
+		}
+	}
+	floor := tip - u.maxLag
+	if session := sessionFromContext(ctx); session != nil && session.Height() > floor {
+		floor = session.Height()
+	}
+
+	var ready, rest []*upstream
+	for _, node := range u.nodes {
+		if !node.read {
+			continue
+		}
+		if node.healthy() && node.getHeight() >= floor {
+			ready = append(ready, node)
+		} else {
+			rest = append(rest, node)
+		}
+	}
+
+	// spread reads over the ready nodes
+	if len(ready) > 1 {
+		start := int(atomic.AddUint32(&u.next, 1) % uint32(len(ready)))
+		ready = append(ready[start:], ready[:start]...)
+	}
+	// the closest to the session's view first
+	sort.SliceStable(rest, func(i, j int) bool {
+		if rest[i].healthy() != rest[j].healthy() {
+			return rest[i].healthy()
+		}
+		return rest[i].getHeight() > rest[j].getHeight()
+	})
+
+	return append(ready, rest...)
+}
+
+// do sends a JSON-RPC call to the upstreams of 'method', failing over to the next one when an upstream can't be
+// reached. The last upstream's answer is returned whatever its status, as a single revod would give it
+func (c *Client) do(ctx context.Context, method string, body []byte) ([]byte, error) {
+	nodes := c.upstreams.route(ctx, method)
+	if len(nodes) == 0 {
+		return nil, errors.Errorf("no revod upstream for %s", method)
+	}
+
+	var lastErr error
+	for i, node := range nodes {
+		last := i == len(nodes)-1
+		respBody, err := c.doUpstream(ctx, node, body, last)
+		if err == ErrUpstreamUnavailable {
+			// its breaker is open
+			lastErr = err
+			continue
+		}
+		if err == nil {
+			node.reached(true)
+			height := node.getHeight()
+			if served, ok := servedHeight(method, respBody); ok {
+				// fresher than the last check
+				height = served
+				node.setHeight(served)
+			}
+			if session := sessionFromContext(ctx); session != nil && !pinnedMethods[method] {
+				session.observe(height)
+			}
+			return respBody, nil
+		}
+
+		lastErr = err
+		node.reached(false)
+		if ctx != nil && ctx.Err() != nil {
+			// the caller gave up, not the upstream
+			break
+		}
+		if !last {
+			metrics.RevodFailovers.WithLabelValues(method).Inc()
+			c.GetLogger().Log("msg", "revod upstream failed, trying the next one", "upstream", node.host, "method", method, "error", err)
+		}
+	}
+	return nil, lastErr
+}
+
+// servedHeight returns the height of the chain an upstream answered a getblockcount or getblockchaininfo call with
+func servedHeight(method string, respBody []byte) (int64, bool) {
+	if method != MethodGetBlockCount && method != MethodGetBlockChainInfo {
+		return 0, false
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil || len(resp.Result) == 0 || string(resp.Result) == "null" {
+		return 0, false
+	}
+
+	var height int64
+	if method == MethodGetBlockCount {
+		if err := json.Unmarshal(resp.Result, &height); err != nil {
+			return 0, false
+		}
+		return height, true
+	}
+	var info struct {
+		Blocks *int64 `json:"blocks"`
+	}
+	if err := json.Unmarshal(resp.Result, &info); err != nil || info.Blocks == nil {
+		return 0, false
+	}
+	return *info.Blocks, true
+}
+
+func (c *Client) doUpstream(ctx context.Context, node *upstream, body []byte, last bool) ([]byte, error) {
+	var done func(success bool)
+	if node.breaker != nil {
+		var err error
+		if done, err = node.breaker.Allow(); err != nil {
+			metrics.RevodBreakerRejections.WithLabelValues(node.host).Inc()
+			return nil, ErrUpstreamUnavailable
+		}
+	}
+
+	status, respBody, err := c.post(ctx, node, body)
+	if done != nil {
+		// calls the caller gave up on don't count against the upstream
+		gaveUp := ctx != nil && ctx.Err() != nil
+		done(gaveUp || (err == nil && !overloaded(status, respBody)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !last && upstreamUnavailable(status) {
+		return nil, errors.Wrapf(errUpstreamStatus, "%s answered %d %s", node.host, status, http.StatusText(status))
+	}
+	return respBody, nil
+}
+
+// post sends 'body' to 'node', returning the status and body of its answer
+func (c *Client) post(ctx context.Context, node *upstream, body []byte) (int, []byte, error) {
+	rawURL := node.rawURL
+	if node == c.upstreams.nodes[0] {
+		// the client's URL may be changed after it's made
+		rawURL = c.URL
+	}
+
+	var req *http.Request
+	var err error
+	if ctx != nil {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	} else {
+		req, err = http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req.Close = false
+
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+
+	reader, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, errors.Wrap(err, "ioutil error in revo client package")
+	}
+	return resp.StatusCode, reader, nil
+}
+
+// upstreamUnavailable statuses come from proxies in front of revod or from revod when its work queue is full,
+// revod answers RPC errors with a 500
+func upstreamUnavailable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// checkUpstreams keeps the height of every upstream up to date until 'ctx' is done
+func (c *Client) checkUpstreams(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var wg sync.WaitGroup
+		for _, node := range c.upstreams.nodes {
+			wg.Add(1)
+			go func(node *upstream) {
+				defer wg.Done()
+				c.checkUpstream(ctx, node, interval)
+			}(node)
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) checkUpstream(ctx context.Context, node *upstream, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := c.NewRPCRequest(MethodGetBlockCount, nil)
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+
+	respBody, err := c.doUpstream(ctx, node, body, false)
+	if err == ErrUpstreamUnavailable {
+		// checked again once its breaker lets calls through
+		return
+	}
+	if err != nil {
+		node.reached(false)
+		c.GetDebugLogger().Log("msg", "revod upstream check failed", "upstream", node.host, "error", err)
+		return
+	}
+	res, err := c.responseBodyToResult(respBody)
+	if err != nil {
+		node.reached(false)
+		c.GetDebugLogger().Log("msg", "revod upstream check failed", "upstream", node.host, "error", err)
+		return
+	}
+	var height int64
+	if err := json.Unmarshal(res.RawResult, &height); err != nil {
+		node.reached(false)
+		return
+	}
+	node.setHeight(height)
+	node.reached(true)
+}
+
+// HealthyUpstreams returns the number of revod upstreams used for reads which are healthy
+func (c *Client) HealthyUpstreams() int {
+	healthy := 0
+	for _, node := range c.upstreams.nodes {
+		if node.read && node.healthy() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// Session is a client's view of the chain, its reads don't go to an upstream behind the highest block it was
+// served from unless no other upstream is left
+type Session struct {
+	height   int64
+	lastSeen int64
+}
+
+func (s *Session) Height() int64 {
+	return atomic.LoadInt64(&s.height)
+}
+
+func (s *Session) observe(height int64) {
+	for {
+		current := atomic.LoadInt64(&s.height)
+		if height <= current || atomic.CompareAndSwapInt64(&s.height, current, height) {
+			return
+		}
+	}
+}
+
+type sessionKey struct{}
+
+// WithSession returns 'ctx' whose revod reads are made for 'session'
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionFromContext(ctx context.Context) *Session {
+	if ctx == nil {
+		return nil
+	}
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// Sessions are the sessions of clients, by a key identifying each client. Sessions idle for longer than their
+// idle timeout are forgotten
+type Sessions struct {
+	mutex     sync.Mutex
+	sessions  map[string]*Session
+	idle      time.Duration
+	lastPrune time.Time
+}
+
+func NewSessions(idle time.Duration) *Sessions {
+	return &Sessions{
+		sessions:  make(map[string]*Session),
+		idle:      idle,
+		lastPrune: time.Now(),
+	}
+}
+
+// Get returns the session of the client 'key', a new one if it has none
+func (s *Sessions) Get(key string) *Session {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Sub(s.lastPrune) > s.idle {
+		for k, session := range s.sessions {
+			if now.Sub(time.Unix(0, atomic.LoadInt64(&session.lastSeen))) > s.idle {
+				delete(s.sessions, k)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	session, ok := s.sessions[key]
+	if !ok {
+		session = &Session{}
+		s.sessions[key] = session
+	}
+	atomic.StoreInt64(&session.lastSeen, now.UnixNano())
+	return session
+}
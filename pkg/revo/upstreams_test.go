@@ -0,0 +1,270 @@
+package revo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upstreamsDoer answers getblockcount with the height of each host, hosts in 'down' can't be reached
+type upstreamsDoer struct {
+	mutex   sync.Mutex
+	heights map[string]int64
+	down    map[string]bool
+	calls   []string
+}
+
+func (d *upstreamsDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var rpcReq JSONRPCRequest
+	json.NewDecoder(req.Body).Decode(&rpcReq)
+	d.calls = append(d.calls, req.URL.Host+" "+rpcReq.Method)
+
+	if d.down[req.URL.Host] {
+		return nil, errors.New("connection refused")
+	}
+	result, _ := json.Marshal(d.heights[req.URL.Host])
+	if rpcReq.Method != MethodGetBlockCount {
+		result, _ = json.Marshal(req.URL.Host)
+	}
+	body := `{"result":` + string(result) + `,"error":null,"id":"1"}`
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(body))}, nil
+}
+
+func (d *upstreamsDoer) setDown(host string, down bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.down[host] = down
+}
+
+func newUpstreamsClient(t *testing.T, doer *upstreamsDoer, opts ...func(*Client) error) *Client {
+	opts = append([]func(*Client) error{
+		SetDoer(doer),
+		SetUpstreams([]string{"http://user:pass@b", "http://user:pass@c"}),
+		SetWalletUpstreams([]string{"http://user:pass@w1", "http://user:pass@w2"}),
+	}, opts...)
+	client, err := NewClient(true, "http://user:pass@a", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetErrorHandler(func(ctx context.Context, err error) error { return nil })
+	return client
+}
+
+func checkAll(client *Client) {
+	for _, node := range client.upstreams.nodes {
+		client.checkUpstream(context.Background(), node, time.Second)
+	}
+}
+
+func callHost(t *testing.T, client *Client, ctx context.Context, method string) string {
+	var host string
+	if err := client.RequestWithContext(ctx, method, nil, &host); err != nil {
+		t.Fatal(err)
+	}
+	return host
+}
+
+func TestUpstreamsReadsGoToTheTip(t *testing.T) {
+	doer := &upstreamsDoer{
+		heights: map[string]int64{"a": 100, "b": 100, "c": 98, "w1": 100, "w2": 100},
+		down:    map[string]bool{},
+	}
+	client := newUpstreamsClient(t, doer)
+	checkAll(client)
+
+	hosts := map[string]int{}
+	for i := 0; i < 10; i++ {
+		hosts[callHost(t, client, context.Background(), MethodGetBlockHash)]++
+	}
+	if hosts["a"] == 0 || hosts["b"] == 0 || hosts["c"] != 0 || hosts["w1"] != 0 {
+		t.Errorf("Unexpected reads %v", hosts)
+	}
+
+	// within the lag
+	client.upstreams.maxLag = 2
+	hosts = map[string]int{}
+	for i := 0; i < 10; i++ {
+		hosts[callHost(t, client, context.Background(), MethodGetBlockHash)]++
+	}
+	if hosts["c"] == 0 {
+		t.Errorf("Unexpected reads %v", hosts)
+	}
+}
+
+func TestUpstreamsPinnedMethods(t *testing.T) {
+	doer := &upstreamsDoer{heights: map[string]int64{}, down: map[string]bool{}}
+	client := newUpstreamsClient(t, doer)
+
+	for i := 0; i < 3; i++ {
+		if host := callHost(t, client, context.Background(), MethodSendRawTx); host != "w1" {
+			t.Fatalf("Broadcast went to %s", host)
+		}
+	}
+
+	doer.setDown("w1", true)
+	if host := callHost(t, client, context.Background(), MethodSendRawTx); host != "w2" {
+		t.Fatalf("Broadcast failed over to %s", host)
+	}
+	// w1 stays out until it's reachable again
+	if host := callHost(t, client, context.Background(), MethodListUnspent); host != "w2" {
+		t.Fatalf("Wallet call went to %s", host)
+	}
+	doer.setDown("w1", false)
+	checkAll(client)
+	if host := callHost(t, client, context.Background(), MethodListUnspent); host != "w1" {
+		t.Fatalf("Wallet call went to %s", host)
+	}
+}
+
+func TestUpstreamsFailover(t *testing.T) {
+	doer := &upstreamsDoer{
+		heights: map[string]int64{"a": 100, "b": 100, "c": 100},
+		down:    map[string]bool{},
+	}
+	client := newUpstreamsClient(t, doer)
+	checkAll(client)
+
+	doer.setDown("a", true)
+	doer.setDown("b", true)
+	for i := 0; i < 5; i++ {
+		if host := callHost(t, client, context.Background(), MethodGetBlockHash); host != "c" {
+			t.Fatalf("Read went to %s", host)
+		}
+	}
+	if client.HealthyUpstreams() != 1 {
+		t.Errorf("Unexpected healthy upstreams %d", client.HealthyUpstreams())
+	}
+
+	doer.setDown("c", true)
+	if err := client.RequestWithContext(context.Background(), MethodGetBlockHash, nil, new(string)); err == nil {
+		t.Error("Expected an error with every upstream down")
+	}
+}
+
+func TestUpstreamsSessionNeverGoesBack(t *testing.T) {
+	doer := &upstreamsDoer{
+		heights: map[string]int64{"a": 100, "b": 99, "c": 99},
+		down:    map[string]bool{},
+	}
+	client := newUpstreamsClient(t, doer, SetUpstreamMaxLag(5))
+	checkAll(client)
+
+	session := &Session{}
+	ctx := WithSession(context.Background(), session)
+
+	// until the session was served from a, it may go anywhere
+	for session.Height() < 100 {
+		callHost(t, client, ctx, MethodGetBlockHash)
+	}
+	for i := 0; i < 10; i++ {
+		if host := callHost(t, client, ctx, MethodGetBlockHash); host != "a" {
+			t.Fatalf("The session went back to %s", host)
+		}
+	}
+
+	// b catches up
+	doer.heights["b"] = 100
+	checkAll(client)
+	hosts := map[string]int{}
+	for i := 0; i < 10; i++ {
+		hosts[callHost(t, client, ctx, MethodGetBlockHash)]++
+	}
+	if hosts["b"] == 0 || hosts["c"] != 0 {
+		t.Errorf("Unexpected reads %v", hosts)
+	}
+}
+
+func TestUpstreamsSessionFollowsServedHeight(t *testing.T) {
+	doer := &upstreamsDoer{
+		heights: map[string]int64{"a": 100, "b": 100, "c": 100},
+		down:    map[string]bool{},
+	}
+	client := newUpstreamsClient(t, doer)
+	checkAll(client)
+
+	// a gets a block before the next check
+	doer.heights["a"] = 101
+	session := &Session{}
+	ctx := WithSession(context.Background(), session)
+	for i := 0; i < 10 && session.Height() < 101; i++ {
+		var height int64
+		if err := client.RequestWithContext(ctx, MethodGetBlockCount, nil, &height); err != nil {
+			t.Fatal(err)
+		}
+		if session.Height() != height {
+			t.Fatalf("Served height %d but the session is at %d", height, session.Height())
+		}
+	}
+	if session.Height() != 101 {
+		t.Fatalf("Expected a to serve height 101")
+	}
+
+	for i := 0; i < 10; i++ {
+		if host := callHost(t, client, ctx, MethodGetBlockHash); host != "a" {
+			t.Fatalf("The session went back to %s", host)
+		}
+	}
+}
+
+func TestCheckUpstreams(t *testing.T) {
+	doer := &upstreamsDoer{
+		heights: map[string]int64{"a": 100, "b": 101, "c": 102},
+		down:    map[string]bool{"b": true},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := newUpstreamsClient(t, doer, SetContext(ctx), SetUpstreamCheckInterval(10*time.Millisecond))
+
+	deadline := time.Now().Add(time.Second)
+	for client.upstreams.nodes[2].getHeight() != 102 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if client.upstreams.nodes[0].getHeight() != 100 || client.upstreams.nodes[2].getHeight() != 102 {
+		t.Errorf("Unexpected heights %d %d", client.upstreams.nodes[0].getHeight(), client.upstreams.nodes[2].getHeight())
+	}
+	if client.upstreams.nodes[1].healthy() {
+		t.Error("An unreachable upstream is healthy")
+	}
+}
+
+func TestSingleUpstream(t *testing.T) {
+	doer := &upstreamsDoer{heights: map[string]int64{}, down: map[string]bool{}}
+	client, err := NewClient(true, "http://user:pass@a", SetDoer(doer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := callHost(t, client, context.Background(), MethodSendRawTx); host != "a" {
+		t.Errorf("Broadcast went to %s", host)
+	}
+	if host := callHost(t, client, context.Background(), MethodGetBlockHash); host != "a" {
+		t.Errorf("Read went to %s", host)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	sessions := NewSessions(time.Minute)
+	session := sessions.Get("10.0.0.1")
+	session.observe(10)
+	session.observe(5)
+	if sessions.Get("10.0.0.1").Height() != 10 {
+		t.Errorf("Unexpected height %d", sessions.Get("10.0.0.1").Height())
+	}
+	if sessions.Get("10.0.0.2") == session {
+		t.Error("Two clients share a session")
+	}
+
+	sessions.idle = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if sessions.Get("10.0.0.1") == session {
+		t.Error("An idle session wasn't forgotten")
+	}
+}
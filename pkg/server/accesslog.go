@@ -0,0 +1,39 @@
+package server
+
+import (
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/accesslog"
+	"github.com/revolutionchain/charon/pkg/apikeys"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/transformer"
+)
+
+// logCall writes a call answered with 'response' to the access log, 'revodCalls' is nil for calls which weren't
+// made because they were refused
+func logCall(c echo.Context, cc *myCtx, rpcReq *eth.JSONRPCRequest, start time.Time, response *eth.JSONRPCResult, revodCalls *revo.CallLog) {
+	if cc.accessLog == nil {
+		return
+	}
+
+	entry := accesslog.Entry{
+		Method:   rpcReq.Method,
+		Duration: time.Since(start),
+		ClientIP: cc.clientIP,
+		Params:   rpcReq.Params,
+		Result:   response.RawResult,
+	}
+	if response.Error != nil {
+		entry.Code = response.Error.Code()
+	}
+	if key, ok := c.Get(transformer.APIKeyContextKey).(*apikeys.Key); ok {
+		entry.APIKey = key.Name()
+	}
+	if revodCalls != nil {
+		entry.RevodCalls = revodCalls.Calls()
+	}
+
+	cc.accessLog.Log(entry)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/transformer"
+)
+
+const (
+	apiKeyHeader     = "X-API-Key"
+	apiKeyPathPrefix = "/v1/"
+	apiKeyQueryParam = "apikey"
+)
+
+// apiKeyMiddleware refuses requests without an accepted API key, the key is handed to the transformer which enforces
+// its quotas and allowed methods
+func (s *Server) apiKeyMiddleware(h echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.isMonitoringPath(c.Request().URL.Path) {
+			return h(c)
+		}
+
+		cc, ok := c.Get("myctx").(*myCtx)
+		if !ok {
+			return errors.New("Could not find myctx")
+		}
+
+		value := requestAPIKey(c.Request())
+		key := s.apiKeys.Lookup(value)
+		if key == nil {
+			if value == "" {
+				return cc.JSONRPCError(eth.NewUnauthorizedError("missing API key"))
+			}
+			return cc.JSONRPCError(eth.NewUnauthorizedError("invalid API key"))
+		}
+
+		c.Set(transformer.APIKeyContextKey, key)
+		cc.logger = log.With(cc.logger, "apiKey", key.Name())
+
+		return h(c)
+	}
+}
+
+// requestAPIKey returns the API key of a request: the X-API-Key header, the <key> of a /v1/<key> path or, for
+// websocket clients which can't set headers, the apikey query parameter
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	if strings.HasPrefix(r.URL.Path, apiKeyPathPrefix) {
+		return strings.Trim(strings.TrimPrefix(r.URL.Path, apiKeyPathPrefix), "/")
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		return r.URL.Query().Get(apiKeyQueryParam)
+	}
+	return ""
+}
+
+// httpStatus returns the HTTP status of a plain HTTP response holding 'err'
+func httpStatus(err eth.JSONRPCError) int {
+	switch err.Code() {
+	case eth.UnauthorizedErrorCode:
+		return http.StatusUnauthorized
+	case eth.LimitExceededErrorCode:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusOK
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/tracing"
+)
+
+// DefaultBatchWorkers is how many calls of a batch run at once
+const DefaultBatchWorkers = 8
+
+// handleMessage answers a JSON-RPC message received over HTTP or a websocket, a single request or a batch. Each
+// request takes a token from the client's rate limit, the requests past it are refused and the others go through the
+// transformer. The response is nil when there is nothing to send back, for notifications, 'status' is the HTTP status
+// to send it with
+func handleMessage(c echo.Context, cc *myCtx, message []byte) (response interface{}, status int) {
+	rpcReqs, reqErrs, batch, err := eth.ParseJSONRPCMessage(message)
+	if err != nil {
+		// a message which can't be read costs a request
+		if !cc.limits.allowIP(cc.clientIP) {
+			err = newRateLimitError()
+		}
+		return errorResult(nil, err), httpStatus(err)
+	}
+
+	if max := cc.limits.maxBatchCalls; batch && max > 0 && len(rpcReqs) > max {
+		return errorResult(nil, newBatchTooLargeError(len(rpcReqs), max)), http.StatusRequestEntityTooLarge
+	}
+
+	for i := range reqErrs {
+		if reqErrs[i] == nil && !cc.limits.allowIP(cc.clientIP) {
+			reqErrs[i] = newRateLimitError()
+		}
+	}
+
+	responses := callBatch(c, cc, rpcReqs, reqErrs)
+
+	if !batch {
+		if responses[0] == nil {
+			return nil, http.StatusNoContent
+		}
+		status = http.StatusOK
+		if responses[0].Error != nil {
+			status = httpStatus(responses[0].Error)
+		}
+		return responses[0], status
+	}
+
+	// notifications aren't answered
+	answered := make([]*eth.JSONRPCResult, 0, len(responses))
+	for _, response := range responses {
+		if response != nil {
+			answered = append(answered, response)
+		}
+	}
+	if len(answered) == 0 {
+		return nil, http.StatusNoContent
+	}
+	// a batch is answered with a 429 when every call in it went over a quota
+	return answered, batchHTTPStatus(answered)
+}
+
+// callBatch runs the requests of a message on up to 'cc.batchWorkers' goroutines, the responses are in the order
+// of the requests. A request with an error in 'reqErrs' is answered with it instead. Notifications run, but their
+// response is nil
+func callBatch(c echo.Context, cc *myCtx, rpcReqs []*eth.JSONRPCRequest, reqErrs []eth.JSONRPCError) []*eth.JSONRPCResult {
+	responses := make([]*eth.JSONRPCResult, len(rpcReqs))
+
+	workers := cc.batchWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(rpcReqs) {
+		workers = len(rpcReqs)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				responses[i] = answer(c, cc, rpcReqs[i], reqErrs[i])
+			}
+		}()
+	}
+	for i := range rpcReqs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return responses
+}
+
+func answer(c echo.Context, cc *myCtx, rpcReq *eth.JSONRPCRequest, reqErr eth.JSONRPCError) *eth.JSONRPCResult {
+	start := time.Now()
+	if reqErr != nil {
+		observeRequest(cc, rpcReq.Method, start, reqErr)
+		logCall(c, cc, rpcReq, start, errorResult(rpcReq.ID, reqErr), nil)
+		if cc.ethAnalytics != nil {
+			cc.ethAnalytics.Failure()
+		}
+		// invalid requests are answered even without an id, a refused notification isn't
+		if reqErr.Code() != eth.InvalidRequestErrorCode && rpcReq.IsNotification() {
+			return nil
+		}
+		return errorResult(rpcReq.ID, reqErr)
+	}
+
+	// the revod calls made for the slow log
+	ctx, revodCalls := revo.WithCallLog(c.Request().Context())
+	response := call(tracing.WithContext(c, ctx), cc, rpcReq)
+	observeRequest(cc, rpcReq.Method, start, response.Error)
+	logCall(c, cc, rpcReq, start, response, revodCalls)
+	if rpcReq.IsNotification() {
+		return nil
+	}
+	return response
+}
+
+// call runs a request straight through the transformer, 'c' is shared by the requests of a batch and only read
+func call(c echo.Context, cc *myCtx, rpcReq *eth.JSONRPCRequest) *eth.JSONRPCResult {
+	cc.GetLogger().Log("msg", "proxy RPC", "method", rpcReq.Method)
+
+	ctx, span := startCallSpan(c.Request().Context(), cc, rpcReq)
+	defer span.End()
+
+	response := transform(tracing.WithContext(c, ctx), cc, rpcReq)
+	if response.Error != nil {
+		tracing.RecordJSONRPCError(span, response.Error)
+	}
+	return response
+}
+
+func transform(c echo.Context, cc *myCtx, rpcReq *eth.JSONRPCRequest) *eth.JSONRPCResult {
+	result, jsonErr := cc.transformer.Transform(rpcReq, c)
+	if jsonErr == nil {
+		// Allow transformer to return an explicit JSON error
+		jsonErr, _ = result.(eth.JSONRPCError)
+	}
+	if jsonErr != nil {
+		if cc.ethAnalytics != nil {
+			cc.ethAnalytics.Failure()
+		}
+		// the cause is only logged, the client gets the error with its code, message and data
+		if jsonErr.Error() != nil {
+			cc.GetErrorLogger().Log("err", jsonErr.Message(), "cause", jsonErr.Error().Error())
+		} else {
+			cc.GetErrorLogger().Log("err", jsonErr.Message())
+		}
+		return errorResult(rpcReq.ID, jsonErr)
+	}
+
+	response, err := eth.NewJSONRPCResult(rpcReq.ID, result)
+	if err != nil {
+		if cc.ethAnalytics != nil {
+			cc.ethAnalytics.Failure()
+		}
+		cc.GetErrorLogger().Log("err", err.Error())
+		return errorResult(rpcReq.ID, eth.NewInternalError(err.Error()))
+	}
+
+	if cc.ethAnalytics != nil {
+		cc.ethAnalytics.Success()
+	}
+	return response
+}
+
+// errorResult answers the request 'id' with 'err', a nil id is sent as null
+func errorResult(id json.RawMessage, err eth.JSONRPCError) *eth.JSONRPCResult {
+	return &eth.JSONRPCResult{
+		ID:      id,
+		Error:   err,
+		JSONRPC: eth.RPCVersion,
+	}
+}
+
+// batchHTTPStatus is 429 when every call of a batch went over a quota
+func batchHTTPStatus(responses []*eth.JSONRPCResult) int {
+	if len(responses) == 0 {
+		return http.StatusOK
+	}
+	for _, response := range responses {
+		if response.Error == nil || response.Error.Code() != eth.LimitExceededErrorCode {
+			return http.StatusOK
+		}
+	}
+	return http.StatusTooManyRequests
+}
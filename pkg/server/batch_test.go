@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/transformer"
+)
+
+// sleepProxy answers test_sleep with the milliseconds it slept for, given as its only param, keeping the most
+// calls it saw running at once in 'max'
+func sleepProxy(max *int32) *testProxy {
+	var running int32
+	return &testProxy{method: "test_sleep", answer: func(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(max)
+			if now <= seen || atomic.CompareAndSwapInt32(max, seen, now) {
+				break
+			}
+		}
+
+		var params []int
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+			return nil, eth.NewInvalidParamsError("expected the milliseconds to sleep")
+		}
+		time.Sleep(time.Duration(params[0]) * time.Millisecond)
+		return params[0], nil
+	}}
+}
+
+// sleepBatch is a batch of test_sleep calls with ids from 1, the first ones sleeping the longest
+func sleepBatch(calls int) string {
+	batch := make([]string, calls)
+	for i := range batch {
+		batch[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"test_sleep","params":[%d]}`, i+1, 5*(calls-i))
+	}
+	return "[" + strings.Join(batch, ",") + "]"
+}
+
+func checkSleepBatch(t *testing.T, status int, body []byte, calls int) {
+	t.Helper()
+	if status != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", status, body)
+	}
+	results := decodeBatch(t, body)
+	if len(results) != calls {
+		t.Fatalf("Expected %d responses, got %d", calls, len(results))
+	}
+	for i, result := range results {
+		if result.Error != nil {
+			t.Errorf("Unexpected error %d: %s", i, result.Error.Message)
+		}
+		if string(result.ID) != fmt.Sprint(i+1) || string(result.Result) != fmt.Sprint(5*(calls-i)) {
+			t.Errorf("Unexpected response %d in the batch: id %s, result %s", i, result.ID, result.Result)
+		}
+	}
+}
+
+func TestBatchKeepsOrder(t *testing.T) {
+	var max int32
+	server := newTestServer(t, []transformer.ETHProxy{sleepProxy(&max)}, SetBatchWorkers(4))
+
+	status, body := post(t, server, sleepBatch(8))
+	checkSleepBatch(t, status, body, 8)
+	if max != 4 {
+		t.Errorf("Expected the batch to run on 4 workers, %d calls ran at once", max)
+	}
+}
+
+func TestBatchSingleThreaded(t *testing.T) {
+	var max int32
+	server := newTestServer(t, []transformer.ETHProxy{sleepProxy(&max)}, SetBatchWorkers(8), SetSingleThreaded(true))
+
+	// neither the calls of a batch nor the batches run at once
+	statuses, bodies, errs := make([]int, 3), make([][]byte, 3), make([]error, 3)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i], bodies[i], errs[i] = postBody(server, sleepBatch(4))
+		}(i)
+	}
+	wg.Wait()
+	for i := range statuses {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		checkSleepBatch(t, statuses[i], bodies[i], 4)
+	}
+	if max != 1 {
+		t.Errorf("Expected a single call to run at once, %d did", max)
+	}
+}
+
+func TestBatchSharesContext(t *testing.T) {
+	// run with -race, the calls of a batch only read the request's echo context
+	proxy := &testProxy{method: "test_context", answer: func(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+		if _, ok := c.Get("myctx").(*myCtx); !ok {
+			return nil, eth.NewInternalError("myctx not found")
+		}
+		if c.Request().Context().Err() != nil || c.Request().Header.Get("Content-Type") != "application/json" {
+			return nil, eth.NewInternalError("unexpected request")
+		}
+		return c.RealIP(), nil
+	}}
+	server := newTestServer(t, []transformer.ETHProxy{proxy}, SetBatchWorkers(8))
+
+	batch := make([]string, 50)
+	for i := range batch {
+		batch[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"test_context"}`, i)
+	}
+	status, body := post(t, server, "["+strings.Join(batch, ",")+"]")
+	if status != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", status, body)
+	}
+	results := decodeBatch(t, body)
+	if len(results) != len(batch) {
+		t.Fatalf("Expected %d responses, got %d", len(batch), len(results))
+	}
+	for i, result := range results {
+		if result.Error != nil || string(result.Result) != `"127.0.0.1"` {
+			t.Errorf("Unexpected response %d: %s, %+v", i, result.Result, result.Error)
+		}
+	}
+}
+
+func TestTransformKeepsTranslatedErrors(t *testing.T) {
+	doer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(doer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revodMessage := "bad-txns-in-belowout, value in (1.00) < value out (2.00)"
+	if err := doer.AddError(revo.MethodSendRawTx, eth.NewJSONRPCError(-26, revodMessage, nil)); err != nil {
+		t.Fatal(err)
+	}
+	proxy := &testProxy{method: "test_send", answer: func(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+		var result string
+		if err := revoClient.RequestWithContext(c.Request().Context(), revo.MethodSendRawTx, []string{"00"}, &result); err != nil {
+			return nil, eth.NewRevodError(err)
+		}
+		return result, nil
+	}}
+	server := newClientTestServer(t, revoClient, []transformer.ETHProxy{proxy})
+
+	_, body := post(t, server, `{"jsonrpc":"2.0","id":1,"method":"test_send","params":[]}`)
+	result := decodeResult(t, body)
+	if result.Error == nil {
+		t.Fatalf("Expected an error, got %s", body)
+	}
+	// geth's error, with revod's message as its data
+	if result.Error.Code != eth.CallbackErrorCode || result.Error.Message != "insufficient funds for gas * price + value" {
+		t.Errorf("Expected the translated revod error, got %d %q", result.Error.Code, result.Error.Message)
+	}
+	var data string
+	if err := json.Unmarshal(result.Error.Data, &data); err != nil || data != revodMessage {
+		t.Errorf("Expected revod's message as the error's data, got %s", result.Error.Data)
+	}
+}
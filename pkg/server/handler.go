@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	stdLog "log"
 	"net/http"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"github.com/labstack/echo"
 	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/metrics"
 	"github.com/revolutionchain/charon/pkg/notifier"
 	"github.com/revolutionchain/charon/pkg/revo"
 
@@ -21,54 +23,19 @@ func httpHandler(c echo.Context) error {
 	myctx := c.Get("myctx")
 	cc, ok := myctx.(*myCtx)
 	if !ok {
-		if cc.ethAnalytics != nil {
-			defer cc.ethAnalytics.Failure()
-		}
 		return errors.New("Could not find myctx")
 	}
 
-	var rpcReq *eth.JSONRPCRequest
-	decoder := json.NewDecoder(c.Request().Body)
-	if err := decoder.Decode(&rpcReq); err != nil {
-		if cc.ethAnalytics != nil {
-			defer cc.ethAnalytics.Failure()
-		}
-		return errors.Wrap(err, "json decoder issue")
-	}
-
-	cc.rpcReq = rpcReq
-
-	cc.GetLogger().Log("msg", "proxy RPC", "method", rpcReq.Method)
-
-	// level.Debug(cc.logger).Log("msg", "before call transformer#Transform")
-	result, err := cc.transformer.Transform(rpcReq, c)
-	// level.Debug(cc.logger).Log("msg", "after call transformer#Transform")
-
+	body, err := ioutil.ReadAll(c.Request().Body)
 	if err != nil {
-		if cc.ethAnalytics != nil {
-			defer cc.ethAnalytics.Failure()
-		}
-		if err.Error() == nil {
-			cc.GetErrorLogger().Log("err", err.Error())
-			return cc.JSONRPCError(err)
-		}
-
-		return err.Error()
-	}
-
-	// Allow transformer to return an explicit JSON error
-	if jerr, isJSONErr := result.(eth.JSONRPCError); isJSONErr {
-		if cc.ethAnalytics != nil {
-			defer cc.ethAnalytics.Failure()
-		}
-		return cc.JSONRPCError(jerr)
+		return err
 	}
 
-	if cc.ethAnalytics != nil {
-		defer cc.ethAnalytics.Success()
+	response, status := handleMessage(c, cc, body)
+	if response == nil {
+		return c.NoContent(status)
 	}
-
-	return cc.JSONRPCResult(result)
+	return c.JSON(status, response)
 }
 
 /*
@@ -225,41 +192,12 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func isBatchRequests(msg json.RawMessage) bool {
-	return len(msg) != 0 && msg[0] == '['
-}
-
-func getRpcResponses(c echo.Context, cc *myCtx, rpcReqs []eth.JSONRPCRequest) ([]interface{}, error) {
-	responses := make([]interface{}, 0, len(rpcReqs))
-	for _, rpcReq := range rpcReqs {
-		cc.rpcReq = &rpcReq
-
-		result, jsonError := cc.transformer.Transform(&rpcReq, c)
-
-		response := result
-
-		if jsonError != nil {
-			if jsonError.Error() == nil {
-				cc.GetErrorLogger().Log("err", jsonError.Error())
-				response = jsonError
-			}
-		}
-
-		// Allow transformer to return an explicit JSON error
-		if jerr, isJSONErr := response.(eth.JSONRPCError); isJSONErr {
-			response = cc.GetJSONRPCError(jerr)
-		} else {
-			var err error
-			response, err = cc.GetJSONRPCResult(response)
-			if err != nil {
-				cc.GetErrorLogger().Log("err", err.Error())
-				return nil, err
-			}
-		}
-
-		responses = append(responses, response)
+func sendJSON(send func([]byte) error, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
 	}
-	return responses, nil
+	return send(data)
 }
 
 func websocketHandler(c echo.Context) error {
@@ -281,6 +219,8 @@ func websocketHandler(c echo.Context) error {
 	} else {
 		cc.GetDebugLogger().Log("msg", "Got websocket request")
 	}
+	metrics.WebsocketConnections.Inc()
+	defer metrics.WebsocketConnections.Dec()
 	closeOnce := sync.Once{}
 	close := func() {
 		closeOnce.Do(func() {
@@ -288,7 +228,8 @@ func websocketHandler(c echo.Context) error {
 		})
 	}
 
-	ctx := c.Request().Context()
+	ctx := revo.WithSession(c.Request().Context(), &revo.Session{})
+	c.SetRequest(c.Request().WithContext(ctx))
 	var writeMutex sync.Mutex
 	stopPingPong := pingPong(ctx, ws, &writeMutex)
 	send := func(value []byte) error {
@@ -317,35 +258,24 @@ func websocketHandler(c echo.Context) error {
 
 	for {
 		cc.GetDebugLogger().Log("msg", "reading websocket request")
-		_, req, err := ws.ReadMessage()
-		if err != nil {
-			cc.GetLogger().Log("msg", "Failed to read websocket message", "err", err)
-			return nil
-		}
-		var rpcReqs []eth.JSONRPCRequest
-
-		isBatchedRequest := isBatchRequests(req)
-		if !isBatchedRequest {
-			var rpcReq eth.JSONRPCRequest
-			json.Unmarshal(req, &rpcReq)
-			rpcReqs = append(rpcReqs, rpcReq)
-		} else {
-			json.Unmarshal(req, &rpcReqs)
+		req, err := readMessage(ws, cc.limits.maxFrameSize)
+		if err == errMessageTooLarge {
+			// the request couldn't be read, its id is unknown
+			if err := sendJSON(send, errorResult(nil, newBodyTooLargeError(cc.limits.maxFrameSize))); err != nil {
+				cc.GetErrorLogger().Log("err", err.Error())
+				return nil
+			}
+			continue
 		}
-
-		responses, err := getRpcResponses(c, cc, rpcReqs)
 		if err != nil {
-			cc.GetErrorLogger().Log("err", err.Error())
+			cc.GetLogger().Log("msg", "Failed to read websocket message", "err", err)
 			return nil
 		}
-
-		var response interface{}
-		if isBatchedRequest {
-			response = responses
-		} else if len(responses) > 0 {
-			response = responses[0]
-		} else {
-			response = []interface{}{}
+		response, _ := handleMessage(c, cc, req)
+		if response == nil {
+			// only notifications, a subscription can't be waiting for its id to be sent
+			notifier.ResponseSent()
+			continue
 		}
 
 		responseBytes, err := json.Marshal(response)
@@ -383,7 +313,7 @@ func errorHandler(err error, c echo.Context) {
 	cc, ok := myctx.(*myCtx)
 	if ok {
 		cc.GetErrorLogger().Log("err", err.Error())
-		if err := cc.JSONRPCError(eth.NewJSONRPCError(100, err.Error(), nil)); err != nil {
+		if err := cc.JSONRPCError(eth.NewInternalError(err.Error())); err != nil {
 			cc.GetErrorLogger().Log("msg", "reply to client", "err", err.Error())
 		}
 		return
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/transformer"
+)
+
+func failProxy() *testProxy {
+	return &testProxy{method: "test_fail", answer: func(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+		return nil, eth.NewCallbackError("failed")
+	}}
+}
+
+func dial(t *testing.T, server *httptest.Server) *websocket.Conn {
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// wsSend sends 'message' over the websocket and returns the next message it gets
+func wsSend(t *testing.T, ws *websocket.Conn, message string) []byte {
+	t.Helper()
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatal(err)
+	}
+	return wsRead(t, ws)
+}
+
+func wsRead(t *testing.T, ws *websocket.Conn) []byte {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, response, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+const (
+	notification      = `{"jsonrpc":"2.0","method":"test_echo","params":[1]}`
+	notificationBatch = `[{"jsonrpc":"2.0","method":"test_echo","params":[1]},{"jsonrpc":"2.0","method":"test_echo","params":[2]}]`
+	// a valid call, an unknown method, an invalid request, a failing call and a notification
+	mixedBatch = `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["a"]},{"jsonrpc":"2.0","id":2,"method":"test_missing"},1,` +
+		`{"jsonrpc":"2.0","id":3,"method":"test_fail"},{"jsonrpc":"2.0","method":"test_echo"}]`
+	// string, number, big number, fraction and null ids
+	idsBatch = `[{"jsonrpc":"2.0","id":"abc","method":"test_echo"},{"jsonrpc":"2.0","id":7,"method":"test_echo"},` +
+		`{"jsonrpc":"2.0","id":123456789012345678901234567890,"method":"test_echo"},{"jsonrpc":"2.0","id":1.5e3,"method":"test_echo"},` +
+		`{"jsonrpc":"2.0","id":null,"method":"test_echo"}]`
+)
+
+func checkError(t *testing.T, result testResult, id string, code int) {
+	t.Helper()
+	if result.Error == nil || result.Error.Code != code || string(result.ID) != id {
+		t.Errorf("Expected error %d for id %s, got %+v for id %s", code, id, result.Error, result.ID)
+	}
+}
+
+func checkMixedBatch(t *testing.T, body []byte) {
+	t.Helper()
+	results := decodeBatch(t, body)
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 responses, got %s", body)
+	}
+	if results[0].Error != nil || string(results[0].ID) != "1" || string(results[0].Result) != `["a"]` {
+		t.Errorf("Unexpected response %s", body)
+	}
+	checkError(t, results[1], "2", eth.MethodNotFoundErrorCode)
+	checkError(t, results[2], "null", eth.InvalidRequestErrorCode)
+	checkError(t, results[3], "3", eth.CallbackErrorCode)
+}
+
+func checkIDs(t *testing.T, body []byte) {
+	t.Helper()
+	results := decodeBatch(t, body)
+	ids := []string{`"abc"`, "7", "123456789012345678901234567890", "1.5e3", "null"}
+	if len(results) != len(ids) {
+		t.Fatalf("Expected %d responses, got %s", len(ids), body)
+	}
+	for i, id := range ids {
+		if results[i].Error != nil || string(results[i].ID) != id {
+			t.Errorf("Expected id %s, got %s", id, results[i].ID)
+		}
+	}
+}
+
+func TestHTTPMessages(t *testing.T) {
+	var calls int32
+	server := newTestServer(t, []transformer.ETHProxy{echoProxy(&calls), failProxy()})
+
+	status, body := post(t, server, notification)
+	if status != http.StatusNoContent || len(body) != 0 || calls != 1 {
+		t.Errorf("Expected the notification to be run without a reply, got %d %s after %d calls", status, body, calls)
+	}
+	status, body = post(t, server, notificationBatch)
+	if status != http.StatusNoContent || len(body) != 0 || calls != 3 {
+		t.Errorf("Expected the notifications to be run without a reply, got %d %s after %d calls", status, body, calls)
+	}
+
+	status, body = post(t, server, `[]`)
+	if status != http.StatusOK {
+		t.Errorf("Unexpected status %d", status)
+	}
+	checkError(t, decodeResult(t, body), "null", eth.InvalidRequestErrorCode)
+
+	status, body = post(t, server, `{"jsonrpc":"2.0","id":1,`)
+	if status != http.StatusOK {
+		t.Errorf("Unexpected status %d", status)
+	}
+	checkError(t, decodeResult(t, body), "null", eth.InvalidMessageErrorCode)
+
+	_, body = post(t, server, mixedBatch)
+	checkMixedBatch(t, body)
+	_, body = post(t, server, idsBatch)
+	checkIDs(t, body)
+}
+
+func TestWebsocketMessages(t *testing.T) {
+	var calls int32
+	server := newTestServer(t, []transformer.ETHProxy{echoProxy(&calls), failProxy()})
+	ws := dial(t, server)
+
+	// notifications aren't answered, the next message is the reply to the call after them
+	for _, message := range []string{notification, notificationBatch} {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result := decodeResult(t, wsSend(t, ws, `{"jsonrpc":"2.0","id":"after","method":"test_echo","params":[]}`))
+	if string(result.ID) != `"after"` || calls != 4 {
+		t.Errorf("Expected the reply to the call after the notifications, got %s after %d calls", result.ID, calls)
+	}
+
+	checkError(t, decodeResult(t, wsSend(t, ws, `[]`)), "null", eth.InvalidRequestErrorCode)
+	checkError(t, decodeResult(t, wsSend(t, ws, `{"jsonrpc":"2.0","id":1,`)), "null", eth.InvalidMessageErrorCode)
+	checkMixedBatch(t, wsSend(t, ws, mixedBatch))
+	checkIDs(t, wsSend(t, ws, idsBatch))
+}
@@ -2,6 +2,9 @@ package server
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -13,6 +16,8 @@ var ErrCannotGetConnectedChain = errors.New("Cannot detect chain revod is connec
 var ErrBlockSyncingSeemsStalled = errors.New("Block syncing seems stalled")
 var ErrLostLotsOfBlocks = errors.New("Lost a lot of blocks, expected block height to be higher")
 var ErrLostFewBlocks = errors.New("Lost a few blocks, expected block height to be higher")
+var ErrNoHealthyUpstreams = errors.New("No revod upstream is healthy")
+var ErrUpstreamBreakersOpen = errors.New("The circuit breaker of every revod upstream is open")
 
 func (s *Server) testConnectionToRevod() error {
 	networkInfo, err := s.revoRPCClient.GetNetworkInfo(s.revoRPCClient.GetContext())
@@ -47,9 +52,35 @@ func (s *Server) testConnectionToRevod() error {
 	return err
 }
 
+func (s *Server) testRevodUpstreams() error {
+	if s.revoRPCClient.HealthyUpstreams() == 0 {
+		s.logger.Log("liveness", "No revod upstream is healthy")
+		return ErrNoHealthyUpstreams
+	}
+	return nil
+}
+
+// testRevodBreakers fails while calls to revod fail at once, with the state of the breaker of each upstream
+func (s *Server) testRevodBreakers() error {
+	if !s.revoRPCClient.ReadBreakersOpen() {
+		return nil
+	}
+	states := s.revoRPCClient.BreakerStates()
+	hosts := make([]string, 0, len(states))
+	for host := range states {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for i, host := range hosts {
+		hosts[i] = host + "=" + states[host]
+	}
+	s.logger.Log("readiness", "The circuit breaker of every revod upstream is open")
+	return fmt.Errorf("%w: %s", ErrUpstreamBreakersOpen, strings.Join(hosts, ", "))
+}
+
 func (s *Server) testLogEvents() error {
 	_, err := s.revoRPCClient.GetTransactionReceipt(s.revoRPCClient.GetContext(), "0000000000000000000000000000000000000000000000000000000000000000")
-	if err == revo.ErrInternalError {
+	if errors.Is(err, revo.ErrInternalError) {
 		s.logger.Log("liveness", "-logevents might not be enabled")
 		return errors.Wrap(err, "-logevents might not be enabled")
 	}
@@ -123,8 +154,13 @@ func (s *Server) testBlocksSyncing() error {
 	return s.lastBlockStatus
 }
 
+// minimumSuccessRate is the success rate under which the liveness check fails
+func (s *Server) minimumSuccessRate() float32 {
+	return float32(atomic.LoadInt32(&s.healthCheckPercent)) / 100
+}
+
 func (s *Server) testRevodErrorRate() error {
-	minimumSuccessRate := float32(*s.healthCheckPercent / 100)
+	minimumSuccessRate := s.minimumSuccessRate()
 	revoSuccessRate := s.revoRequestAnalytics.GetSuccessRate()
 
 	if revoSuccessRate < minimumSuccessRate {
@@ -136,7 +172,7 @@ func (s *Server) testRevodErrorRate() error {
 }
 
 func (s *Server) testCharonErrorRate() error {
-	minimumSuccessRate := float32(*s.healthCheckPercent / 100)
+	minimumSuccessRate := s.minimumSuccessRate()
 	ethSuccessRate := s.ethRequestAnalytics.GetSuccessRate()
 
 	if ethSuccessRate < minimumSuccessRate {
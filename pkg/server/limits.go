@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/ratelimit"
+)
+
+// geth's defaults
+const (
+	DefaultMaxBodySize   = 5 * 1024 * 1024
+	DefaultMaxBatchCalls = 1000
+	DefaultMaxFrameSize  = 32 * 1024 * 1024
+)
+
+// limits protect the server from clients sending too much, a zero limit is unlimited
+type limits struct {
+	// calls per client IP, a batch takes one per call, nil is unlimited
+	perIP          *ratelimit.Limiter
+	trustedProxies ratelimit.TrustedProxies
+
+	maxBodySize   int64
+	maxBatchCalls int
+	maxFrameSize  int64
+}
+
+func defaultLimits() *limits {
+	return &limits{
+		maxBodySize:   DefaultMaxBodySize,
+		maxBatchCalls: DefaultMaxBatchCalls,
+		maxFrameSize:  DefaultMaxFrameSize,
+	}
+}
+
+func (l *limits) allowIP(ip string) bool {
+	return l.perIP == nil || l.perIP.Allow(ip)
+}
+
+func newRateLimitError() eth.JSONRPCError {
+	return eth.NewLimitExceededError("request rate limit exceeded")
+}
+
+func newBodyTooLargeError(max int64) eth.JSONRPCError {
+	return eth.NewLimitExceededError(fmt.Sprintf("request exceeds the limit of %d bytes", max))
+}
+
+func newBatchTooLargeError(calls int, max int) eth.JSONRPCError {
+	return eth.NewLimitExceededError(fmt.Sprintf("batch of %d calls exceeds the limit of %d calls", calls, max))
+}
+
+// limitsMiddleware enforces the maximum body size of HTTP requests, websocket messages are checked as they are read.
+// The per IP rate limit is taken from for each call once the message is read
+func (s *Server) limitsMiddleware(h echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.isMonitoringPath(c.Request().URL.Path) || websocket.IsWebSocketUpgrade(c.Request()) {
+			return h(c)
+		}
+
+		cc, ok := c.Get("myctx").(*myCtx)
+		if !ok {
+			return errors.New("Could not find myctx")
+		}
+
+		if max := s.limits.maxBodySize; max > 0 && c.Request().Body != nil {
+			if c.Request().ContentLength > max {
+				return cc.JSONRPCErrorStatus(http.StatusRequestEntityTooLarge, newBodyTooLargeError(max))
+			}
+			body, err := ioutil.ReadAll(io.LimitReader(c.Request().Body, max+1))
+			if err != nil {
+				return err
+			}
+			if int64(len(body)) > max {
+				return cc.JSONRPCErrorStatus(http.StatusRequestEntityTooLarge, newBodyTooLargeError(max))
+			}
+			c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		return h(c)
+	}
+}
+
+var errMessageTooLarge = errors.New("websocket message too large")
+
+// readMessage reads the next websocket message, a message over 'max' bytes is skipped and errMessageTooLarge
+// returned, which unlike gorilla's read limit keeps the connection open
+func readMessage(ws *websocket.Conn, max int64) ([]byte, error) {
+	_, r, err := ws.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	msg, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(msg)) > max {
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			return nil, err
+		}
+		return nil, errMessageTooLarge
+	}
+	return msg, nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/transformer"
+)
+
+// echoCall is a test_echo call padded to 'size' bytes
+func echoCall(size int) string {
+	call := `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":[""]}`
+	return strings.Replace(call, `[""]`, `["`+strings.Repeat("a", size-len(call))+`"]`, 1)
+}
+
+// postFrom sends a test_echo call to the server as forwarded for 'forwardedFor', returning the status it got
+func postFrom(t *testing.T, server *httptest.Server, forwardedFor string) int {
+	req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString(echoCall(100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMaxBodySize(t *testing.T) {
+	server := newTestServer(t, []transformer.ETHProxy{echoProxy(nil)}, SetMaxBodySize(100))
+
+	if status, body := post(t, server, echoCall(100)); status != http.StatusOK {
+		t.Errorf("Unexpected status %d: %s", status, body)
+	}
+	status, body := post(t, server, echoCall(101))
+	if status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, status)
+	}
+	checkError(t, decodeResult(t, body), "null", eth.LimitExceededErrorCode)
+
+	// without a content length
+	resp, err := http.Post(server.URL, "application/json", ioutil.NopCloser(strings.NewReader(echoCall(101))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d for a chunked body, got %d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+}
+
+func TestMaxBatchCalls(t *testing.T) {
+	server := newTestServer(t, []transformer.ETHProxy{echoProxy(nil)}, SetMaxBatchCalls(2))
+
+	call := `{"jsonrpc":"2.0","id":1,"method":"test_echo"}`
+	if status, body := post(t, server, "["+call+","+call+"]"); status != http.StatusOK {
+		t.Errorf("Unexpected status %d: %s", status, body)
+	}
+	status, body := post(t, server, "["+call+","+call+","+call+"]")
+	if status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, status)
+	}
+	checkError(t, decodeResult(t, body), "null", eth.LimitExceededErrorCode)
+
+	// the same over a websocket
+	ws := dial(t, server)
+	checkError(t, decodeResult(t, wsSend(t, ws, "["+call+","+call+","+call+"]")), "null", eth.LimitExceededErrorCode)
+}
+
+func TestMaxFrameSize(t *testing.T) {
+	server := newTestServer(t, []transformer.ETHProxy{echoProxy(nil)}, SetMaxFrameSize(100))
+	ws := dial(t, server)
+
+	checkError(t, decodeResult(t, wsSend(t, ws, echoCall(101))), "null", eth.LimitExceededErrorCode)
+	// the connection stays open
+	result := decodeResult(t, wsSend(t, ws, echoCall(100)))
+	if result.Error != nil || string(result.ID) != "1" {
+		t.Errorf("Unexpected response %+v for id %s", result.Error, result.ID)
+	}
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	// X-Forwarded-For is ignored from peers which aren't trusted
+	server := newTestServer(t, []transformer.ETHProxy{echoProxy(nil)}, SetRateLimit(0.001, 1))
+	if status := postFrom(t, server, "10.0.0.1"); status != http.StatusOK {
+		t.Errorf("Unexpected status %d", status)
+	}
+	if status := postFrom(t, server, "10.0.0.2"); status != http.StatusTooManyRequests {
+		t.Errorf("Expected the peer to be rate limited, got %d", status)
+	}
+
+	// and followed through trusted proxies
+	server = newTestServer(t, []transformer.ETHProxy{echoProxy(nil)}, SetRateLimit(0.001, 1), SetTrustedProxies([]string{"127.0.0.1"}))
+	for _, forwardedFor := range []string{"10.0.0.1", "10.0.0.2"} {
+		if status := postFrom(t, server, forwardedFor); status != http.StatusOK {
+			t.Errorf("Unexpected status %d for %s", status, forwardedFor)
+		}
+	}
+	if status := postFrom(t, server, "10.0.0.1"); status != http.StatusTooManyRequests {
+		t.Errorf("Expected 10.0.0.1 to be rate limited, got %d", status)
+	}
+}
+
+func TestRateLimitBatch(t *testing.T) {
+	server := newTestServer(t, []transformer.ETHProxy{echoProxy(nil)}, SetRateLimit(0.001, 2))
+
+	// each call of a batch takes a token, the calls past the limit are refused
+	call := `{"jsonrpc":"2.0","id":1,"method":"test_echo"}`
+	status, body := post(t, server, "["+call+","+call+","+call+"]")
+	if status != http.StatusOK {
+		t.Errorf("Unexpected status %d: %s", status, body)
+	}
+	results := decodeBatch(t, body)
+	if len(results) != 3 || results[0].Error != nil || results[1].Error != nil {
+		t.Fatalf("Expected the first 2 calls to go through, got %s", body)
+	}
+	checkError(t, results[2], "1", eth.LimitExceededErrorCode)
+
+	status, body = post(t, server, "["+call+","+call+"]")
+	if status != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, status)
+	}
+	for _, result := range decodeBatch(t, body) {
+		checkError(t, result, "1", eth.LimitExceededErrorCode)
+	}
+
+	// the same over a websocket
+	server = newTestServer(t, []transformer.ETHProxy{echoProxy(nil)}, SetRateLimit(0.001, 2))
+	results = decodeBatch(t, wsSend(t, dial(t, server), "["+call+","+call+","+call+"]"))
+	if len(results) != 3 || results[0].Error != nil || results[1].Error != nil {
+		t.Fatalf("Expected the first 2 calls to go through, got %+v", results)
+	}
+	checkError(t, results[2], "1", eth.LimitExceededErrorCode)
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"time"
+
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/metrics"
+)
+
+const metricsPath = "/metrics"
+
+// isMonitoringPath is whether 'path' is a health check or the metrics endpoint, which skip API keys and limits
+func (s *Server) isMonitoringPath(path string) bool {
+	return path == "/live" || path == "/ready" || (s.metrics && path == metricsPath)
+}
+
+// observeRequest records a request answered with 'err', nil when it succeeded, in the metrics. Methods charon
+// doesn't implement share a single label
+func observeRequest(cc *myCtx, method string, start time.Time, err eth.JSONRPCError) {
+	if !cc.transformer.HasMethod(method) {
+		method = metrics.UnknownMethod
+	}
+	code := 0
+	if err != nil {
+		code = err.Code()
+	}
+	metrics.ObserveETHRequest(method, time.Since(start), code)
+}
@@ -1,13 +1,12 @@
 package server
 
 import (
-	"encoding/json"
 	"io"
-	"net/http"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/accesslog"
 	"github.com/revolutionchain/charon/pkg/analytics"
 	"github.com/revolutionchain/charon/pkg/blockhash"
 	"github.com/revolutionchain/charon/pkg/eth"
@@ -16,45 +15,33 @@ import (
 
 type myCtx struct {
 	echo.Context
-	rpcReq        *eth.JSONRPCRequest
 	logWriter     io.Writer
 	logger        log.Logger
 	transformer   *transformer.Transformer
 	blockHash     *blockhash.BlockHash
 	revoAnalytics *analytics.Analytics
 	ethAnalytics  *analytics.Analytics
+	limits        *limits
+	clientIP      string
+	batchWorkers  int
+	accessLog     *accesslog.Logger
 }
 
-func (c *myCtx) GetJSONRPCResult(result interface{}) (*eth.JSONRPCResult, error) {
-	return eth.NewJSONRPCResult(c.rpcReq.ID, result)
-}
-
-func (c *myCtx) JSONRPCResult(result interface{}) error {
-	response, err := c.GetJSONRPCResult(result)
-	if err != nil {
-		return err
-	}
-
-	return c.JSON(http.StatusOK, response)
-}
-
+// GetJSONRPCError answers a request that couldn't be read, with a null id
 func (c *myCtx) GetJSONRPCError(err eth.JSONRPCError) *eth.JSONRPCResult {
-	var id json.RawMessage
-	if c.rpcReq != nil && c.rpcReq.ID != nil {
-		id = c.rpcReq.ID
-	}
-	return &eth.JSONRPCResult{
-		ID:      id,
-		Error:   err,
-		JSONRPC: eth.RPCVersion,
-	}
+	return errorResult(nil, err)
 }
 
 func (c *myCtx) JSONRPCError(err eth.JSONRPCError) error {
+	return c.JSONRPCErrorStatus(httpStatus(err), err)
+}
+
+// JSONRPCErrorStatus replies with 'err' and the HTTP status 'status'
+func (c *myCtx) JSONRPCErrorStatus(status int, err eth.JSONRPCError) error {
 	resp := c.GetJSONRPCError(err)
 
 	if !c.Response().Committed {
-		err := c.JSON(http.StatusOK, resp)
+		err := c.JSON(status, resp)
 		c.logger.Log("Internal server error", err)
 		return err
 	}
@@ -71,7 +58,7 @@ func (c *myCtx) GetLogWriter() io.Writer {
 }
 
 func (c *myCtx) SetLogger(l log.Logger) {
-	c.logger = log.WithPrefix(l, "component", "context")
+	c.logger = log.WithPrefix(l, "component", "server")
 }
 
 func (c *myCtx) GetLogger() log.Logger {
@@ -79,10 +66,7 @@ func (c *myCtx) GetLogger() log.Logger {
 }
 
 func (c *myCtx) GetDebugLogger() log.Logger {
-	if !c.IsDebugEnabled() {
-		return log.NewNopLogger()
-	}
-	return log.With(level.Debug(c.logger))
+	return level.Debug(c.logger)
 }
 
 func (c *myCtx) GetErrorLogger() log.Logger {
@@ -1,30 +1,33 @@
 package server
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
-	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/websocket"
 	"github.com/heptiolabs/healthcheck"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
-	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/accesslog"
 	"github.com/revolutionchain/charon/pkg/analytics"
+	"github.com/revolutionchain/charon/pkg/apikeys"
 	"github.com/revolutionchain/charon/pkg/blockhash"
-	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/metrics"
+	"github.com/revolutionchain/charon/pkg/ratelimit"
 	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/tracing"
 	"github.com/revolutionchain/charon/pkg/transformer"
 )
 
+// how long the chain height a client was served is remembered after its last request
+const sessionIdleTimeout = 10 * time.Minute
+
 type Server struct {
 	address       string
 	transformer   *transformer.Transformer
@@ -38,7 +41,28 @@ type Server struct {
 	echo          *echo.Echo
 	blockHash     *blockhash.BlockHash
 
-	healthCheckPercent   *int
+	// methods exposed over HTTP and over websockets, nil exposes everything
+	httpMethodFilter *transformer.MethodFilter
+	wsMethodFilter   *transformer.MethodFilter
+
+	// accepted API keys, nil doesn't require any
+	apiKeys *apikeys.Registry
+	limits  *limits
+
+	// how many calls of a batch run at once
+	batchWorkers int
+
+	// serve Prometheus metrics at /metrics
+	metrics bool
+
+	// logs every call and the slow ones, nil doesn't log them
+	accessLog *accesslog.Logger
+
+	// the chain height each client was served, so that its reads never go to a revod upstream behind it
+	sessions *revo.Sessions
+
+	// minimum success rate of revod and ETH requests for the liveness check, changed with UpdateHealthCheckPercent
+	healthCheckPercent   int32
 	revoRequestAnalytics *analytics.Analytics
 	ethRequestAnalytics  *analytics.Analytics
 
@@ -63,6 +87,9 @@ func New(
 		revoRPCClient:       revoRPCClient,
 		transformer:         transformer,
 		ethRequestAnalytics: analytics.NewAnalytics(requests),
+		limits:              defaultLimits(),
+		batchWorkers:        DefaultBatchWorkers,
+		sessions:            revo.NewSessions(sessionIdleTimeout),
 	}
 
 	blockHashProcessor, err := blockhash.NewBlockHash(
@@ -87,15 +114,62 @@ func New(
 }
 
 func (s *Server) Start() error {
+	e := s.setup()
+
+	https := (s.httpsKey != "" && s.httpsCert != "")
+	url := s.revoRPCClient.GetURL().Redacted()
+	level.Info(s.logger).Log("listen", s.address, "revo_rpc", url, "msg", "proxy started", "https", https)
+
+	var err error
+
+	// shutdown echo server when context ends
+	go func(ctx context.Context, e *echo.Echo) {
+		<-ctx.Done()
+		e.Close()
+	}(s.revoRPCClient.GetContext(), e)
+
+	if s.revoRPCClient.DbConfig.String() == "" {
+		level.Warn(s.logger).Log("msg", "Database not configured - won't be able to respond to Ethereum block hash requests")
+	} else {
+		chainIdChan := make(chan int, 1)
+		err := s.blockHash.Start(&s.revoRPCClient.DbConfig, chainIdChan)
+		if err != nil {
+			level.Error(s.logger).Log("msg", "Failed to launch block hash converter", "error", err)
+			/*
+				level.Error(s.logger).Log("msg", "Failed to connect to database, quitting")
+				e.Close()
+				return errors.Wrap(err, "Failed to connect to database")
+			*/
+		}
+
+		go func() {
+			chainIdChan <- s.revoRPCClient.ChainId()
+		}()
+	}
+
+	if https {
+		level.Info(s.logger).Log("msg", "SSL enabled")
+		err = e.StartTLS(s.address, s.httpsCert, s.httpsKey)
+	} else {
+		err = e.Start(s.address)
+	}
+
+	return err
+}
+
+// setup registers the middlewares and the handlers of the server on its echo instance
+func (s *Server) setup() *echo.Echo {
 	logWriter := s.logWriter
 	e := s.echo
 
 	health := healthcheck.NewHandler()
 	health.AddLivenessCheck("revod-connection", func() error { return s.testConnectionToRevod() })
+	health.AddLivenessCheck("revod-upstreams", func() error { return s.testRevodUpstreams() })
 	health.AddLivenessCheck("revod-logevents-enabled", func() error { return s.testLogEvents() })
 	health.AddLivenessCheck("revod-blocks-syncing", func() error { return s.testBlocksSyncing() })
 	health.AddLivenessCheck("revod-error-rate", func() error { return s.testRevodErrorRate() })
 	health.AddLivenessCheck("charon-error-rate", func() error { return s.testCharonErrorRate() })
+	health.AddReadinessCheck("revod-breakers", func() error { return s.testRevodBreakers() })
 
 	e.Use(middleware.CORS())
 	e.Use(middleware.BodyDump(func(c echo.Context, req []byte, res []byte) {
@@ -105,7 +179,8 @@ func (s *Server) Start() error {
 			return
 		}
 
-		if s.debug {
+		// notifications aren't answered
+		if s.debug && len(res) > 0 {
 			reqBody, reqErr := revo.ReformatJSON(req)
 			resBody, resErr := revo.ReformatJSON(res)
 			if reqErr == nil && resErr == nil {
@@ -120,6 +195,11 @@ func (s *Server) Start() error {
 		}
 	}))
 
+	batchWorkers := s.batchWorkers
+	if s.mutex != nil {
+		batchWorkers = 1
+	}
+
 	e.Use(func(h echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			cc := &myCtx{
@@ -130,17 +210,37 @@ func (s *Server) Start() error {
 				blockHash:     s.blockHash,
 				revoAnalytics: s.revoRequestAnalytics,
 				ethAnalytics:  s.ethRequestAnalytics,
+				limits:        s.limits,
+				clientIP:      ratelimit.ClientIP(c.Request(), s.limits.trustedProxies),
+				batchWorkers:  batchWorkers,
+				accessLog:     s.accessLog,
 			}
 
 			c.Set("myctx", cc)
 			c.Set("blockHash", cc.blockHash)
+			// spans of the calls in the request are children of the client's trace, if it sent one
+			ctx := tracing.Extract(c.Request().Context(), c.Request().Header)
+			// a websocket connection is its own session
+			if !websocket.IsWebSocketUpgrade(c.Request()) {
+				ctx = revo.WithSession(ctx, s.sessions.Get(cc.clientIP))
+			}
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			if websocket.IsWebSocketUpgrade(c.Request()) {
+				c.Set(transformer.MethodFilterContextKey, s.wsMethodFilter)
+			} else {
+				c.Set(transformer.MethodFilterContextKey, s.httpMethodFilter)
+			}
 
 			return h(c)
 		}
 	})
 
-	// support batch requests
-	e.Use(batchRequestsMiddleware)
+	e.Use(s.limitsMiddleware)
+
+	if s.apiKeys != nil {
+		e.Use(s.apiKeyMiddleware)
+	}
 
 	e.HTTPErrorHandler = errorHandler
 	e.HideBanner = true
@@ -154,6 +254,9 @@ func (s *Server) Start() error {
 			return nil
 		})
 	}
+	if s.metrics {
+		e.GET(metricsPath, echo.WrapHandler(metrics.Handler()))
+	}
 
 	if s.mutex == nil {
 		e.POST("/*", httpHandler)
@@ -168,45 +271,7 @@ func (s *Server) Start() error {
 		e.GET("/*", websocketHandler)
 	}
 
-	https := (s.httpsKey != "" && s.httpsCert != "")
-	url := s.revoRPCClient.GetURL().Redacted()
-	level.Info(s.logger).Log("listen", s.address, "revo_rpc", url, "msg", "proxy started", "https", https)
-
-	var err error
-
-	// shutdown echo server when context ends
-	go func(ctx context.Context, e *echo.Echo) {
-		<-ctx.Done()
-		e.Close()
-	}(s.revoRPCClient.GetContext(), e)
-
-	if s.revoRPCClient.DbConfig.String() == "" {
-		level.Warn(s.logger).Log("msg", "Database not configured - won't be able to respond to Ethereum block hash requests")
-	} else {
-		chainIdChan := make(chan int, 1)
-		err := s.blockHash.Start(&s.revoRPCClient.DbConfig, chainIdChan)
-		if err != nil {
-			level.Error(s.logger).Log("msg", "Failed to launch block hash converter", "error", err)
-			/*
-				level.Error(s.logger).Log("msg", "Failed to connect to database, quitting")
-				e.Close()
-				return errors.Wrap(err, "Failed to connect to database")
-			*/
-		}
-
-		go func() {
-			chainIdChan <- s.revoRPCClient.ChainId()
-		}()
-	}
-
-	if https {
-		level.Info(s.logger).Log("msg", "SSL enabled")
-		err = e.StartTLS(s.address, s.httpsCert, s.httpsKey)
-	} else {
-		err = e.Start(s.address)
-	}
-
-	return err
+	return e
 }
 
 type Option func(*Server) error
@@ -220,7 +285,7 @@ func SetLogWriter(logWriter io.Writer) Option {
 
 func SetLogger(l log.Logger) Option {
 	return func(p *Server) error {
-		p.logger = l
+		p.logger = log.WithPrefix(l, "component", "server")
 		return nil
 	}
 }
@@ -243,6 +308,27 @@ func SetSingleThreaded(singleThreaded bool) Option {
 	}
 }
 
+func SetBatchWorkers(workers int) Option {
+	return func(p *Server) error {
+		p.batchWorkers = workers
+		return nil
+	}
+}
+
+func SetMetrics(enabled bool) Option {
+	return func(p *Server) error {
+		p.metrics = enabled
+		return nil
+	}
+}
+
+func SetAccessLog(accessLog *accesslog.Logger) Option {
+	return func(p *Server) error {
+		p.accessLog = accessLog
+		return nil
+	}
+}
+
 func SetHttps(key string, cert string) Option {
 	return func(p *Server) error {
 		p.httpsKey = key
@@ -251,96 +337,85 @@ func SetHttps(key string, cert string) Option {
 	}
 }
 
-func SetRevoAnalytics(analytics *analytics.Analytics) Option {
+func SetHTTPMethodFilter(filter *transformer.MethodFilter) Option {
 	return func(p *Server) error {
-		p.revoRequestAnalytics = analytics
+		p.httpMethodFilter = filter
 		return nil
 	}
 }
 
-func SetHealthCheckPercent(percent *int) Option {
+func SetWSMethodFilter(filter *transformer.MethodFilter) Option {
 	return func(p *Server) error {
-		p.healthCheckPercent = percent
+		p.wsMethodFilter = filter
 		return nil
 	}
 }
 
-func batchRequestsMiddleware(h echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		myctx := c.Get("myctx")
-		cc, ok := myctx.(*myCtx)
-		if !ok {
-			return errors.New("Could not find myctx")
-		}
-
-		// Request
-		reqBody := []byte{}
-		if c.Request().Body != nil { // Read
-			var err error
-			reqBody, err = ioutil.ReadAll(c.Request().Body)
-			if err != nil {
-				panic(fmt.Sprintf("%v", err))
-			}
-		}
-		isBatchRequests := func(msg json.RawMessage) bool {
-			return len(msg) != 0 && msg[0] == '['
-		}
-		c.Request().Body = ioutil.NopCloser(bytes.NewBuffer(reqBody)) // Reset
+func SetAPIKeys(registry *apikeys.Registry) Option {
+	return func(p *Server) error {
+		p.apiKeys = registry
+		return nil
+	}
+}
 
-		if !isBatchRequests(reqBody) {
-			return h(c)
+func SetRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(p *Server) error {
+		if requestsPerSecond <= 0 {
+			p.limits.perIP = nil
+			return nil
 		}
+		p.limits.perIP = ratelimit.NewLimiter(requestsPerSecond, burst)
+		return nil
+	}
+}
 
-		var rpcReqs []*eth.JSONRPCRequest
-		if err := c.Bind(&rpcReqs); err != nil {
-
+func SetTrustedProxies(proxies []string) Option {
+	return func(p *Server) error {
+		trusted, err := ratelimit.ParseTrustedProxies(proxies)
+		if err != nil {
 			return err
 		}
+		p.limits.trustedProxies = trusted
+		return nil
+	}
+}
 
-		results := make([]*eth.JSONRPCResult, 0, len(rpcReqs))
-
-		for _, req := range rpcReqs {
-			result, err := callHttpHandler(cc, req)
-			if err != nil {
-				return err
-			}
-
-			results = append(results, result)
-		}
-
-		return c.JSON(http.StatusOK, results)
+func SetMaxBodySize(max int64) Option {
+	return func(p *Server) error {
+		p.limits.maxBodySize = max
+		return nil
 	}
 }
 
-func callHttpHandler(cc *myCtx, req *eth.JSONRPCRequest) (*eth.JSONRPCResult, error) {
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
+func SetMaxBatchCalls(max int) Option {
+	return func(p *Server) error {
+		p.limits.maxBatchCalls = max
+		return nil
 	}
+}
 
-	httpreq := httptest.NewRequest(echo.POST, "/", ioutil.NopCloser(bytes.NewReader(reqBytes)))
-	httpreq.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-	rec := httptest.NewRecorder()
-
-	newCtx := cc.Echo().NewContext(httpreq, rec)
-	myCtx := &myCtx{
-		Context:       newCtx,
-		logWriter:     cc.GetLogWriter(),
-		logger:        cc.logger,
-		transformer:   cc.transformer,
-		blockHash:     cc.blockHash,
-		revoAnalytics: cc.revoAnalytics,
-		ethAnalytics:  cc.ethAnalytics,
+func SetMaxFrameSize(max int64) Option {
+	return func(p *Server) error {
+		p.limits.maxFrameSize = max
+		return nil
 	}
-	newCtx.Set("myctx", myCtx)
-	if err = httpHandler(myCtx); err != nil {
-		errorHandler(err, myCtx)
+}
+
+func SetRevoAnalytics(analytics *analytics.Analytics) Option {
+	return func(p *Server) error {
+		p.revoRequestAnalytics = analytics
+		return nil
 	}
+}
 
-	var result *eth.JSONRPCResult
-	if err = json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
-		return nil, err
+func SetHealthCheckPercent(percent *int) Option {
+	return func(p *Server) error {
+		p.healthCheckPercent = int32(*percent)
+		return nil
 	}
+}
 
-	return result, nil
+// UpdateHealthCheckPercent changes the minimum success rate of the liveness check while the server runs
+func (s *Server) UpdateHealthCheckPercent(percent int) {
+	atomic.StoreInt32(&s.healthCheckPercent, int32(percent))
 }
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/transformer"
+)
+
+// testProxy answers calls to 'method' with 'answer'
+type testProxy struct {
+	method string
+	answer func(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError)
+}
+
+func (p *testProxy) Method() string {
+	return p.method
+}
+
+func (p *testProxy) Request(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	return p.answer(req, c)
+}
+
+// echoProxy answers test_echo with its params, counting the calls it got
+func echoProxy(calls *int32) *testProxy {
+	return &testProxy{method: "test_echo", answer: func(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+		if calls != nil {
+			atomic.AddInt32(calls, 1)
+		}
+		return req.Params, nil
+	}}
+}
+
+// newTestServer serves the proxies behind a server made with 'opts'
+func newTestServer(t *testing.T, proxies []transformer.ETHProxy, opts ...Option) *httptest.Server {
+	revoClient, err := internal.CreateMockedClient(internal.NewDoerMappedMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newClientTestServer(t, revoClient, proxies, opts...)
+}
+
+// newClientTestServer serves the proxies behind a server made with 'opts' calling revod with 'revoClient'
+func newClientTestServer(t *testing.T, revoClient *revo.Revo, proxies []transformer.ETHProxy, opts ...Option) *httptest.Server {
+	trans, err := transformer.New(revoClient, proxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(revoClient, trans, "127.0.0.1:0", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s.setup())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// post sends 'body' to the server, returning the status and the body of its response
+func post(t *testing.T, server *httptest.Server, body string) (int, []byte) {
+	status, respBody, err := postBody(server, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, respBody
+}
+
+func postBody(server *httptest.Server, body string) (int, []byte, error) {
+	resp, err := http.Post(server.URL, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
+
+// testResult is a JSON-RPC response as a client reads it
+type testResult struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	} `json:"error"`
+	ID json.RawMessage `json:"id"`
+}
+
+func decodeResult(t *testing.T, body []byte) testResult {
+	var result testResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Couldn't decode response %s: %v", body, err)
+	}
+	return result
+}
+
+func decodeBatch(t *testing.T, body []byte) []testResult {
+	var results []testResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		t.Fatalf("Couldn't decode batch response %s: %v", body, err)
+	}
+	return results
+}
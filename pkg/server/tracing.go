@@ -0,0 +1,28 @@
+package server
+
+import (
+	"context"
+
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/metrics"
+	"github.com/revolutionchain/charon/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startCallSpan starts the span of a JSON-RPC call, named after its method like the metrics
+func startCallSpan(ctx context.Context, cc *myCtx, rpcReq *eth.JSONRPCRequest) (context.Context, trace.Span) {
+	method := rpcReq.Method
+	if !cc.transformer.HasMethod(method) {
+		method = metrics.UnknownMethod
+	}
+	return tracing.Tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("jsonrpc"),
+			semconv.RPCMethod(rpcReq.Method),
+			semconv.RPCJsonrpcRequestIDKey.String(string(rpcReq.ID)),
+			semconv.RPCJsonrpcVersionKey.String(rpcReq.JSONRPC),
+		),
+	)
+}
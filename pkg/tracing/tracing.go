@@ -0,0 +1,137 @@
+// Package tracing sets up OpenTelemetry tracing, spans follow a request from the ETH JSON-RPC call through the
+// transformer down to every revod call it makes
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/revolutionchain/charon"
+
+// exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+type Config struct {
+	// ExporterNone, ExporterOTLP or ExporterStdout
+	Exporter string
+	// host:port of the OTLP gRPC collector, OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317 when empty
+	Endpoint string
+	// connect to the collector without TLS
+	Insecure bool
+	// fraction of the traces started by charon which are recorded, traces started by a client follow its decision
+	SampleRatio float64
+	// where ExporterStdout writes spans, os.Stdout when nil
+	Writer io.Writer
+	// charon's version, recorded on every span
+	ServiceVersion string
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator, the returned function flushes
+// the spans not exported yet and stops exporting. With ExporterNone spans aren't recorded
+func Setup(ctx context.Context, config Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{}
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case ExporterStdout:
+		writer := config.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	default:
+		return nil, errors.Errorf("unknown trace exporter '%s'", config.Exporter)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create the trace exporter")
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName("charon"),
+		semconv.ServiceVersion(config.ServiceVersion),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns charon's tracer, it does nothing until Setup installs an exporter
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Extract returns 'ctx' with the trace context of an incoming request's headers
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// RecordError marks 'span' as failed with 'err'
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// RecordJSONRPCError marks 'span' as failed with the JSON-RPC error 'err'
+func RecordJSONRPCError(span trace.Span, err eth.JSONRPCError) {
+	span.SetAttributes(
+		semconv.RPCJsonrpcErrorCodeKey.Int(err.Code()),
+		semconv.RPCJsonrpcErrorMessageKey.String(err.Message()),
+	)
+	span.SetStatus(codes.Error, err.Message())
+}
+
+// WithContext returns an echo.Context whose request carries 'ctx', the proxies pass on the request's context to
+// revod calls so that their spans are children of the span in 'ctx'. Unlike echo.Context.SetRequest it leaves 'c',
+// which the calls of a batch share, untouched
+func WithContext(c echo.Context, ctx context.Context) echo.Context {
+	return &spanContext{Context: c, request: c.Request().WithContext(ctx)}
+}
+
+type spanContext struct {
+	echo.Context
+	request *http.Request
+}
+
+func (c *spanContext) Request() *http.Request {
+	return c.request
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetupStdout(t *testing.T) {
+	var buffer bytes.Buffer
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterStdout, Writer: &buffer, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a client's trace
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), header)
+
+	ctx, parent := Tracer().Start(ctx, "eth_blockNumber")
+	_, child := Tracer().Start(ctx, "revod getblockcount")
+	if child.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected trace id %s", child.SpanContext().TraceID())
+	}
+	child.End()
+	parent.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{`"Name":"eth_blockNumber"`, `"Name":"revod getblockcount"`, `"Value":"charon"`} {
+		if !strings.Contains(buffer.String(), name) {
+			t.Errorf("%s is missing from the exported spans", name)
+		}
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	c := echo.New().NewContext(httptest.NewRequest("POST", "/", nil), httptest.NewRecorder())
+	c.Set("key", "value")
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	wrapped := WithContext(c, ctx)
+	if !trace.SpanContextFromContext(wrapped.Request().Context()).Equal(spanContext) {
+		t.Error("The request doesn't carry the span")
+	}
+	if trace.SpanContextFromContext(c.Request().Context()).IsValid() {
+		t.Error("The original request was changed")
+	}
+	if wrapped.Get("key") != "value" {
+		t.Error("The values of the original context are missing")
+	}
+}
@@ -0,0 +1,44 @@
+package transformer
+
+import (
+	"encoding/json"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/logging"
+)
+
+// ProxyAdminSetLogLevel implements ETHProxy
+type ProxyAdminSetLogLevel struct {
+	Levels *logging.Levels
+}
+
+func (p *ProxyAdminSetLogLevel) Method() string {
+	return "admin_setLogLevel"
+}
+
+func (p *ProxyAdminSetLogLevel) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var params []string
+	if err := json.Unmarshal(rawreq.Params, &params); err != nil || len(params) != 2 {
+		return nil, eth.NewInvalidParamsError("expected [component, level]")
+	}
+
+	if err := p.Levels.Set(params[0], params[1]); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	return true, nil
+}
+
+// ProxyAdminLogLevels implements ETHProxy
+type ProxyAdminLogLevels struct {
+	Levels *logging.Levels
+}
+
+func (p *ProxyAdminLogLevels) Method() string {
+	return "admin_logLevels"
+}
+
+func (p *ProxyAdminLogLevels) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	return p.Levels.All(), nil
+}
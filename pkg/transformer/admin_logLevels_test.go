@@ -0,0 +1,49 @@
+package transformer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/logging"
+)
+
+func TestAdminSetLogLevel(t *testing.T) {
+	levels, err := logging.NewLevels(logging.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := internal.PrepareEthRPCRequest(1, []json.RawMessage{[]byte(`"transformer"`), []byte(`"debug"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, jsonErr := (&ProxyAdminSetLogLevel{Levels: levels}).Request(request, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	if got != true {
+		t.Errorf("Unexpected result %v", got)
+	}
+
+	request, err = internal.PrepareEthRPCRequest(1, []json.RawMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, jsonErr = (&ProxyAdminLogLevels{Levels: levels}).Request(request, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	all := got.(map[string]string)
+	if all["transformer"] != logging.LevelDebug || all["server"] != logging.LevelWarn {
+		t.Errorf("Unexpected levels %v", all)
+	}
+
+	request, err = internal.PrepareEthRPCRequest(1, []json.RawMessage{[]byte(`"transformer"`), []byte(`"loud"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, jsonErr := (&ProxyAdminSetLogLevel{Levels: levels}).Request(request, internal.NewEchoContext()); jsonErr == nil {
+		t.Error("Expected an invalid params error")
+	}
+}
@@ -0,0 +1,34 @@
+package transformer
+
+import (
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/apikeys"
+	"github.com/revolutionchain/charon/pkg/eth"
+)
+
+// APIKeyContextKey is the echo context key of the *apikeys.Key a request was made with
+const APIKeyContextKey = "apiKey"
+
+// apiKey returns the API key the server authenticated the request with, if any
+func apiKey(c echo.Context) *apikeys.Key {
+	if c == nil {
+		return nil
+	}
+	key, _ := c.Get(APIKeyContextKey).(*apikeys.Key)
+	return key
+}
+
+// ProxyCharonAPIKeyUsage implements ETHProxy, it returns the usage of the caller's API key
+type ProxyCharonAPIKeyUsage struct{}
+
+func (p *ProxyCharonAPIKeyUsage) Method() string {
+	return "charon_apiKeyUsage"
+}
+
+func (p *ProxyCharonAPIKeyUsage) Request(_ *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	key := apiKey(c)
+	if key == nil {
+		return nil, eth.NewInvalidRequestError("the request wasn't made with an API key")
+	}
+	return key.Usage(), nil
+}
@@ -0,0 +1,55 @@
+package transformer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/revolutionchain/charon/pkg/apikeys"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+)
+
+func TestTransformChecksAPIKey(t *testing.T) {
+	revoClient, err := internal.CreateMockedClient(internal.NewDoerMappedMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer, err := New(revoClient, []ETHProxy{&Web3ClientVersion{}, &ProxyCharonAPIKeyUsage{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := apikeys.NewRegistry([]apikeys.KeyConfig{
+		{Key: "secret", Name: "dapp", Daily: 2, Methods: []string{"web3_clientVersion", "charon_*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := internal.NewEchoContext()
+	c.Set(APIKeyContextKey, registry.Lookup("secret"))
+
+	request := func(method string) (interface{}, eth.JSONRPCError) {
+		return transformer.Transform(&eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method}, c)
+	}
+
+	if _, jsonErr := request("rpc_modules"); jsonErr == nil || jsonErr.Code() != eth.MethodNotFoundErrorCode {
+		t.Fatalf("expected a method the key isn't allowed to call to be refused, got %v", jsonErr)
+	}
+	if _, jsonErr := request("web3_clientVersion"); jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+
+	usage, jsonErr := request("charon_apiKeyUsage")
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	expected := apikeys.Usage{Name: "dapp", Total: 3, Rejected: 1, Today: 2, DailyQuota: 2}
+	if usage != expected {
+		t.Errorf("expected usage %+v, got %+v", expected, usage)
+	}
+
+	if _, jsonErr := request("web3_clientVersion"); jsonErr == nil || jsonErr.Code() != eth.LimitExceededErrorCode {
+		t.Fatalf("expected the daily quota to be exceeded, got %v", jsonErr)
+	}
+}
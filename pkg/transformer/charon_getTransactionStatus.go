@@ -0,0 +1,54 @@
+package transformer
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/txtracker"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+// ProxyCharonGetTransactionStatus implements ETHProxy
+type ProxyCharonGetTransactionStatus struct {
+	tracker *txtracker.Tracker
+}
+
+func (p *ProxyCharonGetTransactionStatus) Method() string {
+	return "charon_getTransactionStatus"
+}
+
+func (p *ProxyCharonGetTransactionStatus) Request(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var txHash eth.GetTransactionStatusRequest
+	if err := json.Unmarshal(req.Params, &txHash); err != nil {
+		return nil, eth.NewInvalidParamsError("couldn't unmarshal request")
+	}
+	if txHash == "" {
+		return nil, eth.NewInvalidParamsError("transaction hash is empty")
+	}
+
+	tx, ok := p.tracker.Get(string(txHash))
+	if !ok {
+		// not broadcast through this charon instance, or forgotten after the retention period
+		return nil, nil
+	}
+
+	resp := &eth.GetTransactionStatusResponse{
+		Hash:          utils.AddHexPrefix(tx.Hash),
+		Status:        string(tx.Status),
+		Confirmations: hexutil.EncodeUint64(uint64(p.tracker.Confirmations(tx))),
+		Rebroadcasts:  hexutil.EncodeUint64(uint64(tx.Rebroadcasts)),
+		Error:         tx.LastError,
+		CreatedAt:     tx.Created.Unix(),
+		UpdatedAt:     tx.Updated.Unix(),
+	}
+	if tx.Status == txtracker.StatusMined {
+		blockHash := utils.AddHexPrefix(tx.BlockHash)
+		blockNumber := hexutil.EncodeUint64(uint64(tx.BlockHeight))
+		resp.BlockHash = &blockHash
+		resp.BlockNumber = &blockNumber
+	}
+
+	return resp, nil
+}
@@ -1,6 +1,8 @@
 package transformer
 
 import (
+	"context"
+
 	"github.com/labstack/echo"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
@@ -17,16 +19,22 @@ func (p *ProxyETHAccounts) Method() string {
 }
 
 func (p *ProxyETHAccounts) Request(_ *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
-	return p.request()
+	return p.request(c.Request().Context())
 }
 
-func (p *ProxyETHAccounts) request() (eth.AccountsResponse, eth.JSONRPCError) {
+func (p *ProxyETHAccounts) request(ctx context.Context) (eth.AccountsResponse, eth.JSONRPCError) {
 	var accounts eth.AccountsResponse
 
-	for _, acc := range p.Accounts {
-		acc := revo.Account{acc}
-		addr := acc.ToHexAddress()
+	for _, addr := range p.AccountAddresses() {
+		accounts = append(accounts, utils.AddHexPrefix(addr))
+	}
 
+	// the accounts charon holds are still listed while the external signer can't be reached
+	signerAddresses, err := p.SignerAccountAddresses(ctx)
+	if err != nil {
+		p.GetErrorLogger().Log("method", p.Method(), "msg", "Failed to list external signer accounts", "error", err)
+	}
+	for _, addr := range signerAddresses {
 		accounts = append(accounts, utils.AddHexPrefix(addr))
 	}
 
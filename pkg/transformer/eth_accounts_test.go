@@ -2,7 +2,9 @@ package transformer
 
 import (
 	"encoding/json"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/btcsuite/btcutil"
 	"github.com/revolutionchain/charon/pkg/eth"
@@ -46,6 +48,40 @@ func TestAccountRequest(t *testing.T) {
 	internal.CheckTestResultEthRequestRPC(*request, want, got, t, false)
 }
 
+func TestAccountRequestWithoutSigner(t *testing.T) {
+	request, err := internal.PrepareEthRPCRequest(1, []json.RawMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient, err := internal.CreateMockedClient(internal.NewDoerMappedMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := btcutil.DecodeWIF("5JK4Gu9nxCvsCxiq9Zf3KdmA9ACza6dUn5BRLVWAYEtQabdnJ89")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.Accounts = append(revoClient.Accounts, local)
+
+	// a signer which went away
+	server := httptest.NewServer(internal.NewSignerServer(revo.Accounts{}))
+	revoClient.Signer, err = revo.NewExternalSigner(server.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	proxyEth := ProxyETHAccounts{revoClient}
+	got, jsonErr := proxyEth.Request(request, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+
+	want := eth.AccountsResponse{"0x6d358cf96533189dd5a602d0937fddf0888ad3ae"}
+
+	internal.CheckTestResultEthRequestRPC(*request, want, got, t, false)
+}
+
 func TestAccountMethod(t *testing.T) {
 	mockedClientDoer := internal.NewDoerMappedMock()
 	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
@@ -31,13 +31,13 @@ func (p *ProxyETHBlockNumber) request(c echo.Context, retries int) (*eth.BlockNu
 			t := time.NewTimer(500 * time.Millisecond)
 			select {
 			case <-ctx.Done():
-				return nil, eth.NewCallbackError(err.Error())
+				return nil, eth.NewCallbackErrorFrom(err)
 			case <-t.C:
 				// fallthrough
 			}
 			return p.request(c, retries-1)
 		}
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	// revo res -> eth res
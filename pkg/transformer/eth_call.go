@@ -5,6 +5,7 @@ import (
 	"math/big"
 
 	"github.com/labstack/echo"
+	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
 	"github.com/revolutionchain/charon/pkg/utils"
@@ -43,12 +44,16 @@ func (p *ProxyETHCall) request(ctx context.Context, ethreq *eth.CallRequest) (in
 
 	revoresp, err := p.CallContract(ctx, revoreq)
 	if err != nil {
-		if err == revo.ErrInvalidAddress {
+		if errors.Is(err, revo.ErrInvalidAddress) {
 			revoresp := eth.CallResponse("0x")
 			return &revoresp, nil
 		}
 
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewRevodError(err)
+	}
+
+	if revoresp.ExecutionResult.Excepted == "Revert" {
+		return nil, eth.NewExecutionRevertedError(revoresp.ExecutionResult.ExceptedMessage, utils.AddHexPrefix(revoresp.ExecutionResult.Output))
 	}
 
 	// revo res -> eth res
@@ -61,7 +66,7 @@ func (p *ProxyETHCall) ToRequest(ethreq *eth.CallRequest) (*revo.CallContractReq
 	if utils.IsEthHexAddress(from) {
 		from, err = p.FromHexAddress(from)
 		if err != nil {
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewRevodError(err)
 		}
 	}
 
@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
 )
 
 // 22000
@@ -54,13 +55,16 @@ func (p *ProxyETHEstimateGas) Request(rawreq *eth.JSONRPCRequest, c echo.Context
 	// revo [code: -5] Incorrect address occurs here
 	revoresp, err := p.CallContract(c.Request().Context(), revoreq)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewRevodError(err)
 	}
 
 	return p.toResp(revoresp)
 }
 
 func (p *ProxyETHEstimateGas) toResp(revoresp *revo.CallContractResponse) (*eth.EstimateGasResponse, eth.JSONRPCError) {
+	if revoresp.ExecutionResult.Excepted == "Revert" {
+		return nil, eth.NewExecutionRevertedError(revoresp.ExecutionResult.ExceptedMessage, utils.AddHexPrefix(revoresp.ExecutionResult.Output))
+	}
 	if revoresp.ExecutionResult.Excepted != "None" {
 		return nil, eth.NewCallbackError(ErrExecutionReverted.Error())
 	}
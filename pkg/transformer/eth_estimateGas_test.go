@@ -137,6 +137,71 @@ func TestEstimateGasRequestExecutionReverted(t *testing.T) {
 	internal.CheckTestResultDefault(want, got, t, false)
 }
 
+func TestEstimateGasRequestRevert(t *testing.T) {
+	request := eth.CallRequest{
+		From: "0x1e6f89d7399081b4f8f8aa1ae2805a5efff2f960",
+		To:   "0x1e6f89d7399081b4f8f8aa1ae2805a5efff2f960",
+		Data: "0x0",
+	}
+	requestRaw, err := json.Marshal(&request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestParamsArray := []json.RawMessage{requestRaw}
+	requestRPC, err := internal.PrepareEthRPCRequest(1, requestParamsArray)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//preparing responses
+	fromHexAddressResponse := revo.FromHexAddressResponse("0x1e6f89d7399081b4f8f8aa1ae2805a5efff2f960")
+	err = mockedClientDoer.AddResponseWithRequestID(2, revo.MethodFromHexAddress, fromHexAddressResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callContractResponse := revo.CallContractResponse{
+		Address: "1e6f89d7399081b4f8f8aa1ae2805a5efff2f960",
+		ExecutionResult: struct {
+			GasUsed         int    `json:"gasUsed"`
+			Excepted        string `json:"excepted"`
+			ExceptedMessage string `json:"exceptedMessage"`
+			NewAddress      string `json:"newAddress"`
+			Output          string `json:"output"`
+			CodeDeposit     int    `json:"codeDeposit"`
+			GasRefunded     int    `json:"gasRefunded"`
+			DepositSize     int    `json:"depositSize"`
+			GasForDeposit   int    `json:"gasForDeposit"`
+		}{
+			GasUsed:         21678,
+			Excepted:        "Revert",
+			ExceptedMessage: "not owner",
+			Output:          "08c379a0",
+		},
+	}
+	err = mockedClientDoer.AddResponseWithRequestID(1, revo.MethodCallContract, callContractResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//preparing proxy & executing request
+	proxyEth := ProxyETHCall{revoClient}
+	proxyEthEstimateGas := ProxyETHEstimateGas{&proxyEth}
+
+	_, got := proxyEthEstimateGas.Request(requestRPC, internal.NewEchoContext())
+
+	want := eth.NewExecutionRevertedError("not owner", "0x08c379a0")
+
+	internal.CheckTestResultDefault(want, got, t, false)
+}
+
 func TestEstimateGasNonVMRequest(t *testing.T) {
 	request := eth.CallRequest{
 		From: "0x1e6f89d7399081b4f8f8aa1ae2805a5efff2f960",
@@ -21,7 +21,7 @@ func (p *ProxyETHGasPrice) Method() string {
 func (p *ProxyETHGasPrice) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
 	revoresp, err := p.Revo.GetGasPrice(c.Request().Context())
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	// revo res -> eth res
@@ -5,6 +5,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/labstack/echo"
+	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
 	"github.com/revolutionchain/charon/pkg/utils"
@@ -45,18 +46,18 @@ func (p *ProxyETHGetBalance) Request(rawreq *eth.JSONRPCRequest, c echo.Context)
 		base58Addr, err := p.FromHexAddress(addr)
 		if err != nil {
 			p.GetDebugLogger().Log("method", p.Method(), "address", req.Address, "msg", "error parsing address", "error", err)
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewCallbackErrorFrom(err)
 		}
 
 		revoreq := revo.GetAddressBalanceRequest{Address: base58Addr}
 		revoresp, err := p.GetAddressBalance(c.Request().Context(), &revoreq)
 		if err != nil {
-			if err == revo.ErrInvalidAddress {
+			if errors.Is(err, revo.ErrInvalidAddress) {
 				// invalid address should return 0x0
 				return "0x0", nil
 			}
 			p.GetDebugLogger().Log("method", p.Method(), "address", req.Address, "msg", "error getting address balance", "error", err)
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewCallbackErrorFrom(err)
 		}
 
 		// 1 REVO = 10 ^ 8 Satoshi
@@ -32,10 +32,12 @@ func (p *ProxyETHGetBlockByHash) Request(rawreq *eth.JSONRPCRequest, c echo.Cont
 		return nil, eth.NewInvalidParamsError(err.Error())
 	}
 
-	blockHash := c.Get("blockHash")
-	bh, ok := blockHash.(*blockhash.BlockHash)
-	if !ok {
-		// ok, do nothing
+	var bh *blockhash.BlockHash
+	parent := context.Background()
+	if c != nil {
+		// without a block hash converter only the revod block hash is looked up
+		bh, _ = c.Get("blockHash").(*blockhash.BlockHash)
+		parent = c.Request().Context()
 	}
 
 	req.BlockHash = utils.RemoveHexPrefix(req.BlockHash)
@@ -43,7 +45,7 @@ func (p *ProxyETHGetBlockByHash) Request(rawreq *eth.JSONRPCRequest, c echo.Cont
 	resultChan := make(chan *eth.GetBlockByHashResponse, 2)
 	errorChan := make(chan eth.JSONRPCError, 1)
 	revoBlockErrorChan := make(chan error, 1)
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	ctx, cancelFunc := context.WithCancel(parent)
 	defer cancelFunc()
 
 	go func() {
@@ -117,18 +119,18 @@ func (p *ProxyETHGetBlockByHash) Request(rawreq *eth.JSONRPCRequest, c echo.Cont
 func (p *ProxyETHGetBlockByHash) request(ctx context.Context, req *eth.GetBlockByHashRequest) (*eth.GetBlockByHashResponse, eth.JSONRPCError) {
 	blockHeader, err := p.GetBlockHeader(ctx, req.BlockHash)
 	if err != nil {
-		if err == revo.ErrInvalidAddress {
+		if errors.Is(err, revo.ErrInvalidAddress) {
 			// unknown block hash should return {result: null}
 			p.GetDebugLogger().Log("msg", "Unknown block hash", "blockHash", req.BlockHash)
 			return nil, nil
 		}
 		p.GetDebugLogger().Log("msg", "couldn't get block header", "blockHash", req.BlockHash)
-		return nil, eth.NewCallbackError("couldn't get block header")
+		return nil, eth.WrapCallbackError(err, "couldn't get block header")
 	}
 	block, err := p.GetBlock(ctx, req.BlockHash)
 	if err != nil {
 		p.GetDebugLogger().Log("msg", "couldn't get block", "blockHash", req.BlockHash)
-		return nil, eth.NewCallbackError("couldn't get block")
+		return nil, eth.WrapCallbackError(err, "couldn't get block")
 	}
 	nonce := hexutil.EncodeUint64(uint64(block.Nonce))
 	// left pad nonce with 0 to length 16, eg: 0x0000000000000042
@@ -206,11 +208,12 @@ func (p *ProxyETHGetBlockByHash) request(ctx context.Context, req *eth.GetBlockB
 	// so using that from the start instead of requesting each tx individually as done here would save a lot of back-and-forth
 
 	if req.FullTransaction {
+		ctx = prefetchTransactions(ctx, p.Revo, block.Txs)
 		for _, txHash := range block.Txs {
 			tx, err := getTransactionByHash(ctx, p.Revo, txHash)
 			if err != nil {
 				p.GetDebugLogger().Log("msg", "Couldn't get transaction by hash", "hash", txHash, "err", err)
-				return nil, eth.NewCallbackError("couldn't get transaction by hash")
+				return nil, eth.WrapCallbackError(err.Error(), "couldn't get transaction by hash")
 			}
 			if tx == nil {
 				if block.Height == 0 {
@@ -242,3 +245,63 @@ func (p *ProxyETHGetBlockByHash) request(ctx context.Context, req *eth.GetBlockB
 
 	return resp, nil
 }
+
+// prefetchTransactions makes the revod calls of getTransactionByHash for 'hashes' in batches, a few rounds of them
+// instead of several calls per transaction, and returns 'ctx' for getTransactionByHash to use their results
+func prefetchTransactions(ctx context.Context, p *revo.Revo, hashes []string) context.Context {
+	ctx = revo.WithPrefetch(ctx)
+	prefetched := make(map[string]bool)
+
+	txCalls := make([]*revo.BatchCall, len(hashes))
+	for i, hash := range hashes {
+		txCalls[i] = revo.NewGetTransactionCall(hash)
+	}
+	p.Prefetch(ctx, txCalls)
+
+	// transactions out of the wallet are looked up raw
+	var hexes []string
+	var generated = make(map[string]bool)
+	var rawCalls []*revo.BatchCall
+	for i, call := range txCalls {
+		switch {
+		case call.Err == nil:
+			tx := call.Result.(*revo.GetTransactionResponse)
+			hexes = append(hexes, tx.Hex)
+			generated[tx.Hex] = tx.Generated
+		case errors.Cause(call.Err) == revo.ErrInvalidAddress:
+			rawCalls = append(rawCalls, revo.NewGetRawTransactionCall(hashes[i], false))
+			prefetched[hashes[i]] = true
+		}
+	}
+	p.Prefetch(ctx, rawCalls)
+	for _, call := range rawCalls {
+		if call.Err == nil {
+			hexes = append(hexes, call.Result.(*revo.GetRawTransactionResponse).Hex)
+		}
+	}
+
+	decodeCalls := make([]*revo.BatchCall, len(hexes))
+	for i, hex := range hexes {
+		decodeCalls[i] = revo.NewDecodeRawTransactionCall(hex)
+	}
+	p.Prefetch(ctx, decodeCalls)
+
+	// the senders of transactions are found in their raw inputs
+	var senderCalls []*revo.BatchCall
+	for i, call := range decodeCalls {
+		if call.Err != nil || generated[hexes[i]] {
+			continue
+		}
+		tx := call.Result.(*revo.DecodedRawTransactionResponse)
+		if contractInfo, isContractTx, _ := tx.ExtractContractInfo(); isContractTx && contractInfo.From != "" {
+			continue
+		}
+		if !prefetched[tx.ID] {
+			senderCalls = append(senderCalls, revo.NewGetRawTransactionCall(tx.ID, false))
+			prefetched[tx.ID] = true
+		}
+	}
+	p.Prefetch(ctx, senderCalls)
+
+	return ctx
+}
@@ -1,6 +1,7 @@
 package transformer
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -36,3 +37,34 @@ func TestGetBlockByHashTransactionsRequest(t *testing.T) {
 		&internal.GetTransactionByHashResponseWithTransactions,
 	)
 }
+
+func TestGetBlockByHashUsesRequestContext(t *testing.T) {
+	request, err := internal.PrepareEthRPCRequest(1, []json.RawMessage{[]byte(`"` + internal.GetTransactionByHashBlockHexHash + `"`), []byte(`false`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	internal.SetupGetBlockByHashResponses(t, mockedClientDoer)
+
+	// the revod calls are made with the request's context
+	ctx, calls := revo.WithCallLog(context.Background())
+	if _, jsonErr := initializeProxyETHGetBlockByHash(revoClient).Request(request, internal.NewEchoWithContext(ctx)); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	methods := make(map[string]bool)
+	for _, call := range calls.Calls() {
+		methods[call.Method] = true
+	}
+	if !methods[revo.MethodGetBlockHeader] || !methods[revo.MethodGetBlock] {
+		t.Errorf("Expected the block to be fetched with the request's context, got the calls %v", calls.Calls())
+	}
+
+	// and without an echo context
+	if _, jsonErr := initializeProxyETHGetBlockByHash(revoClient).Request(request, nil); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math/big"
 
 	"github.com/labstack/echo"
+	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
 )
@@ -30,7 +31,7 @@ func (p *ProxyETHGetBlockByNumber) Request(rpcReq *eth.JSONRPCRequest, c echo.Co
 func (p *ProxyETHGetBlockByNumber) request(ctx context.Context, req *eth.GetBlockByNumberRequest) (*eth.GetBlockByNumberResponse, eth.JSONRPCError) {
 	blockNum, err := getBlockNumberByRawParam(ctx, p.Revo, req.BlockNumber, false)
 	if err != nil {
-		return nil, eth.NewCallbackError("couldn't get block number by parameter")
+		return nil, eth.WrapCallbackError(err.Error(), "couldn't get block number by parameter")
 	}
 
 	blockHash, jsonErr := proxyETHGetBlockByHash(ctx, p, p.Revo, blockNum)
@@ -50,8 +51,8 @@ func (p *ProxyETHGetBlockByNumber) request(ctx context.Context, req *eth.GetBloc
 	)
 	block, jsonErr := proxy.request(ctx, getBlockByHashReq)
 	if jsonErr != nil {
-		p.GetDebugLogger().Log("function", p.Method(), "msg", "couldn't get block by hash", "err", err)
-		return nil, eth.NewCallbackError("couldn't get block by hash")
+		p.GetDebugLogger().Log("function", p.Method(), "msg", "couldn't get block by hash", "err", jsonErr.Message())
+		return nil, eth.WrapCallbackError(jsonErr.Error(), "couldn't get block by hash")
 	}
 	if blockNum != nil {
 		p.GetDebugLogger().Log("function", p.Method(), "request", string(req.BlockNumber), "msg", "Successfully got block by number", "result", blockNum.String())
@@ -63,7 +64,7 @@ func (p *ProxyETHGetBlockByNumber) request(ctx context.Context, req *eth.GetBloc
 func proxyETHGetBlockByHash(ctx context.Context, p ETHProxy, q *revo.Revo, blockNum *big.Int) (*revo.GetBlockHashResponse, eth.JSONRPCError) {
 	resp, err := q.GetBlockHash(ctx, blockNum)
 	if err != nil {
-		if err == revo.ErrInvalidParameter {
+		if errors.Is(err, revo.ErrInvalidParameter) {
 			// block doesn't exist, ETH rpc returns null
 			/**
 			{
@@ -75,7 +76,7 @@ func proxyETHGetBlockByHash(ctx context.Context, p ETHProxy, q *revo.Revo, block
 			q.GetDebugLogger().Log("function", p.Method(), "request", blockNum.String(), "msg", "Unknown block")
 			return nil, nil
 		}
-		return nil, eth.NewCallbackError("couldn't get block hash")
+		return nil, eth.WrapCallbackError(err, "couldn't get block hash")
 	}
 	return &resp, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/labstack/echo"
+	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
 	"github.com/revolutionchain/charon/pkg/utils"
@@ -33,7 +34,7 @@ func (p *ProxyETHGetCode) request(ctx context.Context, ethreq *eth.GetCodeReques
 
 	revoresp, err := p.GetAccountInfo(ctx, &revoreq)
 	if err != nil {
-		if err == revo.ErrInvalidAddress {
+		if errors.Is(err, revo.ErrInvalidAddress) {
 			/**
 			// correct response for an invalid address
 			{
@@ -44,7 +45,7 @@ func (p *ProxyETHGetCode) request(ctx context.Context, ethreq *eth.GetCodeReques
 			**/
 			return "0x", nil
 		} else {
-			return "", eth.NewCallbackError(err.Error())
+			return "", eth.NewCallbackErrorFrom(err)
 		}
 	}
 
@@ -53,22 +53,31 @@ func (p *ProxyETHGetFilterChanges) requestBlockFilter(ctx context.Context, filte
 
 	blockCountBigInt, blockErr := p.GetBlockCount(ctx)
 	if blockErr != nil {
-		return revoresp, eth.NewCallbackError(blockErr.Error())
+		return revoresp, eth.NewCallbackErrorFrom(blockErr)
 	}
 	blockCount := blockCountBigInt.Uint64()
+	if blockCount <= lastBlockNumber {
+		// no new block, or answered by a revod behind the one which last answered
+		return revoresp, nil
+	}
 
 	differ := blockCount - lastBlockNumber
 
 	hashes := make(eth.GetFilterChangesResponse, differ)
-	for i := range hashes {
+	calls := make([]*revo.BatchCall, differ)
+	for i := range calls {
 		blockNumber := new(big.Int).SetUint64(lastBlockNumber + uint64(i) + 1)
-
-		resp, err := p.GetBlockHash(ctx, blockNumber)
-		if err != nil {
-			return revoresp, eth.NewCallbackError(err.Error())
+		calls[i] = revo.NewGetBlockHashCall(blockNumber)
+	}
+	// in as few requests to revod as it takes
+	if err := p.Batch(ctx, calls); err != nil {
+		return revoresp, eth.NewCallbackErrorFrom(err)
+	}
+	for i, call := range calls {
+		if call.Err != nil {
+			return revoresp, eth.NewCallbackErrorFrom(call.Err)
 		}
-
-		hashes[i] = utils.AddHexPrefix(string(resp))
+		hashes[i] = utils.AddHexPrefix(string(*call.Result.(*revo.GetBlockHashResponse)))
 	}
 
 	revoresp = hashes
@@ -87,13 +96,11 @@ func (p *ProxyETHGetFilterChanges) requestFilter(ctx context.Context, filter *et
 
 	blockCountBigInt, blockErr := p.GetBlockCount(ctx)
 	if blockErr != nil {
-		return revoresp, eth.NewCallbackError(blockErr.Error())
+		return revoresp, eth.NewCallbackErrorFrom(blockErr)
 	}
 	blockCount := blockCountBigInt.Uint64()
-
-	differ := blockCount - lastBlockNumber
-
-	if differ == 0 {
+	if blockCount <= lastBlockNumber {
+		// no new block, or answered by a revod behind the one which last answered
 		return eth.GetFilterChangesResponse{}, nil
 	}
 
@@ -122,3 +122,43 @@ func TestGetFilterChangesRequest_NoSuchFilter(t *testing.T) {
 
 	internal.CheckTestResultEthRequestRPC(*requestRPC, want, got, t, false)
 }
+
+func TestGetFilterChangesRequest_BehindLastBlock(t *testing.T) {
+	for _, filterType := range []eth.FilterType{eth.NewFilterTy, eth.NewBlockFilterTy} {
+		requestParams := []json.RawMessage{[]byte(`"0x1"`)}
+		requestRPC, err := internal.PrepareEthRPCRequest(1, requestParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mockedClientDoer := internal.NewDoerMappedMock()
+		revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// answered by a revod behind the one which answered last time
+		getBlockCountResponse := revo.GetBlockCountResponse{Int: big.NewInt(657650)}
+		err = mockedClientDoer.AddResponseWithRequestID(2, revo.MethodGetBlockCount, getBlockCountResponse)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filterSimulator := eth.NewFilterSimulator()
+		filterSimulator.New(filterType, &eth.NewFilterRequest{})
+		_filter, _ := filterSimulator.Filter(1)
+		filter := _filter.(*eth.Filter)
+		filter.Data.Store("lastBlockNumber", uint64(657655))
+
+		proxyEth := ProxyETHGetFilterChanges{revoClient, filterSimulator}
+		got, jsonErr := proxyEth.Request(requestRPC, internal.NewEchoContext())
+		if jsonErr != nil {
+			t.Fatal(jsonErr)
+		}
+		if len(got.(eth.GetFilterChangesResponse)) != 0 {
+			t.Errorf("Expected no changes, got %v", got)
+		}
+		if lastBlockNumber, _ := filter.Data.Load("lastBlockNumber"); lastBlockNumber.(uint64) != 657655 {
+			t.Errorf("Expected the last block number to stay 657655, got %d", lastBlockNumber)
+		}
+	}
+}
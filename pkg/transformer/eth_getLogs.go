@@ -92,7 +92,7 @@ func (p *ProxyETHGetLogs) ToRequest(ctx context.Context, ethreq *eth.GetLogsRequ
 	//transform EthReq topics to RevoReq topics:
 	topics, topicsErr := eth.TranslateTopics(ethreq.Topics)
 	if topicsErr != nil {
-		return nil, eth.NewCallbackError(topicsErr.Error())
+		return nil, eth.NewCallbackErrorFrom(topicsErr)
 	}
 
 	return &revo.SearchLogsRequest{
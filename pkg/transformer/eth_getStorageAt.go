@@ -46,7 +46,7 @@ func (p *ProxyETHGetStorageAt) Request(rawreq *eth.JSONRPCRequest, c echo.Contex
 func (p *ProxyETHGetStorageAt) request(ctx context.Context, ethreq *revo.GetStorageRequest, index string) (*eth.GetStorageResponse, eth.JSONRPCError) {
 	revoresp, err := p.Revo.GetStorage(ctx, ethreq)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	// revo res -> eth res
@@ -42,7 +42,7 @@ func (p *ProxyETHGetTransactionByBlockNumberAndIndex) request(ctx context.Contex
 
 	blockNum, err := getBlockNumberByParam(ctx, p.Revo, req.BlockNumber, false)
 	if err != nil {
-		return nil, eth.NewCallbackError("couldn't get block number by parameter")
+		return nil, eth.WrapCallbackError(err.Error(), "couldn't get block number by parameter")
 	}
 
 	blockHash, err := proxyETHGetBlockByHash(ctx, p, p.Revo, blockNum)
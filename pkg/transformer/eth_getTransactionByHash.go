@@ -60,7 +60,7 @@ func getTransactionByHash(ctx context.Context, p *revo.Revo, hash string) (*eth.
 	if err != nil {
 		if errors.Cause(err) != revo.ErrInvalidAddress {
 			p.GetDebugLogger().Log("msg", "Failed to GetTransaction", "hash", hash, "err", err)
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewCallbackErrorFrom(err)
 		}
 		var rawRevoTx *revo.GetRawTransactionResponse
 		ethTx, rawRevoTx, err = getRewardTransactionByHash(ctx, p, hash)
@@ -74,7 +74,7 @@ func getTransactionByHash(ctx context.Context, p *revo.Revo, hash string) (*eth.
 					return nil, nil
 				}
 				p.GetDebugLogger().Log("msg", "Failed to GetRawTransaction", "hash", hash, "err", err)
-				return nil, eth.NewCallbackError(err.Error())
+				return nil, eth.NewCallbackErrorFrom(err)
 			} else {
 				p.GetDebugLogger().Log("msg", "Got raw transaction by hash")
 				revoTx = &revo.GetTransactionResponse{
@@ -95,7 +95,7 @@ func getTransactionByHash(ctx context.Context, p *revo.Revo, hash string) (*eth.
 	revoDecodedRawTx, err := p.DecodeRawTransaction(ctx, revoTx.Hex)
 	if err != nil {
 		p.GetDebugLogger().Log("msg", "Failed to DecodeRawTransaction", "hex", revoTx.Hex, "err", err)
-		return nil, eth.NewCallbackError("couldn't get raw transaction")
+		return nil, eth.WrapCallbackError(err, "couldn't get raw transaction")
 	}
 
 	if ethTx == nil {
@@ -117,7 +117,7 @@ func getTransactionByHash(ctx context.Context, p *revo.Revo, hash string) (*eth.
 		blockNumber, err := getBlockNumberByHash(ctx, p, revoTx.BlockHash)
 		if err != nil {
 			p.GetDebugLogger().Log("msg", "Failed to get block number by hash", "hash", revoTx.BlockHash, "err", err)
-			return nil, eth.NewCallbackError("couldn't get block number by hash")
+			return nil, eth.WrapCallbackError(err, "couldn't get block number by hash")
 		}
 		ethTx.BlockNumber = hexutil.EncodeUint64(blockNumber)
 		ethTx.BlockHash = utils.AddHexPrefix(revoTx.BlockHash)
@@ -160,7 +160,7 @@ func getTransactionByHash(ctx context.Context, p *revo.Revo, hash string) (*eth.
 			ethTx.From, err = getNonContractTxSenderAddress(ctx, p, revoDecodedRawTx)
 			if err != nil {
 				p.GetDebugLogger().Log("msg", "Contract tx parsing found no sender address", "tx", revoDecodedRawTx, "err", err)
-				return nil, eth.NewCallbackError("Contract tx parsing found no sender address, and the fallback function also failed: " + err.Error())
+				return nil, eth.WrapCallbackError(err, "Contract tx parsing found no sender address, and the fallback function also failed: "+err.Error())
 			}
 		}
 		//TODO: research if 'To' adress could be other than zero address when 'isContractTx == TRUE'
@@ -286,12 +286,18 @@ func getRewardTransactionByHash(ctx context.Context, p *revo.Revo, hash string)
 		ethTx.BlockHash = utils.AddHexPrefix(rawRevoTx.BlockHash)
 	}
 
+	voutCalls := make([]*revo.BatchCall, len(rawRevoTx.Vouts))
 	for i := range rawRevoTx.Vouts {
+		voutCalls[i] = revo.NewGetTransactionOutCall(hash, i, rawRevoTx.IsPending())
+	}
+	if err := p.Batch(ctx, voutCalls); err != nil {
+		return nil, nil, errors.WithMessage(err, "couldn't get transaction out")
+	}
+	for _, call := range voutCalls {
 		// TODO: discuss
 		// ! The response may be null, even if txout is presented
-		_, err := p.GetTransactionOut(ctx, hash, i, rawRevoTx.IsPending())
-		if err != nil {
-			return nil, nil, errors.WithMessage(err, "couldn't get transaction out")
+		if call.Err != nil {
+			return nil, nil, errors.WithMessage(call.Err, "couldn't get transaction out")
 		}
 		// TODO: discuss, researching
 		// ? Where is a reward amount
@@ -27,7 +27,7 @@ func (p *ProxyETHTxCount) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (i
 	}*/
 	revoresp, err := p.Revo.GetTransactionCount(c.Request().Context(), "", "")
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	// revo res -> eth res
@@ -51,7 +51,7 @@ func (p *ProxyETHGetTransactionReceipt) request(ctx context.Context, req *revo.G
 				return nil, nil
 			}
 			p.Revo.GetDebugLogger().Log("msg", "Transaction does not exist", "txid", string(*req))
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewCallbackErrorFrom(err)
 		}
 		if ethTx == nil {
 			// unconfirmed tx, return nil
@@ -106,12 +106,12 @@ func (p *ProxyETHGetTransactionReceipt) request(ctx context.Context, req *revo.G
 	revoTx, err := p.Revo.GetRawTransaction(ctx, revoReceipt.TransactionHash, false)
 	if err != nil {
 		p.GetDebugLogger().Log("msg", "couldn't get transaction", "err", err)
-		return nil, eth.NewCallbackError("couldn't get transaction")
+		return nil, eth.WrapCallbackError(err, "couldn't get transaction")
 	}
 	decodedRawRevoTx, err := p.Revo.DecodeRawTransaction(ctx, revoTx.Hex)
 	if err != nil {
 		p.GetDebugLogger().Log("msg", "couldn't decode raw transaction", "err", err)
-		return nil, eth.NewCallbackError("couldn't decode raw transaction")
+		return nil, eth.WrapCallbackError(err, "couldn't decode raw transaction")
 	}
 	if decodedRawRevoTx.IsContractCreation() {
 		ethReceipt.To = ""
@@ -26,7 +26,7 @@ func (p *ProxyETHHashrate) Request(_ *eth.JSONRPCRequest, c echo.Context) (inter
 func (p *ProxyETHHashrate) request(ctx context.Context) (*eth.HashrateResponse, eth.JSONRPCError) {
 	revoresp, err := p.Revo.GetHashrate(ctx)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	// revo res -> eth res
@@ -24,7 +24,7 @@ func (p *ProxyETHMining) Request(_ *eth.JSONRPCRequest, c echo.Context) (interfa
 func (p *ProxyETHMining) request(ctx context.Context) (*eth.MiningResponse, eth.JSONRPCError) {
 	revoresp, err := p.Revo.GetMining(ctx)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	// revo res -> eth res
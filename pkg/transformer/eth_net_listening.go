@@ -19,7 +19,7 @@ func (p *ProxyNetListening) Request(rawreq *eth.JSONRPCRequest, c echo.Context)
 	networkInfo, err := p.GetNetworkInfo(c.Request().Context())
 	if err != nil {
 		p.GetDebugLogger().Log("method", p.Method(), "msg", "Failed to query network info", "err", err)
-		return false, eth.NewCallbackError(err.Error())
+		return false, eth.NewCallbackErrorFrom(err)
 	}
 
 	p.GetDebugLogger().Log("method", p.Method(), "network active", networkInfo.NetworkActive)
@@ -25,7 +25,7 @@ func (p *ProxyNetPeerCount) Request(rawreq *eth.JSONRPCRequest, c echo.Context)
 func (p *ProxyNetPeerCount) request(ctx context.Context) (*eth.NetPeerCountResponse, eth.JSONRPCError) {
 	peerInfos, err := p.GetPeerInfo(ctx)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	resp := eth.NetPeerCountResponse(hexutil.EncodeUint64(uint64(len(peerInfos))))
@@ -26,7 +26,7 @@ func (p *ProxyETHNewBlockFilter) Request(rawreq *eth.JSONRPCRequest, c echo.Cont
 func (p *ProxyETHNewBlockFilter) request(ctx context.Context) (eth.NewBlockFilterResponse, eth.JSONRPCError) {
 	blockCount, err := p.GetBlockCount(ctx)
 	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
+		return "", eth.NewCallbackErrorFrom(err)
 	}
 
 	filter := p.filter.New(eth.NewBlockFilterTy)
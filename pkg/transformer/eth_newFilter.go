@@ -50,7 +50,7 @@ func (p *ProxyETHNewFilter) request(ctx context.Context, ethreq *eth.NewFilterRe
 	if len(ethreq.Topics) > 0 {
 		topics, err := eth.TranslateTopics(ethreq.Topics)
 		if err != nil {
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewCallbackErrorFrom(err)
 		}
 		filter.Data.Store("topics", revo.NewSearchLogsTopics(topics))
 	}
@@ -0,0 +1,134 @@
+package transformer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+// ProxyETHPersonalNewAccount implements ETHProxy
+type ProxyETHPersonalNewAccount struct {
+	*revo.Revo
+}
+
+func (p *ProxyETHPersonalNewAccount) Method() string {
+	return "personal_newAccount"
+}
+
+func (p *ProxyETHPersonalNewAccount) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var params []string
+	if err := json.Unmarshal(rawreq.Params, &params); err != nil || len(params) != 1 {
+		return nil, eth.NewInvalidParamsError("expected [passphrase]")
+	}
+
+	address, err := p.KeyStore.NewAccount(params[0])
+	if err != nil {
+		p.GetErrorLogger().Log("method", p.Method(), "msg", "Failed to create account", "error", err)
+		return nil, eth.NewCallbackErrorFrom(err)
+	}
+
+	p.GetLogger().Log("method", p.Method(), "msg", "Created account", "account", address)
+
+	return utils.AddHexPrefix(address), nil
+}
+
+// ProxyETHPersonalImportRawKey implements ETHProxy
+type ProxyETHPersonalImportRawKey struct {
+	*revo.Revo
+}
+
+func (p *ProxyETHPersonalImportRawKey) Method() string {
+	return "personal_importRawKey"
+}
+
+func (p *ProxyETHPersonalImportRawKey) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var params []string
+	if err := json.Unmarshal(rawreq.Params, &params); err != nil || len(params) != 2 {
+		return nil, eth.NewInvalidParamsError("expected [privateKey, passphrase]")
+	}
+
+	key, err := parseRawKey(params[0])
+	if err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	if p.GetAccounts().FindByHexAddress(hex.EncodeToString(btcutil.Hash160(key.PubKey().SerializeCompressed()))) != nil {
+		return nil, eth.NewCallbackError(revo.ErrAccountExists.Error())
+	}
+
+	address, err := p.KeyStore.Import(key, params[1])
+	if err != nil {
+		p.GetErrorLogger().Log("method", p.Method(), "msg", "Failed to import account", "error", err)
+		return nil, eth.NewCallbackErrorFrom(err)
+	}
+
+	p.GetLogger().Log("method", p.Method(), "msg", "Imported account", "account", address)
+
+	return utils.AddHexPrefix(address), nil
+}
+
+// parseRawKey accepts a hex encoded private key or a WIF
+func parseRawKey(raw string) (*btcec.PrivateKey, error) {
+	if hexKey := utils.RemoveHexPrefix(raw); len(hexKey) == 64 {
+		keyBytes, err := hex.DecodeString(hexKey)
+		if err == nil {
+			key, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyBytes)
+			return key, nil
+		}
+	}
+
+	wif, err := btcutil.DecodeWIF(raw)
+	if err != nil {
+		return nil, errors.New("private key must be 32 hex encoded bytes or a WIF")
+	}
+	return wif.PrivKey, nil
+}
+
+// ProxyETHPersonalListAccounts implements ETHProxy
+type ProxyETHPersonalListAccounts struct {
+	*revo.Revo
+}
+
+func (p *ProxyETHPersonalListAccounts) Method() string {
+	return "personal_listAccounts"
+}
+
+func (p *ProxyETHPersonalListAccounts) Request(_ *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	return (&ProxyETHAccounts{Revo: p.Revo}).request(c.Request().Context())
+}
+
+// ProxyETHPersonalLockAccount implements ETHProxy
+type ProxyETHPersonalLockAccount struct {
+	*revo.Revo
+}
+
+func (p *ProxyETHPersonalLockAccount) Method() string {
+	return "personal_lockAccount"
+}
+
+func (p *ProxyETHPersonalLockAccount) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var params []string
+	if err := json.Unmarshal(rawreq.Params, &params); err != nil || len(params) != 1 {
+		return nil, eth.NewInvalidParamsError("expected [address]")
+	}
+
+	addr := strings.ToLower(utils.RemoveHexPrefix(params[0]))
+	if p.GetAccounts().FindByHexAddress(addr) != nil {
+		// plaintext accounts can't be locked
+		return false, nil
+	}
+
+	if err := p.KeyStore.Lock(addr); err != nil {
+		return nil, accountError(addr, err)
+	}
+
+	return true, nil
+}
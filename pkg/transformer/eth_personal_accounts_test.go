@@ -0,0 +1,132 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+)
+
+func TestPersonalImportRawKeyPersistsAccount(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	revoClient.KeyStore, err = revo.NewKeyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.KeyStore.SetLightKDF(true)
+
+	// WIF for 0x7926223070547d2d15b2ef5e7383e541c338ffe9
+	importRequest, err := internal.PrepareEthRPCRequest(1, []json.RawMessage{[]byte(`"cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk"`), []byte(`"passphrase"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, jsonErr := (&ProxyETHPersonalImportRawKey{revoClient}).Request(importRequest, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	address := got.(string)
+
+	accounts, _ := (&ProxyETHAccounts{revoClient}).request(context.Background())
+	if len(accounts) != 1 || accounts[0] != address {
+		t.Fatalf("expected imported account in eth_accounts, got %v", accounts)
+	}
+
+	if _, jsonErr := (&ProxyETHPersonalImportRawKey{revoClient}).Request(importRequest, internal.NewEchoContext()); jsonErr == nil {
+		t.Error("expected importing the same key twice to fail")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected one key file, got %d", len(files))
+	}
+
+	// the account is still there after a restart, and starts locked
+	reloaded, err := revo.NewKeyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Find(address); err != revo.ErrAccountLocked {
+		t.Fatalf("expected %v, got %v", revo.ErrAccountLocked, err)
+	}
+	if err := reloaded.Unlock(address, "passphrase", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersonalProxiesCanBeRestrictedToLocalhost(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listAccounts ETHProxy
+	for _, proxy := range PersonalProxies(revoClient, true) {
+		if proxy.Method() == "personal_listAccounts" {
+			listAccounts = proxy
+		}
+	}
+
+	request, err := internal.PrepareEthRPCRequest(1, []json.RawMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := internal.NewEchoContext()
+	remote.Request().RemoteAddr = "203.0.113.7:50000"
+	if _, jsonErr := listAccounts.Request(request, remote); jsonErr == nil {
+		t.Error("expected remote request to be refused")
+	}
+
+	local := internal.NewEchoContext()
+	local.Request().RemoteAddr = "127.0.0.1:50000"
+	if _, jsonErr := listAccounts.Request(request, local); jsonErr != nil {
+		t.Errorf("expected local request to succeed, got %s", jsonErr.Message())
+	}
+}
+
+func TestUnlockAccountNeedsPersonalAPI(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, proxy := range DefaultProxies(revoClient, nil, nil) {
+		if proxy.Method() == "personal_unlockAccount" {
+			t.Fatal("personal_unlockAccount is exposed without the personal API")
+		}
+	}
+
+	var unlock ETHProxy
+	for _, proxy := range PersonalProxies(revoClient, true) {
+		if proxy.Method() == "personal_unlockAccount" {
+			unlock = proxy
+		}
+	}
+	if unlock == nil {
+		t.Fatal("personal_unlockAccount isn't part of the personal API")
+	}
+
+	request, err := internal.PrepareEthRPCRequest(1, []json.RawMessage{[]byte(`"0x7e22630f90e6db16283af2c6b04f688117a55db4"`), []byte(`"passphrase"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := internal.NewEchoContext()
+	remote.Request().RemoteAddr = "203.0.113.7:50000"
+	if _, jsonErr := unlock.Request(request, remote); jsonErr == nil {
+		t.Error("expected remote request to be refused")
+	}
+}
@@ -0,0 +1,62 @@
+package transformer
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+// ProxyETHPersonalSign implements ETHProxy
+type ProxyETHPersonalSign struct {
+	*revo.Revo
+}
+
+func (p *ProxyETHPersonalSign) Method() string {
+	return "personal_sign"
+}
+
+func (p *ProxyETHPersonalSign) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var req eth.PersonalSignRequest
+	if err := unmarshalRequest(rawreq.Params, &req); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+	// decrypting a key for any caller would let them guess its passphrase as fast as they like
+	if req.Password != "" {
+		return nil, eth.NewInvalidParamsError("personal_sign doesn't take a password, unlock the account with personal_unlockAccount")
+	}
+
+	addr := strings.ToLower(utils.RemoveHexPrefix(req.Account))
+
+	sig, jsonErr := signMessage(c.Request().Context(), p.Revo, addr, req.Message)
+	if jsonErr != nil {
+		p.GetDebugLogger().Log("method", p.Method(), "account", addr, "msg", "Failed to sign message", "error", jsonErr.Error())
+		return nil, jsonErr
+	}
+
+	return eth.PersonalSignResponse(utils.AddHexPrefix(hex.EncodeToString(sig))), nil
+}
+
+// ProxyETHPersonalECRecover implements ETHProxy
+type ProxyETHPersonalECRecover struct{}
+
+func (p *ProxyETHPersonalECRecover) Method() string {
+	return "personal_ecRecover"
+}
+
+func (p *ProxyETHPersonalECRecover) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var req eth.PersonalECRecoverRequest
+	if err := unmarshalRequest(rawreq.Params, &req); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	address, err := revo.RecoverCompactAddress(req.Signature, revo.MessageHash(req.Message))
+	if err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	return eth.PersonalECRecoverResponse(utils.AddHexPrefix(address)), nil
+}
@@ -0,0 +1,80 @@
+package transformer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+func TestPersonalSignRecoversToSigner(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.Accounts = append(revoClient.Accounts, wif)
+	from := utils.AddHexPrefix((&revo.Account{WIF: wif}).ToHexAddress())
+
+	// "Sign in to example.com"
+	message := "0x5369676e20696e20746f206578616d706c652e636f6d"
+
+	signParams, _ := json.Marshal([]string{message, from, ""})
+	signRequest := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "personal_sign", Params: signParams}
+	sig, jsonErr := (&ProxyETHPersonalSign{revoClient}).Request(signRequest, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+
+	// personal_sign signs the same as eth_sign with the parameters reversed
+	ethSignParams, _ := json.Marshal([]string{from, message})
+	ethSignRequest := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_sign", Params: ethSignParams}
+	ethSig, jsonErr := (&ProxyETHSign{revoClient}).Request(ethSignRequest, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	if string(sig.(eth.PersonalSignResponse)) != string(ethSig.(eth.SignResponse)) {
+		t.Errorf("expected personal_sign and eth_sign signatures to match")
+	}
+
+	recoverParams, _ := json.Marshal([]string{message, string(sig.(eth.PersonalSignResponse))})
+	recoverRequest := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "personal_ecRecover", Params: recoverParams}
+	got, jsonErr := (&ProxyETHPersonalECRecover{}).Request(recoverRequest, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	if got != eth.PersonalECRecoverResponse(from) {
+		t.Errorf("expected the signature to recover to %s, got %s", from, got)
+	}
+}
+
+func TestPersonalSignRefusesPassword(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.Accounts = append(revoClient.Accounts, wif)
+	from := utils.AddHexPrefix((&revo.Account{WIF: wif}).ToHexAddress())
+
+	params, _ := json.Marshal([]string{"0x5369676e20696e20746f206578616d706c652e636f6d", from, "secret"})
+	request := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "personal_sign", Params: params}
+	_, jsonErr := (&ProxyETHPersonalSign{revoClient}).Request(request, internal.NewEchoContext())
+	if jsonErr == nil || jsonErr.Code() != eth.InvalidParamsErrorCode {
+		t.Errorf("Expected a password to be refused with an invalid params error, got %v", jsonErr)
+	}
+}
@@ -1,17 +1,52 @@
 package transformer
 
 import (
+	"strings"
+	"time"
+
 	"github.com/labstack/echo"
 	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
 )
 
+// same default as geth
+var DefaultUnlockDuration = 300 * time.Second
+
 // ProxyETHPersonalUnlockAccount implements ETHProxy
-type ProxyETHPersonalUnlockAccount struct{}
+type ProxyETHPersonalUnlockAccount struct {
+	*revo.Revo
+}
 
 func (p *ProxyETHPersonalUnlockAccount) Method() string {
 	return "personal_unlockAccount"
 }
 
-func (p *ProxyETHPersonalUnlockAccount) Request(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+func (p *ProxyETHPersonalUnlockAccount) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var req eth.PersonalUnlockAccountRequest
+	if err := unmarshalRequest(rawreq.Params, &req); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	// a duration of 0 keeps the account unlocked until personal_lockAccount or a restart
+	duration := DefaultUnlockDuration
+	if req.Duration != nil && req.Duration.Int != nil {
+		if req.Duration.Sign() < 0 || !req.Duration.IsInt64() || req.Duration.Int64() > int64(time.Duration(1<<63-1)/time.Second) {
+			return nil, eth.NewInvalidParamsError("invalid duration")
+		}
+		duration = time.Duration(req.Duration.Int64()) * time.Second
+	}
+
+	addr := strings.ToLower(utils.RemoveHexPrefix(req.Address))
+	if acc := p.GetAccounts().FindByHexAddress(addr); acc != nil {
+		// plaintext accounts are always unlocked
+		return eth.PersonalUnlockAccountResponse(true), nil
+	}
+
+	if err := p.KeyStore.Unlock(addr, req.Passphrase, duration); err != nil {
+		p.GetDebugLogger().Log("method", p.Method(), "account", addr, "msg", "Failed to unlock account", "error", err)
+		return nil, accountError(addr, err)
+	}
+
 	return eth.PersonalUnlockAccountResponse(true), nil
 }
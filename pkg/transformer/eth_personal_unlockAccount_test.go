@@ -0,0 +1,81 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+)
+
+func TestLockedAccountCannotSignUntilUnlocked(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "0x" + (&revo.Account{WIF: wif}).ToHexAddress()
+
+	keyJSON, err := revo.EncryptKeyV3(wif.PrivKey, address, "passphrase", revo.LightScryptN, revo.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.KeyStore, err = revo.NewKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := revoClient.KeyStore.AddKeyJSON(keyJSON, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, jsonErr := (&ProxyETHAccounts{revoClient}).request(context.Background())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	if len(accounts) != 1 || accounts[0] != address {
+		t.Fatalf("expected locked account to be listed, got %v", accounts)
+	}
+
+	signRequest, err := internal.PrepareEthRPCRequest(1, []json.RawMessage{[]byte(`"` + address + `"`), []byte(`"0x68656c6c6f"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := &ProxyETHSign{revoClient}
+
+	if _, jsonErr := sign.Request(signRequest, internal.NewEchoContext()); jsonErr == nil || jsonErr.Message() != revo.ErrAccountLocked.Error() {
+		t.Fatalf("expected locked account error, got %v", jsonErr)
+	}
+
+	unlock := &ProxyETHPersonalUnlockAccount{revoClient}
+	wrongPassphrase, err := internal.PrepareEthRPCRequest(2, []json.RawMessage{[]byte(`"` + address + `"`), []byte(`"wrong"`), []byte(`60`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, jsonErr := unlock.Request(wrongPassphrase, internal.NewEchoContext()); jsonErr == nil {
+		t.Fatal("expected unlocking with the wrong passphrase to fail")
+	}
+
+	unlockRequest, err := internal.PrepareEthRPCRequest(3, []json.RawMessage{[]byte(`"` + address + `"`), []byte(`"passphrase"`), []byte(`60`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, jsonErr := unlock.Request(unlockRequest, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	if got != eth.PersonalUnlockAccountResponse(true) {
+		t.Fatalf("unexpected response %v", got)
+	}
+
+	if _, jsonErr := sign.Request(signRequest, internal.NewEchoContext()); jsonErr != nil {
+		t.Fatalf("expected unlocked account to sign, got %s", jsonErr.Message())
+	}
+}
@@ -4,14 +4,17 @@ import (
 	"context"
 
 	"github.com/labstack/echo"
+	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/txtracker"
 	"github.com/revolutionchain/charon/pkg/utils"
 )
 
 // ProxyETHSendRawTransaction implements ETHProxy
 type ProxyETHSendRawTransaction struct {
 	*revo.Revo
+	tracker *txtracker.Tracker
 }
 
 var _ ETHProxy = (*ProxyETHSendRawTransaction)(nil)
@@ -40,21 +43,30 @@ func (p *ProxyETHSendRawTransaction) request(ctx context.Context, params eth.Sen
 		req            = revo.SendRawTransactionRequest([1]string{revoHexedRawTx})
 	)
 
+	trackedHash, trackErr := p.tracker.Queue(revoHexedRawTx)
+	if trackErr != nil {
+		// revod will reject it as well
+		p.GetDebugLogger().Log("method", p.Method(), "msg", "Failed to track raw transaction", "error", trackErr)
+	}
+
 	revoresp, err := p.Revo.SendRawTransaction(ctx, &req)
 	if err != nil {
-		if err == revo.ErrVerifyAlreadyInChain {
+		if errors.Is(err, revo.ErrVerifyAlreadyInChain) {
+			p.tracker.Broadcasted(trackedHash)
 			// already committed
 			// we need to send back the tx hash
 			rawTx, err := p.Revo.DecodeRawTransaction(ctx, revoHexedRawTx)
 			if err != nil {
 				p.GetErrorLogger().Log("msg", "Error decoding raw transaction for duplicate raw transaction", "err", err)
-				return eth.SendRawTransactionResponse(""), eth.NewCallbackError(err.Error())
+				return eth.SendRawTransactionResponse(""), eth.NewRevodError(err)
 			}
 			revoresp = &revo.SendRawTransactionResponse{Result: rawTx.Hash}
 		} else {
-			return eth.SendRawTransactionResponse(""), eth.NewCallbackError(err.Error())
+			p.tracker.Forget(trackedHash)
+			return eth.SendRawTransactionResponse(""), eth.NewRevodError(err)
 		}
 	} else {
+		p.tracker.Broadcasted(trackedHash)
 		p.GenerateIfPossible()
 	}
 
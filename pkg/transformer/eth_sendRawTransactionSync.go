@@ -0,0 +1,122 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+var (
+	DefaultSyncTransactionTimeout = 30 * time.Second
+	MaxSyncTransactionTimeout     = 5 * time.Minute
+
+	syncTransactionReceiptPollInterval = 500 * time.Millisecond
+)
+
+// ProxyETHSendRawTransactionSync implements ETHProxy
+type ProxyETHSendRawTransactionSync struct {
+	*ProxyETHSendRawTransaction
+	receipts *ProxyETHGetTransactionReceipt
+}
+
+func (p *ProxyETHSendRawTransactionSync) Method() string {
+	return "eth_sendRawTransactionSync"
+}
+
+func (p *ProxyETHSendRawTransactionSync) Request(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var params eth.SendRawTransactionSyncRequest
+	if err := unmarshalRequest(req.Params, &params); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+	if params.RawTx == "" {
+		return nil, eth.NewInvalidParamsError("invalid parameter: raw transaction hexed string is empty")
+	}
+
+	timeout, jsonErr := syncTransactionTimeout(params.Timeout)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	ctx := c.Request().Context()
+	hash, jsonErr := p.ProxyETHSendRawTransaction.request(ctx, eth.SendRawTransactionRequest{params.RawTx})
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	return waitForReceipt(ctx, p.receipts, string(hash), timeout)
+}
+
+// ProxyETHSendTransactionSync implements ETHProxy
+type ProxyETHSendTransactionSync struct {
+	*ProxyETHSendTransaction
+	receipts *ProxyETHGetTransactionReceipt
+}
+
+func (p *ProxyETHSendTransactionSync) Method() string {
+	return "eth_sendTransactionSync"
+}
+
+func (p *ProxyETHSendTransactionSync) Request(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var params eth.SendTransactionSyncRequest
+	if err := unmarshalRequest(req.Params, &params); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	timeout, jsonErr := syncTransactionTimeout(params.Timeout)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	ctx := c.Request().Context()
+	hash, jsonErr := p.ProxyETHSendTransaction.request(ctx, &params.Transaction)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	return waitForReceipt(ctx, p.receipts, string(*hash), timeout)
+}
+
+// syncTransactionTimeout converts the optional timeout parameter, given in milliseconds
+func syncTransactionTimeout(timeout *eth.ETHInt) (time.Duration, eth.JSONRPCError) {
+	if timeout == nil || timeout.Int == nil {
+		return DefaultSyncTransactionTimeout, nil
+	}
+	if timeout.Sign() <= 0 || !timeout.IsInt64() {
+		return 0, eth.NewInvalidParamsError("invalid parameter: timeout must be a positive number of milliseconds")
+	}
+
+	duration := time.Duration(timeout.Int64()) * time.Millisecond
+	if timeout.Int64() > MaxSyncTransactionTimeout.Milliseconds() {
+		return 0, eth.NewInvalidParamsError(fmt.Sprintf("invalid parameter: timeout must be at most %d milliseconds", MaxSyncTransactionTimeout.Milliseconds()))
+	}
+
+	return duration, nil
+}
+
+// waitForReceipt polls for the receipt of 'hash' until it is available or 'timeout' expires
+func waitForReceipt(ctx context.Context, receipts *ProxyETHGetTransactionReceipt, hash string, timeout time.Duration) (*eth.GetTransactionReceiptResponse, eth.JSONRPCError) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req := revo.GetTransactionReceiptRequest(utils.RemoveHexPrefix(hash))
+	for {
+		receipt, jsonErr := receipts.request(ctx, &req)
+		if jsonErr != nil && ctx.Err() == nil {
+			return nil, jsonErr
+		}
+		if receipt != nil {
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, eth.NewTransactionTimeoutError(utils.AddHexPrefix(hash), timeout)
+		case <-time.After(syncTransactionReceiptPollInterval):
+		}
+	}
+}
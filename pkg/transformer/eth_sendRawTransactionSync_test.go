@@ -0,0 +1,112 @@
+package transformer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+func TestSendRawTransactionSyncReturnsReceipt(t *testing.T) {
+	requestParams := []json.RawMessage{[]byte(`"0x0200000001"`), []byte(`1000`)}
+	request, err := internal.PrepareEthRPCRequest(1, requestParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txHash := "8fcd819194cce6a8454b2bec334d3448df4f097e9cdc36707bfd569900268950"
+	if err = mockedClientDoer.AddResponse(revo.MethodSendRawTx, txHash); err != nil {
+		t.Fatal(err)
+	}
+	if err = mockedClientDoer.AddResponse(revo.MethodGetTransactionReceipt, []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	if err = mockedClientDoer.AddResponse(revo.MethodGetRawTransaction, &revo.GetRawTransactionResponse{
+		BlockHash: internal.GetTransactionByHashBlockHash,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mockedClientDoer.AddResponse(revo.MethodGetBlock, internal.GetBlockResponse); err != nil {
+		t.Fatal(err)
+	}
+
+	proxyEth := ProxyETHSendRawTransactionSync{
+		ProxyETHSendRawTransaction: &ProxyETHSendRawTransaction{Revo: revoClient},
+		receipts:                   &ProxyETHGetTransactionReceipt{Revo: revoClient},
+	}
+	got, jsonErr := proxyEth.Request(request, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+
+	want := eth.GetTransactionReceiptResponse{
+		TransactionHash:   utils.AddHexPrefix(txHash),
+		TransactionIndex:  "0x1",
+		BlockHash:         "0xbba11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5",
+		BlockNumber:       "0xf8f",
+		GasUsed:           NonContractVMGasLimit,
+		Logs:              []eth.Log{},
+		EffectiveGasPrice: "0x0",
+		CumulativeGasUsed: NonContractVMGasLimit,
+		To:                utils.AddHexPrefix(revo.ZeroAddress),
+		From:              utils.AddHexPrefix(revo.ZeroAddress),
+		LogsBloom:         eth.EmptyLogsBloom,
+		Status:            STATUS_SUCCESS,
+	}
+
+	internal.CheckTestResultEthRequestRPC(*request, &want, got, t, false)
+}
+
+func TestSendRawTransactionSyncTimesOut(t *testing.T) {
+	pollInterval := syncTransactionReceiptPollInterval
+	syncTransactionReceiptPollInterval = time.Millisecond
+	defer func() {
+		syncTransactionReceiptPollInterval = pollInterval
+	}()
+
+	requestParams := []json.RawMessage{[]byte(`"0x0200000001"`), []byte(`"0x32"`)}
+	request, err := internal.PrepareEthRPCRequest(1, requestParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txHash := "8fcd819194cce6a8454b2bec334d3448df4f097e9cdc36707bfd569900268950"
+	if err = mockedClientDoer.AddResponse(revo.MethodSendRawTx, txHash); err != nil {
+		t.Fatal(err)
+	}
+	if err = mockedClientDoer.AddResponse(revo.MethodGetTransactionReceipt, []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	// still in the mempool
+	if err = mockedClientDoer.AddResponse(revo.MethodGetRawTransaction, &revo.GetRawTransactionResponse{}); err != nil {
+		t.Fatal(err)
+	}
+
+	proxyEth := ProxyETHSendRawTransactionSync{
+		ProxyETHSendRawTransaction: &ProxyETHSendRawTransaction{Revo: revoClient},
+		receipts:                   &ProxyETHGetTransactionReceipt{Revo: revoClient},
+	}
+	_, jsonErr := proxyEth.Request(request, internal.NewEchoContext())
+	if jsonErr == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if jsonErr.Code() != eth.TransactionTimeoutErrorCode {
+		t.Errorf("expected error code %d, got %d: %s", eth.TransactionTimeoutErrorCode, jsonErr.Code(), jsonErr.Message())
+	}
+}
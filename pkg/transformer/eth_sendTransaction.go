@@ -1,9 +1,14 @@
 package transformer
 
 import (
+	"context"
+	"strings"
+	"sync"
+
 	"github.com/labstack/echo"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/txtracker"
 	"github.com/revolutionchain/charon/pkg/utils"
 	"github.com/shopspring/decimal"
 )
@@ -13,6 +18,45 @@ var MinimumGasLimit = int64(22000)
 // ProxyETHSendTransaction implements ETHProxy
 type ProxyETHSendTransaction struct {
 	*revo.Revo
+	tracker *txtracker.Tracker
+	senders senderLocks
+}
+
+// senderLocks serializes the locally signed transactions of each sender, from picking their inputs until they are
+// broadcast, so that two transactions don't spend the same outputs
+type senderLocks struct {
+	mutex sync.Mutex
+	locks map[string]*senderLock
+}
+
+type senderLock struct {
+	sync.Mutex
+	users int
+}
+
+// lock waits for the other transactions of 'sender' to be done and returns the function releasing the lock
+func (l *senderLocks) lock(sender string) (unlock func()) {
+	l.mutex.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*senderLock)
+	}
+	lock, ok := l.locks[sender]
+	if !ok {
+		lock = &senderLock{}
+		l.locks[sender] = lock
+	}
+	lock.users++
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mutex.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(l.locks, sender)
+		}
+		l.mutex.Unlock()
+	}
 }
 
 func (p *ProxyETHSendTransaction) Method() string {
@@ -27,28 +71,85 @@ func (p *ProxyETHSendTransaction) Request(rawreq *eth.JSONRPCRequest, c echo.Con
 		return nil, eth.NewInvalidParamsError(err.Error())
 	}
 
+	result, jsonErr := p.request(c.Request().Context(), &req)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	return result, nil
+}
+
+func (p *ProxyETHSendTransaction) request(ctx context.Context, req *eth.SendTransactionRequest) (*eth.SendTransactionResponse, eth.JSONRPCError) {
 	if req.Gas != nil && req.Gas.Int64() < MinimumGasLimit {
 		p.GetLogger().Log("msg", "Gas limit is too low", "gasLimit", req.Gas.String())
 	}
 
-	var result interface{}
+	var result *eth.SendTransactionResponse
 	var jsonErr eth.JSONRPCError
 
-	if req.IsCreateContract() {
-		result, jsonErr = p.requestCreateContract(&req)
+	hosted := p.IsHostedAccount(req.From)
+	if !hosted {
+		var err error
+		if hosted, err = p.IsSignerAccount(ctx, req.From); err != nil {
+			return nil, eth.NewRevodError(err)
+		}
+	}
+
+	if hosted {
+		// we or the external signer hold the key, so sign locally instead of relying on revod's wallet
+		result, jsonErr = p.requestSignedLocally(ctx, req)
+	} else if req.IsCreateContract() {
+		result, jsonErr = p.requestCreateContract(req)
 	} else if req.IsSendEther() {
-		result, jsonErr = p.requestSendToAddress(&req)
+		result, jsonErr = p.requestSendToAddress(req)
 	} else if req.IsCallContract() {
-		result, jsonErr = p.requestSendToContract(&req)
+		result, jsonErr = p.requestSendToContract(req)
 	} else {
 		return nil, eth.NewInvalidParamsError("Unknown operation")
 	}
 
-	if err == nil {
-		p.GenerateIfPossible()
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	// no-op for locally signed transactions, which were tracked before they were broadcast
+	p.tracker.TrackHash(string(*result))
+
+	p.GenerateIfPossible()
+
+	return result, nil
+}
+
+func (p *ProxyETHSendTransaction) requestSignedLocally(ctx context.Context, req *eth.SendTransactionRequest) (*eth.SendTransactionResponse, eth.JSONRPCError) {
+	if !req.IsCreateContract() && !req.IsSendEther() && !req.IsCallContract() {
+		return nil, eth.NewInvalidParamsError("Unknown operation")
+	}
+
+	// held until the transaction is broadcast, revod only then sees the outputs it spends as spent
+	unlock := p.senders.lock(strings.ToLower(utils.RemoveHexPrefix(req.From)))
+	defer unlock()
+
+	signer := &ProxyETHSignTransaction{Revo: p.Revo}
+	rawTx, jsonErr := signer.signTransaction(ctx, req)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	hash, err := p.tracker.Queue(rawTx)
+	if err != nil {
+		return nil, eth.NewRevodError(err)
 	}
 
-	return result, jsonErr
+	revoreq := revo.SendRawTransactionRequest([1]string{rawTx})
+	revoresp, err := p.Revo.SendRawTransaction(ctx, &revoreq)
+	if err != nil {
+		p.tracker.Forget(hash)
+		return nil, eth.NewRevodError(err)
+	}
+	p.tracker.Broadcasted(hash)
+
+	ethresp := eth.SendTransactionResponse(utils.AddHexPrefix(revoresp.Result))
+	return &ethresp, nil
 }
 
 func (p *ProxyETHSendTransaction) requestSendToContract(ethtx *eth.SendTransactionRequest) (*eth.SendTransactionResponse, eth.JSONRPCError) {
@@ -77,14 +178,14 @@ func (p *ProxyETHSendTransaction) requestSendToContract(ethtx *eth.SendTransacti
 	if from := ethtx.From; from != "" && utils.IsEthHexAddress(from) {
 		from, err = p.FromHexAddress(from)
 		if err != nil {
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewRevodError(err)
 		}
 		revoreq.SenderAddress = from
 	}
 
 	var resp *revo.SendToContractResponse
 	if err := p.Revo.Request(revo.MethodSendToContract, &revoreq, &resp); err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewRevodError(err)
 	}
 
 	ethresp := eth.SendTransactionResponse(utils.AddHexPrefix(resp.Txid))
@@ -131,7 +232,7 @@ func (p *ProxyETHSendTransaction) requestSendToAddress(req *eth.SendTransactionR
 		// }
 		// this can happen if there are enough coins but some required are untrusted
 		// you can get the trusted coin balance via getbalances rpc call
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewRevodError(err)
 	}
 
 	ethresp := eth.SendTransactionResponse(utils.AddHexPrefix(string(revoresp)))
@@ -156,7 +257,7 @@ func (p *ProxyETHSendTransaction) requestCreateContract(req *eth.SendTransaction
 		if utils.IsEthHexAddress(from) {
 			from, err = p.FromHexAddress(from)
 			if err != nil {
-				return nil, eth.NewCallbackError(err.Error())
+				return nil, eth.NewRevodError(err)
 			}
 		}
 
@@ -165,7 +266,7 @@ func (p *ProxyETHSendTransaction) requestCreateContract(req *eth.SendTransaction
 
 	var resp *revo.CreateContractResponse
 	if err := p.Revo.Request(revo.MethodCreateContract, revoreq, &resp); err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewRevodError(err)
 	}
 
 	ethresp := eth.SendTransactionResponse(utils.AddHexPrefix(string(resp.Txid)))
@@ -0,0 +1,118 @@
+package transformer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+	"github.com/shopspring/decimal"
+)
+
+// sentTxDoer keeps the raw transactions sent to revod through 'Doer'
+type sentTxDoer struct {
+	internal.Doer
+	sent []string
+}
+
+func (d *sentTxDoer) Do(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var call eth.JSONRPCRequest
+	var params []string
+	if json.Unmarshal(body, &call) == nil && call.Method == revo.MethodSendRawTx && json.Unmarshal(call.Params, &params) == nil {
+		d.sent = append(d.sent, params...)
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return d.Doer.Do(req)
+}
+
+func TestSendTransactionSkipsOutputsSpentInMempool(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	doer := &sentTxDoer{Doer: mockedClientDoer}
+	revoClient, err := internal.CreateMockedClient(doer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.Accounts = append(revoClient.Accounts, wif)
+	from := (&revo.Account{WIF: wif}).ToHexAddress()
+
+	pkScript, err := revo.PayToPubKeyHashScript(btcutil.Hash160(wif.SerializePubKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxo := func(txID string) revo.UTXO {
+		return revo.UTXO{
+			TXID:     strings.Repeat(txID, 64),
+			Script:   hex.EncodeToString(pkScript),
+			Satoshis: decimal.NewFromInt(200000000),
+			Height:   big.NewInt(1),
+		}
+	}
+	// getaddressutxos only sees confirmed transactions, both sends get both outputs
+	if err := mockedClientDoer.AddResponse(revo.MethodGetAddressUTXOs, []revo.UTXO{utxo("a"), utxo("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mockedClientDoer.AddResponse(revo.MethodGetBlockCount, signTransactionTestHeight); err != nil {
+		t.Fatal(err)
+	}
+	// once the first send is in the mempool, gettxout has its input as spent
+	for _, txOut := range []interface{}{
+		revo.GetTransactionOutResponse{BestBlockHash: "best"}, revo.GetTransactionOutResponse{BestBlockHash: "best"},
+		[]byte("null"), revo.GetTransactionOutResponse{BestBlockHash: "best"},
+	} {
+		if err := mockedClientDoer.AddResponse(revo.MethodGetTransactionOut, txOut); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mockedClientDoer.AddResponse(revo.MethodSendRawTx, strings.Repeat("c", 64)); err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := json.Marshal([]interface{}{map[string]string{
+		"from":  utils.AddHexPrefix(from),
+		"to":    "0x7e22630f90e6db16283af2c6b04f688117a55db4",
+		"value": "0xde0b6b3a7640000", // 1 REVO
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_sendTransaction", Params: params}
+
+	proxyEth := ProxyETHSendTransaction{Revo: revoClient}
+	for i := 0; i < 2; i++ {
+		if _, jsonErr := proxyEth.Request(request, internal.NewEchoContext()); jsonErr != nil {
+			t.Fatal(jsonErr.Message())
+		}
+	}
+
+	var spent []string
+	for _, rawTx := range doer.sent {
+		tx, err := revo.DeserializeTx(rawTx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, in := range tx.TxIn {
+			spent = append(spent, in.PreviousOutPoint.Hash.String()[:1])
+		}
+	}
+	if len(spent) != 2 || spent[0] != "a" || spent[1] != "b" {
+		t.Errorf("expected each send to spend its own output, got %v", spent)
+	}
+}
@@ -1,13 +1,9 @@
 package transformer
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
 	"encoding/hex"
-	"fmt"
 
-	"github.com/btcsuite/btcd/btcec"
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/labstack/echo"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
@@ -33,16 +29,10 @@ func (p *ProxyETHSign) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (inte
 
 	addr := utils.RemoveHexPrefix(req.Account)
 
-	acc := p.Revo.Accounts.FindByHexAddress(addr)
-	if acc == nil {
-		p.GetDebugLogger().Log("method", p.Method(), "account", addr, "msg", "Unknown account")
-		return nil, eth.NewInvalidParamsError(fmt.Sprintf("No such account: %s", addr))
-	}
-
-	sig, err := signMessage(acc.PrivKey, req.Message)
-	if err != nil {
-		p.GetDebugLogger().Log("method", p.Method(), "msg", "Failed to sign message", "error", err)
-		return nil, eth.NewCallbackError(err.Error())
+	sig, jsonErr := signMessage(c.Request().Context(), p.Revo, addr, req.Message)
+	if jsonErr != nil {
+		p.GetDebugLogger().Log("method", p.Method(), "account", addr, "msg", "Failed to sign message", "error", jsonErr.Error())
+		return nil, jsonErr
 	}
 
 	p.GetDebugLogger().Log("method", p.Method(), "msg", "Successfully signed message")
@@ -50,26 +40,29 @@ func (p *ProxyETHSign) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (inte
 	return eth.SignResponse("0x" + hex.EncodeToString(sig)), nil
 }
 
-func signMessage(key *btcec.PrivateKey, msg []byte) ([]byte, error) {
-	msghash := chainhash.DoubleHashB(paddedMessage(msg))
-
-	secp256k1 := btcec.S256()
-
-	return btcec.SignCompact(secp256k1, key, msghash, true)
-}
-
-var revoSignMessagePrefix = []byte("\u0015Revo Signed Message:\n")
-
-func paddedMessage(msg []byte) []byte {
-	var wbuf bytes.Buffer
-
-	wbuf.Write(revoSignMessagePrefix)
-
-	var msglenbuf [binary.MaxVarintLen64]byte
-	msglen := binary.PutUvarint(msglenbuf[:], uint64(len(msg)))
-
-	wbuf.Write(msglenbuf[:msglen])
-	wbuf.Write(msg)
+// signMessage signs 'msg' with the hosted key of the hex address 'addr', or has the external signer sign it if it holds the key
+func signMessage(ctx context.Context, r *revo.Revo, addr string, msg []byte) ([]byte, eth.JSONRPCError) {
+	acc, err := r.FindAccount(addr)
+	if err == revo.ErrUnknownAccount {
+		external, signerErr := r.IsSignerAccount(ctx, addr)
+		if signerErr != nil {
+			return nil, eth.NewCallbackErrorFrom(signerErr)
+		}
+		if external {
+			sig, err := r.Signer.SignMessage(ctx, addr, msg)
+			if err != nil {
+				return nil, eth.NewCallbackErrorFrom(err)
+			}
+			return sig, nil
+		}
+	}
+	if err != nil {
+		return nil, accountError(addr, err)
+	}
 
-	return wbuf.Bytes()
+	sig, err := revo.SignMessage(acc.PrivKey, msg)
+	if err != nil {
+		return nil, eth.NewCallbackErrorFrom(err)
+	}
+	return sig, nil
 }
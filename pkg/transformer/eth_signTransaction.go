@@ -2,17 +2,22 @@ package transformer
 
 import (
 	"context"
-	"fmt"
+	"encoding/hex"
+	"math/big"
 	"strings"
 
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/btcd/txscript"
+	"github.com/revolutionchain/btcd/wire"
 	"github.com/revolutionchain/charon/pkg/eth"
 	"github.com/revolutionchain/charon/pkg/revo"
 	"github.com/revolutionchain/charon/pkg/utils"
 	"github.com/shopspring/decimal"
 )
 
-// ProxyETHSendTransaction implements ETHProxy
+// ProxyETHSignTransaction implements ETHProxy
 type ProxyETHSignTransaction struct {
 	*revo.Revo
 }
@@ -28,235 +33,251 @@ func (p *ProxyETHSignTransaction) Request(rawreq *eth.JSONRPCRequest, c echo.Con
 		return nil, eth.NewInvalidParamsError(err.Error())
 	}
 
-	ctx := c.Request().Context()
-
-	if req.IsCreateContract() {
-		p.GetDebugLogger().Log("method", p.Method(), "msg", "transaction is a create contract request")
-		return p.requestCreateContract(ctx, &req)
-	} else if req.IsSendEther() {
-		p.GetDebugLogger().Log("method", p.Method(), "msg", "transaction is a send ether request")
-		return p.requestSendToAddress(ctx, &req)
-	} else if req.IsCallContract() {
-		p.GetDebugLogger().Log("method", p.Method(), "msg", "transaction is a call contract request")
-		return p.requestSendToContract(ctx, &req)
-	} else {
+	if !req.IsCreateContract() && !req.IsSendEther() && !req.IsCallContract() {
 		p.GetDebugLogger().Log("method", p.Method(), "msg", "transaction is an unknown request")
+		return nil, eth.NewInvalidParamsError("Unknown operation")
+	}
+
+	rawTx, jsonErr := p.signTransaction(c.Request().Context(), &req)
+	if jsonErr != nil {
+		return nil, jsonErr
 	}
 
-	return nil, eth.NewInvalidParamsError("Unknown operation")
+	return utils.AddHexPrefix(rawTx), nil
 }
 
-func (p *ProxyETHSignTransaction) getRequiredUtxos(ctx context.Context, from string, neededAmount decimal.Decimal) ([]revo.RawTxInputs, decimal.Decimal, error) {
-	//convert address to revo address
-	addr := utils.RemoveHexPrefix(from)
-	base58Addr, err := p.FromHexAddress(addr)
-	if err != nil {
-		return nil, decimal.Decimal{}, err
+// signTransaction builds a Revo transaction for 'ethtx' and signs it with the hosted account's key, or has the external
+// signer sign it, without going through revod's wallet. Returns the hex encoded signed transaction
+func (p *ProxyETHSignTransaction) signTransaction(ctx context.Context, ethtx *eth.SendTransactionRequest) (string, eth.JSONRPCError) {
+	fromAddr := strings.ToLower(utils.RemoveHexPrefix(ethtx.From))
+	acc, err := p.Revo.FindAccount(fromAddr)
+	if err == revo.ErrUnknownAccount {
+		external, signerErr := p.IsSignerAccount(ctx, fromAddr)
+		if signerErr != nil {
+			return "", eth.NewCallbackErrorFrom(signerErr)
+		}
+		if external {
+			err = nil
+		}
 	}
-	// need to get utxos with txid and vouts. In order to do this we get a list of unspent transactions and begin summing them up
-	var getaddressutxos *revo.GetAddressUTXOsRequest = &revo.GetAddressUTXOsRequest{Addresses: []string{base58Addr}}
-	revoresp, err := p.GetAddressUTXOs(ctx, getaddressutxos)
 	if err != nil {
-		return nil, decimal.Decimal{}, err
+		return "", accountError(fromAddr, err)
 	}
 
-	//Convert minSumAmount to Satoshis
-	minimumSum := convertFromRevoToSatoshis(neededAmount)
-	var utxos []revo.RawTxInputs
-	var minUTXOsSum decimal.Decimal
-	for _, utxo := range *revoresp {
-		minUTXOsSum = minUTXOsSum.Add(utxo.Satoshis)
-		utxos = append(utxos, revo.RawTxInputs{TxID: utxo.TXID, Vout: utxo.OutputIndex})
-		if minUTXOsSum.GreaterThanOrEqual(minimumSum) {
-			return utxos, minUTXOsSum, nil
+	var builder *revo.TxBuilder
+	if acc != nil {
+		builder = revo.NewTxBuilder(acc)
+	} else {
+		pkh, err := hex.DecodeString(fromAddr)
+		if err != nil || len(pkh) != 20 {
+			return "", eth.NewInvalidParamsError("invalid from address")
 		}
+		// the external signer holds the key
+		builder = revo.NewUnsignedTxBuilder(pkh)
 	}
 
-	return nil, decimal.Decimal{}, fmt.Errorf("Insufficient UTXO value attempted to be sent")
-}
-
-func calculateChange(balance, neededAmount decimal.Decimal) (decimal.Decimal, error) {
-	if balance.LessThan(neededAmount) {
-		return decimal.Decimal{}, fmt.Errorf("insufficient funds to create fee to chain")
-	}
-	return balance.Sub(neededAmount), nil
-}
-
-func calculateNeededAmount(value, gasLimit, gasPrice decimal.Decimal) decimal.Decimal {
-	return value.Add(gasLimit.Mul(gasPrice))
-}
-
-func (p *ProxyETHSignTransaction) requestSendToContract(ctx context.Context, ethtx *eth.SendTransactionRequest) (string, eth.JSONRPCError) {
-	gasLimit, gasPrice, err := EthGasToRevo(ethtx)
-	if err != nil {
-		return "", eth.NewInvalidParamsError(err.Error())
-	}
-
-	amount := decimal.NewFromFloat(0.0)
+	amount := ZeroSatoshi
 	if ethtx.Value != "" {
-		var err error
 		amount, err = EthValueToRevoAmount(ethtx.Value, ZeroSatoshi)
 		if err != nil {
 			return "", eth.NewInvalidParamsError(err.Error())
 		}
 	}
+	amountSatoshis := convertFromRevoToSatoshis(amount).IntPart()
 
-	newGasPrice, err := decimal.NewFromString(gasPrice)
-	if err != nil {
-		return "", eth.NewInvalidParamsError(err.Error())
-	}
-	neededAmount := calculateNeededAmount(amount, decimal.NewFromBigInt(gasLimit, 0), newGasPrice)
-
-	inputs, balance, err := p.getRequiredUtxos(ctx, ethtx.From, neededAmount)
-	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
-	}
-
-	change, err := calculateChange(balance, neededAmount)
-	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
-	}
-
-	contractInteractTx := &revo.SendToContractRawRequest{
-		ContractAddress: utils.RemoveHexPrefix(ethtx.To),
-		Datahex:         utils.RemoveHexPrefix(ethtx.Data),
-		Amount:          amount,
-		GasLimit:        gasLimit,
-		GasPrice:        gasPrice,
-	}
+	var gasSatoshis int64
+	if ethtx.IsCreateContract() || ethtx.IsCallContract() {
+		gasLimit, gasPrice, err := EthGasToRevo(ethtx)
+		if err != nil {
+			return "", eth.NewInvalidParamsError(err.Error())
+		}
 
-	if from := ethtx.From; from != "" && utils.IsEthHexAddress(from) {
-		from, err = p.FromHexAddress(from)
+		gasPriceDecimal, err := decimal.NewFromString(gasPrice)
 		if err != nil {
 			return "", eth.NewInvalidParamsError(err.Error())
 		}
-		contractInteractTx.SenderAddress = from
-	}
+		gasPriceSatoshis := convertFromRevoToSatoshis(gasPriceDecimal).IntPart()
+		gasSatoshis = gasLimit.Int64() * gasPriceSatoshis
 
-	fromAddr := utils.RemoveHexPrefix(ethtx.From)
+		data, err := hex.DecodeString(utils.RemoveHexPrefix(ethtx.Data))
+		if err != nil {
+			return "", eth.NewInvalidParamsError(errors.Wrap(err, "invalid data").Error())
+		}
 
-	acc := p.Revo.Accounts.FindByHexAddress(strings.ToLower(fromAddr))
-	if acc == nil {
-		return "", eth.NewInvalidParamsError(fmt.Sprintf("No such account: %s", fromAddr))
+		if ethtx.IsCreateContract() {
+			err = builder.AddContractCreate(data, gasLimit.Uint64(), uint64(gasPriceSatoshis))
+		} else {
+			var contract []byte
+			contract, err = hex.DecodeString(utils.RemoveHexPrefix(ethtx.To))
+			if err == nil {
+				err = builder.AddContractCall(contract, data, gasLimit.Uint64(), uint64(gasPriceSatoshis), amountSatoshis)
+			}
+		}
+		if err != nil {
+			return "", eth.NewInvalidParamsError(err.Error())
+		}
+	} else {
+		to, err := addressToPubKeyHash(ethtx.To)
+		if err != nil {
+			return "", eth.NewInvalidParamsError(err.Error())
+		}
+		if err = builder.AddPayToPubKeyHash(to, amountSatoshis); err != nil {
+			return "", eth.NewInvalidParamsError(err.Error())
+		}
 	}
 
-	rawtxreq := []interface{}{inputs, []interface{}{map[string]*revo.SendToContractRawRequest{"contract": contractInteractTx}, map[string]decimal.Decimal{contractInteractTx.SenderAddress: change}}}
-	var rawTx string
-	if err := p.Revo.Request(revo.MethodCreateRawTx, rawtxreq, &rawTx); err != nil {
-		return "", eth.NewCallbackError(err.Error())
+	if err := p.addRequiredUtxos(ctx, builder, amountSatoshis+gasSatoshis); err != nil {
+		return "", eth.NewRevodError(err)
 	}
 
-	var resp *revo.SignRawTxResponse
-	if err := p.Revo.Request(revo.MethodSignRawTx, []interface{}{rawTx}, &resp); err != nil {
-		return "", eth.NewCallbackError(err.Error())
+	var tx *wire.MsgTx
+	if acc != nil {
+		tx, err = builder.Sign()
+	} else {
+		tx, err = p.signExternally(ctx, builder, ethtx)
 	}
-	if !resp.Complete {
-		return "", eth.NewCallbackError("something went wrong with signing the transaction; transaction incomplete")
+	if err != nil {
+		p.GetDebugLogger().Log("method", p.Method(), "msg", "Failed to sign transaction", "error", err)
+		return "", eth.NewCallbackErrorFrom(err)
 	}
-	return utils.AddHexPrefix(resp.Hex), nil
-}
 
-func (p *ProxyETHSignTransaction) requestSendToAddress(ctx context.Context, req *eth.SendTransactionRequest) (string, eth.JSONRPCError) {
-	getRevoWalletAddress := func(addr string) (string, error) {
-		if utils.IsEthHexAddress(addr) {
-			return p.FromHexAddress(utils.RemoveHexPrefix(addr))
-		}
-		return addr, nil
+	rawTx, err := revo.SerializeTx(tx)
+	if err != nil {
+		return "", eth.NewCallbackErrorFrom(err)
 	}
 
-	to, err := getRevoWalletAddress(req.To)
+	p.GetDebugLogger().Log("method", p.Method(), "msg", "Successfully signed transaction", "txid", tx.TxHash().String())
+
+	return rawTx, nil
+}
+
+// signExternally sends the transaction built by 'builder' to the external signer, with the Ethereum fields of 'ethtx' for it to check
+func (p *ProxyETHSignTransaction) signExternally(ctx context.Context, builder *revo.TxBuilder, ethtx *eth.SendTransactionRequest) (*wire.MsgTx, error) {
+	signerTx, err := revo.NewSignerTransaction(builder)
 	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
+		return nil, err
 	}
 
-	from, err := getRevoWalletAddress(req.From)
-	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
+	signerTx.To = ethtx.To
+	signerTx.Value = ethtx.Value
+	signerTx.Data = ethtx.Data
+	if ethtx.Gas != nil {
+		signerTx.Gas = ethtx.Gas.Hex()
+	}
+	if ethtx.GasPrice != nil {
+		signerTx.GasPrice = ethtx.GasPrice.Hex()
 	}
 
-	amount, err := EthValueToRevoAmount(req.Value, ZeroSatoshi)
+	return p.Signer.SignTransaction(ctx, signerTx)
+}
+
+// addRequiredUtxos adds the sender's utxos as inputs until they cover 'neededSatoshis' and the fee, then adds change back to the sender
+func (p *ProxyETHSignTransaction) addRequiredUtxos(ctx context.Context, builder *revo.TxBuilder, neededSatoshis int64) error {
+	base58Addr, err := revo.PubKeyHashToBase58Address(builder.SenderPubKeyHash(), p.Chain() == revo.ChainMain)
 	if err != nil {
-		return "", eth.NewInvalidParamsError(err.Error())
+		return err
 	}
 
-	inputs, balance, err := p.getRequiredUtxos(ctx, req.From, amount)
+	utxos, err := p.GetAddressUTXOs(ctx, &revo.GetAddressUTXOsRequest{Addresses: []string{base58Addr}})
 	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
+		return err
 	}
 
-	change, err := calculateChange(balance, amount)
+	spendable, err := p.spendableUtxos(ctx, *utxos)
 	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
+		return err
 	}
 
-	var addressValMap = map[string]decimal.Decimal{to: amount, from: change}
-	rawtxreq := []interface{}{inputs, addressValMap}
-	var rawTx string
-	if err := p.Revo.Request(revo.MethodCreateRawTx, rawtxreq, &rawTx); err != nil {
-		return "", eth.NewCallbackError(err.Error())
-	}
+	feeRate := revo.DefaultFeeRatePerKB
+	for _, utxo := range spendable {
+		builder.AddInput(utxo)
 
-	var resp *revo.SignRawTxResponse
-	signrawtxreq := []interface{}{rawTx}
-	if err := p.Revo.Request(revo.MethodSignRawTx, signrawtxreq, &resp); err != nil {
-		return "", eth.NewCallbackError(err.Error())
-	}
-	if !resp.Complete {
-		return "", eth.NewCallbackError("something went wrong with signing the transaction; transaction incomplete")
+		fee := revo.FeeForSize(builder.EstimateSize(true), feeRate)
+		change := builder.InputsValue() - neededSatoshis - fee
+		if change < 0 {
+			continue
+		}
+
+		if change > revo.DustThreshold(feeRate) {
+			return builder.AddPayToPubKeyHash(builder.SenderPubKeyHash(), change)
+		}
+		// dust change goes to the miner
+		return nil
 	}
-	return utils.AddHexPrefix(resp.Hex), nil
+
+	return revo.ErrInsufficientUTXOs
 }
 
-func (p *ProxyETHSignTransaction) requestCreateContract(ctx context.Context, req *eth.SendTransactionRequest) (string, eth.JSONRPCError) {
-	gasLimit, gasPrice, err := EthGasToRevo(req)
+// spendableUtxos returns the utxos the transaction builder can spend now: P2PKH outputs which no transaction in the
+// mempool spends yet, and only once they have reached maturity for coinbase and coinstake outputs
+func (p *ProxyETHSignTransaction) spendableUtxos(ctx context.Context, utxos []revo.UTXO) ([]revo.UnspentOutput, error) {
+	blockCount, err := p.GetBlockCount(ctx)
 	if err != nil {
-		return "", eth.NewInvalidParamsError(err.Error())
+		return nil, err
 	}
+	maturity := big.NewInt(int64(p.GetMatureBlockHeight()))
 
-	from := req.From
-	if utils.IsEthHexAddress(from) {
-		from, err = p.FromHexAddress(from)
+	outputs := make([]revo.UnspentOutput, 0, len(utxos))
+	// getaddressutxos still lists the outputs spent by the sender's unconfirmed transactions, gettxout with the mempool
+	// doesn't. It also flags coinbase outputs, which getaddressutxos doesn't, for the outputs young enough to be immature
+	calls := make([]*revo.BatchCall, 0, len(utxos))
+	var immature []bool
+	for _, utxo := range utxos {
+		script, err := hex.DecodeString(utxo.Script)
 		if err != nil {
-			return "", eth.NewInvalidParamsError(err.Error())
+			return nil, errors.Wrapf(err, "invalid script for utxo %s:%d", utxo.TXID, utxo.OutputIndex)
+		}
+		if txscript.GetScriptClass(script) != txscript.PubKeyHashTy {
+			continue
 		}
-	}
 
-	contractDeploymentTx := &revo.CreateContractRawRequest{
-		ByteCode:      utils.RemoveHexPrefix(req.Data),
-		GasLimit:      gasLimit,
-		GasPrice:      gasPrice,
-		SenderAddress: from,
-	}
+		mature := utxo.Height != nil && blockCount.Int.Cmp(new(big.Int).Add(utxo.Height, maturity)) > 0
+		if !mature && utxo.IsStake {
+			continue
+		}
+		calls = append(calls, revo.NewGetTransactionOutCall(utxo.TXID, int(utxo.OutputIndex), true))
+		immature = append(immature, !mature)
 
-	newGasPrice, err := decimal.NewFromString(gasPrice)
-	if err != nil {
-		return "", eth.NewInvalidParamsError(err.Error())
+		outputs = append(outputs, revo.UnspentOutput{
+			TxID:     utxo.TXID,
+			Vout:     uint32(utxo.OutputIndex),
+			Satoshis: utxo.Satoshis.IntPart(),
+			PkScript: script,
+		})
 	}
-	neededAmount := calculateNeededAmount(decimal.NewFromFloat(0.0), decimal.NewFromBigInt(gasLimit, 0), newGasPrice)
 
-	inputs, balance, err := p.getRequiredUtxos(ctx, req.From, neededAmount)
-	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
+	if len(calls) == 0 {
+		return outputs, nil
 	}
-
-	change, err := calculateChange(balance, neededAmount)
-	if err != nil {
-		return "", eth.NewCallbackError(err.Error())
+	if err := p.Batch(ctx, calls); err != nil {
+		return nil, err
 	}
 
-	rawtxreq := []interface{}{inputs, []interface{}{map[string]*revo.CreateContractRawRequest{"contract": contractDeploymentTx}, map[string]decimal.Decimal{from: change}}}
-	var rawTx string
-	if err := p.Revo.Request(revo.MethodCreateRawTx, rawtxreq, &rawTx); err != nil {
-		return "", eth.NewCallbackError(err.Error())
+	spendable := outputs[:0]
+	for i, output := range outputs {
+		if calls[i].Err != nil {
+			return nil, calls[i].Err
+		}
+		txOut := calls[i].Result.(*revo.GetTransactionOutResponse)
+		// null when already spent, in a block or in the mempool
+		if txOut.BestBlockHash == "" || immature[i] && (txOut.IsReward || txOut.IsCoinstake) {
+			continue
+		}
+		spendable = append(spendable, output)
 	}
+	return spendable, nil
+}
 
-	var resp *revo.SignRawTxResponse
-	signrawtxreq := []interface{}{rawTx}
-	if err := p.Revo.Request(revo.MethodSignRawTx, signrawtxreq, &resp); err != nil {
-		return "", eth.NewCallbackError(err.Error())
+// addressToPubKeyHash accepts either a hex address or a base58 Revo P2PKH address
+func addressToPubKeyHash(addr string) ([]byte, error) {
+	if utils.IsEthHexAddress(addr) {
+		return hex.DecodeString(utils.RemoveHexPrefix(addr))
 	}
-	if !resp.Complete {
-		return "", eth.NewCallbackError("something went wrong with signing the transaction; transaction incomplete")
+
+	pkh, _, err := base58.CheckDecode(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid address %s", addr)
 	}
-	return utils.AddHexPrefix(resp.Hex), nil
+
+	return pkh, nil
 }
@@ -0,0 +1,251 @@
+package transformer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/btcd/txscript"
+	"github.com/revolutionchain/btcd/wire"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+	"github.com/shopspring/decimal"
+)
+
+// the chain's height in the tests, past the maturity of the coinbase outputs at the first blocks
+const signTransactionTestHeight = 3000
+
+func TestSignTransactionSendsWithoutWallet(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.Accounts = append(revoClient.Accounts, wif)
+	from := (&revo.Account{WIF: wif}).ToHexAddress()
+
+	pkScript, err := revo.PayToPubKeyHashScript(btcutil.Hash160(wif.SerializePubKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mockedClientDoer.AddResponse(revo.MethodGetAddressUTXOs, []revo.UTXO{
+		{
+			TXID:        "7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d",
+			OutputIndex: 0,
+			Script:      hex.EncodeToString(pkScript),
+			Satoshis:    decimal.NewFromInt(200000000),
+			Height:      big.NewInt(1),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mockedClientDoer.AddResponse(revo.MethodGetBlockCount, signTransactionTestHeight); err != nil {
+		t.Fatal(err)
+	}
+	if err := mockedClientDoer.AddResponse(revo.MethodGetTransactionOut, revo.GetTransactionOutResponse{BestBlockHash: "best"}); err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := json.Marshal([]interface{}{map[string]string{
+		"from":  utils.AddHexPrefix(from),
+		"to":    "0x7e22630f90e6db16283af2c6b04f688117a55db4",
+		"value": "0xde0b6b3a7640000", // 1 REVO
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_signTransaction", Params: params}
+
+	proxyEth := ProxyETHSignTransaction{revoClient}
+	got, jsonErr := proxyEth.Request(request, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+
+	rawTx, err := hex.DecodeString(utils.RemoveHexPrefix(got.(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tx.TxIn) != 1 || len(tx.TxIn[0].SignatureScript) == 0 {
+		t.Fatalf("expected one signed input, got %d", len(tx.TxIn))
+	}
+	if len(tx.TxOut) != 2 {
+		t.Fatalf("expected a payment and a change output, got %d outputs", len(tx.TxOut))
+	}
+	if tx.TxOut[0].Value != 100000000 {
+		t.Errorf("unexpected payment value %d", tx.TxOut[0].Value)
+	}
+	if fee := 200000000 - tx.TxOut[0].Value - tx.TxOut[1].Value; fee <= 0 {
+		t.Errorf("expected a positive fee, got %d", fee)
+	}
+}
+
+func TestSignTransactionWithExternalSigner(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := (&revo.Account{WIF: wif}).ToHexAddress()
+
+	// charon never sees the key
+	server := httptest.NewServer(internal.NewSignerServer(revo.Accounts{wif}))
+	defer server.Close()
+	revoClient.Signer, err = revo.NewExternalSigner(server.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkScript, err := revo.PayToPubKeyHashScript(btcutil.Hash160(wif.SerializePubKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mockedClientDoer.AddResponse(revo.MethodGetAddressUTXOs, []revo.UTXO{
+		{
+			TXID:        "7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d",
+			OutputIndex: 0,
+			Script:      hex.EncodeToString(pkScript),
+			Satoshis:    decimal.NewFromInt(200000000),
+			Height:      big.NewInt(1),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mockedClientDoer.AddResponse(revo.MethodGetBlockCount, signTransactionTestHeight); err != nil {
+		t.Fatal(err)
+	}
+	if err := mockedClientDoer.AddResponse(revo.MethodGetTransactionOut, revo.GetTransactionOutResponse{BestBlockHash: "best"}); err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := json.Marshal([]interface{}{map[string]string{
+		"from":  utils.AddHexPrefix(from),
+		"to":    "0x7e22630f90e6db16283af2c6b04f688117a55db4",
+		"value": "0xde0b6b3a7640000", // 1 REVO
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_signTransaction", Params: params}
+
+	proxyEth := ProxyETHSignTransaction{revoClient}
+	got, jsonErr := proxyEth.Request(request, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+
+	tx, err := revo.DeserializeTx(got.(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.TxIn) != 1 || len(tx.TxIn[0].SignatureScript) == 0 {
+		t.Fatalf("expected one signed input, got %d", len(tx.TxIn))
+	}
+}
+
+func TestSignTransactionOnlySpendsMatureP2PKHOutputs(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.Accounts = append(revoClient.Accounts, wif)
+	from := (&revo.Account{WIF: wif}).ToHexAddress()
+
+	pkhScript, err := revo.PayToPubKeyHashScript(btcutil.Hash160(wif.SerializePubKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.NewScriptBuilder().AddData(wif.SerializePubKey()).AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utxo := func(txID string, script []byte, revos int64, height int64, isStake bool) revo.UTXO {
+		return revo.UTXO{
+			TXID:     strings.Repeat(txID, 64),
+			Script:   hex.EncodeToString(script),
+			Satoshis: decimal.NewFromInt(revos * 100000000),
+			Height:   big.NewInt(height),
+			IsStake:  isStake,
+		}
+	}
+	err = mockedClientDoer.AddResponse(revo.MethodGetAddressUTXOs, []revo.UTXO{
+		utxo("1", pkhScript, 1, 1, false),
+		utxo("2", pkScript, 5, 1, false),
+		utxo("3", pkhScript, 5, signTransactionTestHeight-10, true),
+		utxo("4", pkhScript, 5, signTransactionTestHeight-5, false),
+		utxo("5", pkhScript, 1, signTransactionTestHeight-1, false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mockedClientDoer.AddResponse(revo.MethodGetBlockCount, signTransactionTestHeight); err != nil {
+		t.Fatal(err)
+	}
+	// the P2PKH outputs which aren't young coinstakes are looked up, the young coinbase output can't be spent yet
+	for _, txOut := range []revo.GetTransactionOutResponse{{BestBlockHash: "best", IsReward: true}, {BestBlockHash: "best", IsReward: true}, {BestBlockHash: "best"}} {
+		if err := mockedClientDoer.AddResponse(revo.MethodGetTransactionOut, txOut); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	params, err := json.Marshal([]interface{}{map[string]string{
+		"from":  utils.AddHexPrefix(from),
+		"to":    "0x7e22630f90e6db16283af2c6b04f688117a55db4",
+		"value": "0x14d1120d7b160000", // 1.5 REVO
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_signTransaction", Params: params}
+
+	proxyEth := ProxyETHSignTransaction{revoClient}
+	got, jsonErr := proxyEth.Request(request, internal.NewEchoContext())
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+
+	tx, err := revo.DeserializeTx(got.(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spent []string
+	for _, in := range tx.TxIn {
+		spent = append(spent, in.PreviousOutPoint.Hash.String()[:1])
+	}
+	if len(spent) != 2 || spent[0] != "1" || spent[1] != "5" {
+		t.Errorf("expected the mature and the young P2PKH outputs to be spent, got %v", spent)
+	}
+}
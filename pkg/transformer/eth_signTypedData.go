@@ -0,0 +1,92 @@
+package transformer
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+// ProxyETHSignTypedData implements ETHProxy
+type ProxyETHSignTypedData struct {
+	*revo.Revo
+}
+
+func (p *ProxyETHSignTypedData) Method() string {
+	return "eth_signTypedData_v4"
+}
+
+func (p *ProxyETHSignTypedData) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var req eth.SignTypedDataRequest
+	if err := unmarshalRequest(rawreq.Params, &req); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	digest, err := req.TypedData.Digest()
+	if err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	addr := strings.ToLower(utils.RemoveHexPrefix(req.Account))
+
+	var sig []byte
+	var jsonErr eth.JSONRPCError
+	if req.Options.RawDigest {
+		sig, jsonErr = p.signDigest(addr, digest)
+	} else {
+		sig, jsonErr = signMessage(c.Request().Context(), p.Revo, addr, digest)
+	}
+	if jsonErr != nil {
+		p.GetDebugLogger().Log("method", p.Method(), "account", addr, "msg", "Failed to sign typed data", "error", jsonErr.Error())
+		return nil, jsonErr
+	}
+
+	return eth.SignTypedDataResponse(utils.AddHexPrefix(hex.EncodeToString(sig))), nil
+}
+
+// signDigest signs the EIP-712 digest itself, only hosted keys can since external signers never sign bare hashes
+func (p *ProxyETHSignTypedData) signDigest(addr string, digest []byte) ([]byte, eth.JSONRPCError) {
+	acc, err := p.FindAccount(addr)
+	if err != nil {
+		return nil, accountError(addr, err)
+	}
+
+	sig, err := btcec.SignCompact(btcec.S256(), acc.PrivKey, digest, acc.CompressPubKey)
+	if err != nil {
+		return nil, eth.NewCallbackErrorFrom(err)
+	}
+	return sig, nil
+}
+
+// ProxyREVORecoverTypedData implements ETHProxy
+type ProxyREVORecoverTypedData struct{}
+
+func (p *ProxyREVORecoverTypedData) Method() string {
+	return "revo_recoverTypedData"
+}
+
+func (p *ProxyREVORecoverTypedData) Request(rawreq *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	var req eth.RecoverTypedDataRequest
+	if err := unmarshalRequest(rawreq.Params, &req); err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	hash, err := req.TypedData.Digest()
+	if err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+	if !req.Options.RawDigest {
+		hash = revo.MessageHash(hash)
+	}
+
+	address, err := revo.RecoverCompactAddress(req.Signature, hash)
+	if err != nil {
+		return nil, eth.NewInvalidParamsError(err.Error())
+	}
+
+	return eth.RecoverTypedDataResponse(utils.AddHexPrefix(address)), nil
+}
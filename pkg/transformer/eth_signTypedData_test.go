@@ -0,0 +1,60 @@
+package transformer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+const testTypedData = `{
+	"types": {
+		"EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+		"Permit": [{"name": "spender", "type": "address"}, {"name": "value", "type": "uint256"}]
+	},
+	"primaryType": "Permit",
+	"domain": {"name": "Token", "chainId": 8888},
+	"message": {"spender": "0x7e22630f90e6db16283af2c6b04f688117a55db4", "value": "1000000000000000000000"}
+}`
+
+func TestSignTypedDataRecoversToSigner(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoClient.Accounts = append(revoClient.Accounts, wif)
+	from := utils.AddHexPrefix((&revo.Account{WIF: wif}).ToHexAddress())
+
+	for _, options := range []string{`{"rawDigest": false}`, `{"rawDigest": true}`} {
+		signParams := []byte(`["` + from + `", ` + testTypedData + `, ` + options + `]`)
+		signRequest := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_signTypedData_v4", Params: signParams}
+
+		sig, jsonErr := (&ProxyETHSignTypedData{revoClient}).Request(signRequest, internal.NewEchoContext())
+		if jsonErr != nil {
+			t.Fatal(jsonErr.Message())
+		}
+
+		// typed data is also accepted as a string
+		encodedTypedData, _ := json.Marshal(testTypedData)
+		recoverParams := []byte(`[` + string(encodedTypedData) + `, "` + string(sig.(eth.SignTypedDataResponse)) + `", ` + options + `]`)
+		recoverRequest := &eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "revo_recoverTypedData", Params: recoverParams}
+
+		got, jsonErr := (&ProxyREVORecoverTypedData{}).Request(recoverRequest, internal.NewEchoContext())
+		if jsonErr != nil {
+			t.Fatal(jsonErr.Message())
+		}
+		if got != eth.RecoverTypedDataResponse(from) {
+			t.Errorf("%s: expected the signature to recover to %s, got %s", options, from, got)
+		}
+	}
+}
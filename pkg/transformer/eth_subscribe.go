@@ -51,6 +51,6 @@ func (p *ETHSubscribe) request(req *eth.EthSubscriptionRequest, notifier *notifi
 	if err == nil {
 		return &response, nil
 	} else {
-		return &response, eth.NewCallbackError(err.Error())
+		return &response, eth.NewCallbackErrorFrom(err)
 	}
 }
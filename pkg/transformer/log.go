@@ -8,7 +8,7 @@ import (
 
 func GetLogger(proxy ETHProxy, q *revo.Revo) log.Logger {
 	method := proxy.Method()
-	logger := q.Client.GetLogger()
+	logger := log.With(q.Client.GetLogger(), "component", "transformer")
 	return log.WithPrefix(level.Info(logger), method)
 }
 
@@ -18,7 +18,7 @@ func GetLoggerFromETHCall(proxy *ProxyETHCall) log.Logger {
 
 func GetDebugLogger(proxy ETHProxy, q *revo.Revo) log.Logger {
 	method := proxy.Method()
-	logger := q.Client.GetDebugLogger()
+	logger := log.With(q.Client.GetDebugLogger(), "component", "transformer")
 	return log.WithPrefix(level.Debug(logger), method)
 }
 
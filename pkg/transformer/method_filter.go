@@ -0,0 +1,108 @@
+package transformer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/revolutionchain/charon/pkg/eth"
+)
+
+// MethodFilterContextKey is the echo context key of the *MethodFilter applied to a request's transport
+const MethodFilterContextKey = "methodFilter"
+
+// MethodFilter decides which methods a transport exposes, like geth's --http.api: methods in an enabled namespace
+// (the part of the method name before the first '_') and explicitly allowed methods, minus explicitly denied methods.
+// A nil MethodFilter allows everything
+type MethodFilter struct {
+	// nil enables every namespace
+	namespaces map[string]bool
+	allow      map[string]bool
+	deny       map[string]bool
+}
+
+// NewMethodFilter returns a filter enabling 'namespaces' (every namespace if empty) plus 'allow', minus 'deny'
+func NewMethodFilter(namespaces []string, allow []string, deny []string) *MethodFilter {
+	f := &MethodFilter{
+		allow: toSet(allow),
+		deny:  toSet(deny),
+	}
+	if len(namespaces) > 0 {
+		f.namespaces = toSet(namespaces)
+	}
+	return f
+}
+
+// Allows reports whether 'method' is exposed
+func (f *MethodFilter) Allows(method string) bool {
+	if f == nil || method == rpcModulesMethod {
+		return true
+	}
+	if f.deny[method] {
+		return false
+	}
+	if f.allow[method] {
+		return true
+	}
+	return f.namespaces == nil || f.namespaces[Namespace(method)]
+}
+
+// Namespace returns the namespace of 'method', "eth" for "eth_call"
+func Namespace(method string) string {
+	if i := strings.Index(method, "_"); i != -1 {
+		return method[:i]
+	}
+	return method
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
+
+// methodFilter returns the filter the server set for the request's transport, if any
+func methodFilter(c echo.Context) *MethodFilter {
+	if c == nil {
+		return nil
+	}
+	filter, _ := c.Get(MethodFilterContextKey).(*MethodFilter)
+	return filter
+}
+
+const rpcModulesMethod = "rpc_modules"
+
+// RPCModules implements ETHProxy, it lists the namespaces with at least one method exposed on the request's transport
+type RPCModules struct {
+	transformer *Transformer
+}
+
+func (p *RPCModules) Method() string {
+	return rpcModulesMethod
+}
+
+func (p *RPCModules) Request(_ *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	filter := methodFilter(c)
+
+	modules := map[string]string{}
+	for _, method := range p.transformer.Methods() {
+		if filter.Allows(method) {
+			modules[Namespace(method)] = "1.0"
+		}
+	}
+	return modules, nil
+}
+
+// Methods returns every registered method, sorted
+func (t *Transformer) Methods() []string {
+	methods := make([]string, 0, len(t.transformers))
+	for method := range t.transformers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,60 @@
+package transformer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+)
+
+func TestMethodFilter(t *testing.T) {
+	filter := NewMethodFilter([]string{"eth", "net"}, []string{"personal_ecRecover"}, []string{"eth_sendTransaction", "eth_accounts"})
+
+	for method, allowed := range map[string]bool{
+		"eth_call":            true,
+		"net_version":         true,
+		"eth_sendTransaction": false,
+		"eth_accounts":        false,
+		"personal_ecRecover":  true,
+		"personal_sign":       false,
+		"web3_clientVersion":  false,
+		"rpc_modules":         true,
+	} {
+		if got := filter.Allows(method); got != allowed {
+			t.Errorf("%s: expected allowed to be %v, got %v", method, allowed, got)
+		}
+	}
+
+	if !(*MethodFilter)(nil).Allows("eth_sendTransaction") || !NewMethodFilter(nil, nil, nil).Allows("personal_sign") {
+		t.Error("expected filters without namespaces to allow everything")
+	}
+}
+
+func TestTransformHidesFilteredMethods(t *testing.T) {
+	revoClient, err := internal.CreateMockedClient(internal.NewDoerMappedMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer, err := New(revoClient, []ETHProxy{&Web3ClientVersion{}, &ProxyETHAccounts{Revo: revoClient}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := internal.NewEchoContext()
+	c.Set(MethodFilterContextKey, NewMethodFilter([]string{"web3"}, nil, nil))
+
+	_, jsonErr := transformer.Transform(&eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_accounts"}, c)
+	if jsonErr == nil || jsonErr.Code() != eth.MethodNotFoundErrorCode {
+		t.Fatalf("expected a method not found error, got %v", jsonErr)
+	}
+
+	modules, jsonErr := transformer.Transform(&eth.JSONRPCRequest{JSONRPC: "2.0", ID: json.RawMessage("2"), Method: "rpc_modules"}, c)
+	if jsonErr != nil {
+		t.Fatal(jsonErr.Message())
+	}
+	if expected := map[string]string{"web3": "1.0", "rpc": "1.0"}; !reflect.DeepEqual(modules, expected) {
+		t.Errorf("expected modules %v, got %v", expected, modules)
+	}
+}
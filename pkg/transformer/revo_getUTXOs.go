@@ -50,12 +50,12 @@ func (p *ProxyREVOGetUTXOs) request(ctx context.Context, params eth.GetUTXOsRequ
 
 	resp, err := p.Revo.GetAddressUTXOs(ctx, &req)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	blockCount, err := p.Revo.GetBlockCount(ctx)
 	if err != nil {
-		return nil, eth.NewCallbackError(err.Error())
+		return nil, eth.NewCallbackErrorFrom(err)
 	}
 
 	matureBlockHeight := big.NewInt(int64(p.Revo.GetMatureBlockHeight()))
@@ -1,12 +1,18 @@
 package transformer
 
 import (
+	"fmt"
+	"net"
+
 	"github.com/go-kit/kit/log"
 	"github.com/labstack/echo"
 	"github.com/pkg/errors"
 	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/logging"
 	"github.com/revolutionchain/charon/pkg/notifier"
 	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/tracing"
+	"github.com/revolutionchain/charon/pkg/txtracker"
 )
 
 type Transformer struct {
@@ -28,6 +34,9 @@ func New(revoClient *revo.Revo, proxies []ETHProxy, opts ...Option) (*Transforme
 	}
 
 	var err error
+	if err = t.Register(&RPCModules{transformer: t}); err != nil {
+		return nil, err
+	}
 	for _, p := range proxies {
 		if err = t.Register(p); err != nil {
 			return nil, err
@@ -61,17 +70,48 @@ func (t *Transformer) Register(p ETHProxy) error {
 
 // Transform takes a Transformer and transforms the request from ETH request and returns the proxy request
 func (t *Transformer) Transform(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	ctx, span := tracing.Tracer().Start(c.Request().Context(), "Transformer.Transform")
+	defer span.End()
+
+	resp, err := t.transform(req, tracing.WithContext(c, ctx))
+	if err != nil {
+		tracing.RecordJSONRPCError(span, err)
+	}
+	return resp, err
+}
+
+func (t *Transformer) transform(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	if !methodFilter(c).Allows(req.Method) {
+		// disabled methods look like they don't exist
+		return nil, eth.NewMethodNotFoundError(req.Method)
+	}
+	if key := apiKey(c); key != nil {
+		if err := key.AuthorizeCall(req.Method); err != nil {
+			return nil, err
+		}
+	}
+
 	proxy, err := t.getProxy(req.Method)
 	if err != nil {
 		return nil, err
 	}
 	resp, err := proxy.Request(req, c)
 	if err != nil {
+		if upstreamUnavailable(err) {
+			// the same error whichever call to revod failed
+			return nil, eth.NewUpstreamUnavailableError()
+		}
 		return nil, err
 	}
 	return resp, nil
 }
 
+// upstreamUnavailable tells whether 'err' comes from a call to revod failed by the circuit breakers, which proxies
+// keep as the cause of their errors
+func upstreamUnavailable(err eth.JSONRPCError) bool {
+	return errors.Is(err.Error(), revo.ErrUpstreamUnavailable)
+}
+
 func (t *Transformer) getProxy(method string) (ETHProxy, eth.JSONRPCError) {
 	proxy, ok := t.transformers[method]
 	if !ok {
@@ -80,20 +120,28 @@ func (t *Transformer) getProxy(method string) (ETHProxy, eth.JSONRPCError) {
 	return proxy, nil
 }
 
+// HasMethod is whether a proxy is registered for 'method'
+func (t *Transformer) HasMethod(method string) bool {
+	_, ok := t.transformers[method]
+	return ok
+}
+
 func (t *Transformer) IsDebugEnabled() bool {
 	return t.debugMode
 }
 
 // DefaultProxies are the default proxy methods made available
-func DefaultProxies(revoRPCClient *revo.Revo, agent *notifier.Agent) []ETHProxy {
+func DefaultProxies(revoRPCClient *revo.Revo, agent *notifier.Agent, tracker *txtracker.Tracker) []ETHProxy {
 	filter := eth.NewFilterSimulator()
 	getFilterChanges := &ProxyETHGetFilterChanges{Revo: revoRPCClient, filter: filter}
 	ethCall := &ProxyETHCall{Revo: revoRPCClient}
+	getTransactionReceipt := &ProxyETHGetTransactionReceipt{Revo: revoRPCClient}
+	sendTransaction := &ProxyETHSendTransaction{Revo: revoRPCClient, tracker: tracker}
+	sendRawTransaction := &ProxyETHSendRawTransaction{Revo: revoRPCClient, tracker: tracker}
 
 	ethProxies := []ETHProxy{
 		ethCall,
 		&ProxyNetListening{Revo: revoRPCClient},
-		&ProxyETHPersonalUnlockAccount{},
 		&ProxyETHChainId{Revo: revoRPCClient},
 		&ProxyETHBlockNumber{Revo: revoRPCClient},
 		&ProxyETHHashrate{Revo: revoRPCClient},
@@ -102,8 +150,9 @@ func DefaultProxies(revoRPCClient *revo.Revo, agent *notifier.Agent) []ETHProxy
 		&ProxyETHGetTransactionByHash{Revo: revoRPCClient},
 		&ProxyETHGetTransactionByBlockNumberAndIndex{Revo: revoRPCClient},
 		&ProxyETHGetLogs{Revo: revoRPCClient},
-		&ProxyETHGetTransactionReceipt{Revo: revoRPCClient},
-		&ProxyETHSendTransaction{Revo: revoRPCClient},
+		getTransactionReceipt,
+		sendTransaction,
+		&ProxyETHSendTransactionSync{ProxyETHSendTransaction: sendTransaction, receipts: getTransactionReceipt},
 		&ProxyETHAccounts{Revo: revoRPCClient},
 		&ProxyETHGetCode{Revo: revoRPCClient},
 
@@ -126,16 +175,23 @@ func DefaultProxies(revoRPCClient *revo.Revo, agent *notifier.Agent) []ETHProxy
 		&Web3ClientVersion{},
 		&Web3Sha3{},
 		&ProxyETHSign{Revo: revoRPCClient},
+		&ProxyETHSignTypedData{Revo: revoRPCClient},
+		&ProxyETHPersonalSign{Revo: revoRPCClient},
+		&ProxyETHPersonalECRecover{},
 		&ProxyETHGasPrice{Revo: revoRPCClient},
 		&ProxyETHTxCount{Revo: revoRPCClient},
 		&ProxyETHSignTransaction{Revo: revoRPCClient},
-		&ProxyETHSendRawTransaction{Revo: revoRPCClient},
+		sendRawTransaction,
+		&ProxyETHSendRawTransactionSync{ProxyETHSendRawTransaction: sendRawTransaction, receipts: getTransactionReceipt},
+		&ProxyCharonGetTransactionStatus{tracker: tracker},
+		&ProxyCharonAPIKeyUsage{},
 
 		&ETHSubscribe{Revo: revoRPCClient, Agent: agent},
 		&ETHUnsubscribe{Revo: revoRPCClient, Agent: agent},
 
 		&ProxyREVOGetUTXOs{Revo: revoRPCClient},
 		&ProxyREVOGenerateToAddress{Revo: revoRPCClient},
+		&ProxyREVORecoverTypedData{},
 
 		&ProxyNetPeerCount{Revo: revoRPCClient},
 	}
@@ -159,6 +215,57 @@ func DefaultProxies(revoRPCClient *revo.Revo, agent *notifier.Agent) []ETHProxy
 	return ethProxies
 }
 
+// PersonalProxies are the account management methods. They create, import and unlock hosted keys so they are
+// opt-in, and when 'localOnly' is set they only answer requests coming from the loopback interface
+func PersonalProxies(revoRPCClient *revo.Revo, localOnly bool) []ETHProxy {
+	proxies := []ETHProxy{
+		&ProxyETHPersonalNewAccount{Revo: revoRPCClient},
+		&ProxyETHPersonalImportRawKey{Revo: revoRPCClient},
+		&ProxyETHPersonalListAccounts{Revo: revoRPCClient},
+		&ProxyETHPersonalUnlockAccount{Revo: revoRPCClient},
+		&ProxyETHPersonalLockAccount{Revo: revoRPCClient},
+	}
+
+	if localOnly {
+		for i, proxy := range proxies {
+			proxies[i] = &localOnlyProxy{ETHProxy: proxy}
+		}
+	}
+
+	return proxies
+}
+
+// AdminProxies change how charon runs, like the log levels of its components. They are opt-in, and when 'localOnly'
+// is set they only answer requests coming from the loopback interface
+func AdminProxies(levels *logging.Levels, localOnly bool) []ETHProxy {
+	proxies := []ETHProxy{
+		&ProxyAdminSetLogLevel{Levels: levels},
+		&ProxyAdminLogLevels{Levels: levels},
+	}
+
+	if localOnly {
+		for i, proxy := range proxies {
+			proxies[i] = &localOnlyProxy{ETHProxy: proxy}
+		}
+	}
+
+	return proxies
+}
+
+// localOnlyProxy refuses requests that don't come from the loopback interface
+type localOnlyProxy struct {
+	ETHProxy
+}
+
+func (p *localOnlyProxy) Request(req *eth.JSONRPCRequest, c echo.Context) (interface{}, eth.JSONRPCError) {
+	// RemoteAddr rather than RealIP, proxy headers can be forged
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		return nil, eth.NewInvalidRequestError(fmt.Sprintf("%s is only available from localhost", p.Method()))
+	}
+	return p.ETHProxy.Request(req, c)
+}
+
 func SetDebug(debug bool) func(*Transformer) error {
 	return func(t *Transformer) error {
 		t.debugMode = debug
@@ -0,0 +1,75 @@
+package transformer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/charon/pkg/accesslog"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+)
+
+func TestUpstreamUnavailable(t *testing.T) {
+	cases := []struct {
+		err  eth.JSONRPCError
+		want bool
+	}{
+		{eth.NewCallbackErrorFrom(errors.Wrap(revo.ErrUpstreamUnavailable, "Client#do")), true},
+		{eth.WrapCallbackError(errors.Wrap(revo.ErrUpstreamUnavailable, "Client#do"), "couldn't get block"), true},
+		{eth.NewRevodError(errors.Wrap(revo.ErrUpstreamUnavailable, "Client#do")), true},
+		// only the cause counts, not the message
+		{eth.NewCallbackError("couldn't get block: " + errors.Wrap(revo.ErrUpstreamUnavailable, "Client#do").Error()), false},
+		{eth.NewCallbackError("couldn't get block"), false},
+		{eth.NewInvalidParamsError("invalid block number"), false},
+	}
+	for _, c := range cases {
+		if got := upstreamUnavailable(c.err); got != c.want {
+			t.Errorf("upstreamUnavailable(%q) = %v, expected %v", c.err.Message(), got, c.want)
+		}
+	}
+}
+
+func TestSigningAndKeyMethodsAreRedacted(t *testing.T) {
+	mockedClientDoer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(mockedClientDoer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := make(map[string]bool)
+	for _, method := range accesslog.DefaultRedactMethods {
+		redacted[method] = true
+	}
+
+	// methods taking or returning signatures, signed transactions, passphrases or keys
+	secret := func(method string) bool {
+		method = strings.ToLower(method)
+		if method == "personal_ecrecover" {
+			return false
+		}
+		for _, part := range []string{"sign", "sendraw", "unlock", "newaccount", "rawkey"} {
+			if strings.Contains(method, part) {
+				return true
+			}
+		}
+		return false
+	}
+
+	proxies := append(DefaultProxies(revoClient, nil, nil), PersonalProxies(revoClient, false)...)
+	for _, proxy := range proxies {
+		if secret(proxy.Method()) && !redacted[proxy.Method()] {
+			t.Errorf("%s isn't redacted from the access log by default", proxy.Method())
+		}
+	}
+	for method := range redacted {
+		found := false
+		for _, proxy := range proxies {
+			found = found || proxy.Method() == method
+		}
+		if !found {
+			t.Errorf("%s is redacted from the access log but isn't a method", method)
+		}
+	}
+}
@@ -146,7 +146,7 @@ func getNonContractTxSenderAddress(ctx context.Context, p *revo.Revo, tx *revo.D
 	rawTx, err := p.GetRawTransaction(ctx, tx.ID, false)
 
 	if err != nil {
-		return "", errors.New("Couldn't get raw Transaction data from Transaction ID: " + err.Error())
+		return "", errors.Wrap(err, "Couldn't get raw Transaction data from Transaction ID")
 	}
 
 	// If Tx has no vins it's either a reward transaction or invalid/corrupt (Right?). This is outside the intended scope of this function, so throw an error
@@ -171,7 +171,7 @@ func getNonContractTxSenderAddress(ctx context.Context, p *revo.Revo, tx *revo.D
 	// If we get here, we have no Vins with a valid address, so search for sender address in previous Tx's vouts
 	hexAddr, err := searchSenderAddressInPreviousTransactions(ctx, p, rawTx)
 	if err != nil {
-		return "", errors.New("Couldn't find sender address in previous transactions: " + err.Error())
+		return "", errors.Wrap(err, "Couldn't find sender address in previous transactions")
 	}
 
 	return utils.AddHexPrefix(hexAddr), nil
@@ -196,7 +196,7 @@ func searchSenderAddressInPreviousTransactions(ctx context.Context, p *revo.Revo
 	prevRawTx, err := p.GetRawTransaction(ctx, txid, false)
 	if err != nil {
 		p.GetDebugLogger().Log("msg", "Failed to GetRawTransaction", "tx", txid, "err", err)
-		return "", errors.New("Couldn't get raw transaction: " + err.Error())
+		return "", errors.Wrap(err, "Couldn't get raw transaction")
 	}
 	// check opcodes contained in vout found in previous transaction
 	prevVout := prevRawTx.Vouts[vout]
@@ -324,7 +324,7 @@ func getBlockNumberByParam(ctx context.Context, p *revo.Revo, param string, defa
 		if defaultVal {
 			res, err := p.GetBlockChainInfo(ctx)
 			if err != nil {
-				return nil, eth.NewCallbackError(err.Error())
+				return nil, eth.NewCallbackErrorFrom(err)
 			}
 			p.GetDebugLogger().Log("function", "getBlockNumberByParam", "msg", "returning default value ("+strconv.Itoa(int(res.Blocks))+")")
 			return big.NewInt(res.Blocks), nil
@@ -338,7 +338,7 @@ func getBlockNumberByParam(ctx context.Context, p *revo.Revo, param string, defa
 	case "latest":
 		res, err := p.GetBlockChainInfo(ctx)
 		if err != nil {
-			return nil, eth.NewCallbackError(err.Error())
+			return nil, eth.NewCallbackErrorFrom(err)
 		}
 		p.GetDebugLogger().Log("latest", res.Blocks, "msg", "Got latest block")
 		return big.NewInt(res.Blocks), nil
@@ -450,3 +450,11 @@ func convertFromRevoToSatoshis(inRevo decimal.Decimal) decimal.Decimal {
 func convertFromSatoshiToWei(inSatoshis *big.Int) *big.Int {
 	return inSatoshis.Mul(inSatoshis, big.NewInt(1e10))
 }
+
+// accountError converts errors from revo.Client.FindAccount
+func accountError(addr string, err error) eth.JSONRPCError {
+	if err == revo.ErrUnknownAccount {
+		return eth.NewInvalidParamsError(fmt.Sprintf("No such account: %s", addr))
+	}
+	return eth.NewCallbackErrorFrom(err)
+}
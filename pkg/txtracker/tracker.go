@@ -0,0 +1,507 @@
+package txtracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/revolutionchain/btcd/wire"
+	"github.com/revolutionchain/charon/pkg/revo"
+	"github.com/revolutionchain/charon/pkg/utils"
+)
+
+// Status of a transaction charon has broadcast
+type Status string
+
+const (
+	// StatusQueued means the transaction was recorded but revod hasn't accepted it yet
+	StatusQueued Status = "queued"
+	// StatusMempool means the transaction is waiting in revod's mempool
+	StatusMempool Status = "mempool"
+	// StatusMined means the transaction is included in the active chain
+	StatusMined Status = "mined"
+	// StatusDropped means the transaction left the mempool without being mined and could not be rebroadcast
+	StatusDropped Status = "dropped"
+	// StatusConflicted means one of the transaction's inputs was spent by another transaction
+	StatusConflicted Status = "conflicted"
+)
+
+const (
+	DefaultPollInterval       = 30 * time.Second
+	DefaultFinalConfirmations = int64(20)
+	DefaultRetention          = 24 * time.Hour
+)
+
+// the most blocks searched for a transaction revod can't look up, see findMined
+const maxSearchedBlocks = 100
+
+// Transaction is a snapshot of what the Tracker knows about a transaction
+type Transaction struct {
+	Hash         string
+	RawTx        string
+	Status       Status
+	BlockHash    string
+	BlockHeight  int64
+	Rebroadcasts int
+	LastError    string
+	Created      time.Time
+	Updated      time.Time
+
+	// the chain's height when the transaction was last seen out of a block
+	seenHeight int64
+}
+
+// Tracker remembers every transaction charon broadcasts and polls revod until they are final,
+// rebroadcasting transactions that drop out of the mempool. State is kept in memory only
+type Tracker struct {
+	revo *revo.Revo
+
+	mutex        sync.RWMutex
+	transactions map[string]*Transaction
+	tip          int64
+
+	pollInterval       time.Duration
+	finalConfirmations int64
+	retention          time.Duration
+	now                func() time.Time
+}
+
+func New(revo *revo.Revo, options ...func(*Tracker) error) (*Tracker, error) {
+	if revo == nil {
+		return nil, errors.New("revo cannot be nil")
+	}
+
+	t := &Tracker{
+		revo:               revo,
+		transactions:       make(map[string]*Transaction),
+		pollInterval:       DefaultPollInterval,
+		finalConfirmations: DefaultFinalConfirmations,
+		retention:          DefaultRetention,
+		now:                time.Now,
+	}
+
+	for _, option := range options {
+		if err := option(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func SetPollInterval(interval time.Duration) func(*Tracker) error {
+	return func(t *Tracker) error {
+		if interval <= 0 {
+			return errors.New("poll interval must be positive")
+		}
+		t.pollInterval = interval
+		return nil
+	}
+}
+
+// SetFinalConfirmations sets how many confirmations a transaction needs before it stops being polled
+func SetFinalConfirmations(confirmations int64) func(*Tracker) error {
+	return func(t *Tracker) error {
+		if confirmations < 1 {
+			return errors.New("final confirmations must be at least 1")
+		}
+		t.finalConfirmations = confirmations
+		return nil
+	}
+}
+
+// SetRetention sets how long a transaction is remembered after its status last changed
+func SetRetention(retention time.Duration) func(*Tracker) error {
+	return func(t *Tracker) error {
+		t.retention = retention
+		return nil
+	}
+}
+
+// Queue records a signed transaction that is about to be broadcast and returns its hash
+func (t *Tracker) Queue(rawTx string) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+
+	rawTx = utils.RemoveHexPrefix(rawTx)
+	hash, err := hashRawTx(rawTx)
+	if err != nil {
+		return "", err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, exists := t.transactions[hash]; !exists {
+		now := t.now()
+		t.transactions[hash] = &Transaction{
+			Hash:       hash,
+			RawTx:      rawTx,
+			Status:     StatusQueued,
+			Created:    now,
+			Updated:    now,
+			seenHeight: t.tip,
+		}
+	}
+
+	return hash, nil
+}
+
+// Broadcasted marks a queued transaction as accepted by revod
+func (t *Tracker) Broadcasted(hash string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if tx, ok := t.transactions[normalizeHash(hash)]; ok && tx.Status == StatusQueued {
+		t.setStatus(tx, StatusMempool)
+	}
+}
+
+// Forget drops a transaction, used when revod rejected the initial broadcast
+func (t *Tracker) Forget(hash string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.transactions, normalizeHash(hash))
+}
+
+// TrackHash records a transaction revod's wallet created and broadcast, so its raw form isn't known yet.
+// The raw transaction is picked up from the mempool on the next poll so it can be rebroadcast
+func (t *Tracker) TrackHash(hash string) {
+	if t == nil {
+		return
+	}
+
+	hash = normalizeHash(hash)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, exists := t.transactions[hash]; !exists {
+		now := t.now()
+		t.transactions[hash] = &Transaction{
+			Hash:       hash,
+			Status:     StatusMempool,
+			Created:    now,
+			Updated:    now,
+			seenHeight: t.tip,
+		}
+	}
+}
+
+// Get returns a copy of what is known about 'hash'
+func (t *Tracker) Get(hash string) (Transaction, bool) {
+	if t == nil {
+		return Transaction{}, false
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	tx, ok := t.transactions[normalizeHash(hash)]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *tx, true
+}
+
+// Confirmations returns the number of confirmations of a mined transaction as of the last poll
+func (t *Tracker) Confirmations(tx Transaction) int64 {
+	if t == nil || tx.Status != StatusMined {
+		return 0
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if t.tip < tx.BlockHeight {
+		return 1
+	}
+	return t.tip - tx.BlockHeight + 1
+}
+
+// Start polls revod every poll interval until 'ctx' is done
+func (t *Tracker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(t.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.poll(ctx)
+			}
+		}
+	}()
+}
+
+func (t *Tracker) poll(ctx context.Context) {
+	blockCount, err := t.revo.GetBlockCount(ctx)
+	if err != nil {
+		t.revo.GetErrorLogger().Log("component", "txtracker", "msg", "Failed to get block count", "error", err)
+		return
+	}
+
+	t.mutex.Lock()
+	t.tip = blockCount.Int64()
+	pending := make([]Transaction, 0, len(t.transactions))
+	for hash, tx := range t.transactions {
+		if t.retention > 0 && t.now().Sub(tx.Updated) > t.retention && t.isSettled(tx) {
+			delete(t.transactions, hash)
+			continue
+		}
+		if !t.isFinal(tx) {
+			pending = append(pending, *tx)
+		}
+	}
+	t.mutex.Unlock()
+
+	for _, tx := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		t.check(ctx, tx)
+	}
+}
+
+// isFinal reports whether 'tx' no longer needs polling, must be called with the mutex held
+func (t *Tracker) isFinal(tx *Transaction) bool {
+	return tx.Status == StatusMined && t.tip-tx.BlockHeight+1 >= t.finalConfirmations
+}
+
+// isSettled reports whether 'tx' can be pruned once retention expires, must be called with the mutex held
+func (t *Tracker) isSettled(tx *Transaction) bool {
+	return tx.Status != StatusQueued && tx.Status != StatusMempool
+}
+
+func (t *Tracker) check(ctx context.Context, tx Transaction) {
+	rawTx, err := t.revo.GetRawTransaction(ctx, tx.Hash, false)
+	if err == nil {
+		if rawTx.BlockHash != "" && rawTx.Confirmations > 0 {
+			header, err := t.revo.GetBlockHeader(ctx, rawTx.BlockHash)
+			if err != nil {
+				t.revo.GetErrorLogger().Log("component", "txtracker", "msg", "Failed to get block header", "hash", rawTx.BlockHash, "error", err)
+				return
+			}
+			t.update(tx.Hash, func(tracked *Transaction) {
+				if tracked.RawTx == "" {
+					tracked.RawTx = rawTx.Hex
+				}
+				if tracked.Status != StatusMined || tracked.BlockHash != rawTx.BlockHash {
+					tracked.BlockHash = rawTx.BlockHash
+					tracked.BlockHeight = int64(header.Height)
+					t.setStatus(tracked, StatusMined)
+				}
+			})
+			return
+		}
+
+		// in the mempool, possibly again after a reorg
+		t.update(tx.Hash, func(tracked *Transaction) {
+			if tracked.RawTx == "" {
+				tracked.RawTx = rawTx.Hex
+			}
+			tracked.BlockHash = ""
+			tracked.BlockHeight = 0
+			tracked.seenHeight = t.tip
+			t.setStatus(tracked, StatusMempool)
+		})
+		return
+	}
+
+	if !errors.Is(err, revo.ErrInvalidAddress) {
+		// revod answers unknown transactions with -5, anything else is a transient failure
+		t.revo.GetErrorLogger().Log("component", "txtracker", "msg", "Failed to get transaction", "hash", tx.Hash, "error", err)
+		return
+	}
+
+	t.recover(ctx, tx)
+}
+
+// recover is called for a transaction that is neither in the mempool nor in the active chain
+func (t *Tracker) recover(ctx context.Context, tx Transaction) {
+	if tx.RawTx == "" {
+		t.update(tx.Hash, func(tracked *Transaction) {
+			tracked.BlockHash = ""
+			tracked.BlockHeight = 0
+			tracked.LastError = "transaction left the mempool before its raw form was known"
+			t.setStatus(tracked, StatusDropped)
+		})
+		return
+	}
+
+	conflicted, err := t.inputsSpent(ctx, tx.RawTx)
+	if err != nil {
+		t.revo.GetErrorLogger().Log("component", "txtracker", "msg", "Failed to check transaction inputs", "hash", tx.Hash, "error", err)
+		return
+	}
+	if conflicted {
+		// revod without -txindex can't look up mined transactions either, whose inputs they spent themselves
+		blockHash, blockHeight, err := t.findMined(ctx, tx)
+		if err != nil {
+			t.revo.GetErrorLogger().Log("component", "txtracker", "msg", "Failed to search the blocks for transaction", "hash", tx.Hash, "error", err)
+			return
+		}
+		if blockHash != "" {
+			t.update(tx.Hash, func(tracked *Transaction) {
+				tracked.BlockHash = blockHash
+				tracked.BlockHeight = blockHeight
+				t.setStatus(tracked, StatusMined)
+			})
+			return
+		}
+
+		t.update(tx.Hash, func(tracked *Transaction) {
+			tracked.BlockHash = ""
+			tracked.BlockHeight = 0
+			t.setStatus(tracked, StatusConflicted)
+		})
+		return
+	}
+
+	req := revo.SendRawTransactionRequest([1]string{tx.RawTx})
+	_, err = t.revo.SendRawTransaction(ctx, &req)
+	t.update(tx.Hash, func(tracked *Transaction) {
+		tracked.BlockHash = ""
+		tracked.BlockHeight = 0
+		tracked.Rebroadcasts++
+		if err != nil {
+			tracked.LastError = err.Error()
+			t.setStatus(tracked, StatusDropped)
+			return
+		}
+		tracked.LastError = ""
+		t.setStatus(tracked, StatusMempool)
+	})
+
+	if err != nil {
+		t.revo.GetErrorLogger().Log("component", "txtracker", "msg", "Failed to rebroadcast transaction", "hash", tx.Hash, "error", err)
+	} else {
+		t.revo.GetDebugLogger().Log("component", "txtracker", "msg", "Rebroadcast transaction", "hash", tx.Hash)
+	}
+}
+
+// inputsSpent reports whether any input of 'rawTx' was already spent, by a mined or a mempool transaction
+func (t *Tracker) inputsSpent(ctx context.Context, rawTx string) (bool, error) {
+	msgTx, err := decodeRawTx(rawTx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, in := range msgTx.TxIn {
+		out, err := t.revo.GetTransactionOut(ctx, in.PreviousOutPoint.Hash.String(), int(in.PreviousOutPoint.Index), true)
+		if err != nil {
+			return false, err
+		}
+		// gettxout returns null for spent outputs
+		if out == nil || out.BestBlockHash == "" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// findMined looks for 'tx' in the blocks since it was last seen out of a block, or since the block it was mined in,
+// up to maxSearchedBlocks. It returns the block the transaction is in, the hash is empty when it isn't in any of them
+func (t *Tracker) findMined(ctx context.Context, tx Transaction) (string, int64, error) {
+	t.mutex.RLock()
+	tip := t.tip
+	t.mutex.RUnlock()
+
+	from := tx.seenHeight
+	if tx.Status == StatusMined {
+		from = tx.BlockHeight
+	}
+	if from < tip-maxSearchedBlocks+1 {
+		from = tip - maxSearchedBlocks + 1
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from > tip {
+		return "", 0, nil
+	}
+
+	hashCalls := make([]*revo.BatchCall, 0, tip-from+1)
+	for height := from; height <= tip; height++ {
+		hashCalls = append(hashCalls, revo.NewGetBlockHashCall(big.NewInt(height)))
+	}
+	if err := t.revo.Batch(ctx, hashCalls); err != nil {
+		return "", 0, err
+	}
+	blockCalls := make([]*revo.BatchCall, 0, len(hashCalls))
+	for _, call := range hashCalls {
+		if call.Err != nil {
+			return "", 0, call.Err
+		}
+		blockCalls = append(blockCalls, revo.NewGetBlockCall(string(*call.Result.(*revo.GetBlockHashResponse))))
+	}
+	if err := t.revo.Batch(ctx, blockCalls); err != nil {
+		return "", 0, err
+	}
+
+	for i, call := range blockCalls {
+		if call.Err != nil {
+			return "", 0, call.Err
+		}
+		block := call.Result.(*revo.GetBlockResponse)
+		for _, txID := range block.Txs {
+			if normalizeHash(txID) == tx.Hash {
+				return block.Hash, from + int64(i), nil
+			}
+		}
+	}
+	return "", 0, nil
+}
+
+func (t *Tracker) update(hash string, do func(*Transaction)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if tx, ok := t.transactions[hash]; ok {
+		do(tx)
+	}
+}
+
+// setStatus must be called with the mutex held
+func (t *Tracker) setStatus(tx *Transaction, status Status) {
+	if tx.Status != status {
+		tx.Status = status
+		tx.Updated = t.now()
+	}
+}
+
+func decodeRawTx(rawTx string) (*wire.MsgTx, error) {
+	serialized, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid raw transaction")
+	}
+
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(serialized)); err != nil {
+		return nil, errors.Wrap(err, "invalid raw transaction")
+	}
+
+	return &msgTx, nil
+}
+
+func hashRawTx(rawTx string) (string, error) {
+	msgTx, err := decodeRawTx(rawTx)
+	if err != nil {
+		return "", err
+	}
+	return msgTx.TxHash().String(), nil
+}
+
+func normalizeHash(hash string) string {
+	return strings.ToLower(utils.RemoveHexPrefix(hash))
+}
@@ -0,0 +1,204 @@
+package txtracker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/revolutionchain/charon/pkg/eth"
+	"github.com/revolutionchain/charon/pkg/internal"
+	"github.com/revolutionchain/charon/pkg/revo"
+)
+
+func newSignedTestTx(t *testing.T) string {
+	wif, err := btcutil.DecodeWIF("cMbgxCJrTYUqgcmiC1berh5DFrtY1KeU4PXZ6NZxgenniF1mXCRk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := revo.NewTxBuilder(wif)
+	pkScript, err := revo.PayToPubKeyHashScript(builder.SenderPubKeyHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.AddInput(revo.UnspentOutput{
+		TxID:     "7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d",
+		Vout:     0,
+		Satoshis: 100000000,
+		PkScript: pkScript,
+	})
+	if err := builder.AddPayToPubKeyHash(builder.SenderPubKeyHash(), 90000000); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := builder.Sign()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawTx, err := revo.SerializeTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rawTx
+}
+
+func newTestTracker(t *testing.T) (*Tracker, string, interface {
+	AddResponse(string, interface{}) error
+	AddError(string, eth.JSONRPCError) error
+}) {
+	doer := internal.NewDoerMappedMock()
+	revoClient, err := internal.CreateMockedClient(doer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, err := New(revoClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := tracker.Queue(newSignedTestTx(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Broadcasted(hash)
+
+	if err := doer.AddResponse(revo.MethodGetBlockCount, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	return tracker, hash, doer
+}
+
+func notInMempoolError() eth.JSONRPCError {
+	return eth.NewJSONRPCError(-5, "No such mempool or blockchain transaction. Use gettransaction for wallet transactions.", nil)
+}
+
+func TestTrackerReportsMinedTransaction(t *testing.T) {
+	tracker, hash, doer := newTestTracker(t)
+
+	blockHash := "bba11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5"
+	doer.AddResponse(revo.MethodGetRawTransaction, revo.GetRawTransactionResponse{
+		ID:            hash,
+		BlockHash:     blockHash,
+		Confirmations: 3,
+	})
+	doer.AddResponse(revo.MethodGetBlockHeader, revo.GetBlockHeaderResponse{
+		Hash:   blockHash,
+		Height: 98,
+	})
+
+	tracker.poll(context.Background())
+
+	tx, ok := tracker.Get("0x" + hash)
+	if !ok {
+		t.Fatal("transaction is no longer tracked")
+	}
+	if tx.Status != StatusMined {
+		t.Fatalf("expected status %s, got %s", StatusMined, tx.Status)
+	}
+	if tx.BlockHeight != 98 || tx.BlockHash != blockHash {
+		t.Errorf("unexpected block %d %s", tx.BlockHeight, tx.BlockHash)
+	}
+	if confirmations := tracker.Confirmations(tx); confirmations != 3 {
+		t.Errorf("expected 3 confirmations, got %d", confirmations)
+	}
+}
+
+func TestTrackerRebroadcastsDroppedTransaction(t *testing.T) {
+	tracker, hash, doer := newTestTracker(t)
+
+	doer.AddError(revo.MethodGetRawTransaction, notInMempoolError())
+	doer.AddResponse(revo.MethodGetTransactionOut, revo.GetTransactionOutResponse{
+		BestBlockHash:    "bba11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5",
+		ConfirmationsNum: 10,
+		Amount:           1,
+	})
+	doer.AddResponse(revo.MethodSendRawTx, hash)
+
+	tracker.poll(context.Background())
+
+	tx, _ := tracker.Get(hash)
+	if tx.Status != StatusMempool {
+		t.Fatalf("expected status %s, got %s", StatusMempool, tx.Status)
+	}
+	if tx.Rebroadcasts != 1 {
+		t.Errorf("expected 1 rebroadcast, got %d", tx.Rebroadcasts)
+	}
+}
+
+func TestTrackerDetectsConflictedTransaction(t *testing.T) {
+	tracker, hash, doer := newTestTracker(t)
+
+	doer.AddError(revo.MethodGetRawTransaction, notInMempoolError())
+	// gettxout returns null once the output is spent
+	doer.AddResponse(revo.MethodGetTransactionOut, []byte("null"))
+	// and the transaction isn't in the last blocks
+	doer.AddResponse(revo.MethodGetBlockHash, "bba11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5")
+	doer.AddResponse(revo.MethodGetBlock, revo.GetBlockResponse{
+		Hash: "bba11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5",
+		Txs:  []string{"7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d"},
+	})
+
+	tracker.poll(context.Background())
+
+	tx, _ := tracker.Get(hash)
+	if tx.Status != StatusConflicted {
+		t.Fatalf("expected status %s, got %s", StatusConflicted, tx.Status)
+	}
+	if tx.Rebroadcasts != 0 {
+		t.Errorf("conflicted transactions must not be rebroadcast, got %d rebroadcasts", tx.Rebroadcasts)
+	}
+}
+
+func TestTrackerFindsMinedTransactionWithoutTxIndex(t *testing.T) {
+	tracker, hash, doer := newTestTracker(t)
+
+	// in the mempool at the first poll, two blocks later revod without -txindex can't find the transaction and its
+	// input is spent by itself
+	doer.AddResponse(revo.MethodGetBlockCount, 102)
+	doer.AddResponse(revo.MethodGetRawTransaction, revo.GetRawTransactionResponse{ID: hash})
+	doer.AddError(revo.MethodGetRawTransaction, notInMempoolError())
+	tracker.poll(context.Background())
+	if tx, _ := tracker.Get(hash); tx.Status != StatusMempool {
+		t.Fatalf("expected status %s, got %s", StatusMempool, tx.Status)
+	}
+
+	doer.AddResponse(revo.MethodGetTransactionOut, []byte("null"))
+	blockHashes := []string{
+		"a0a11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5",
+		"a1a11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5",
+		"a2a11e1bacc69ba535d478cf1f2e542da3735a517b0b8eebaf7e6bb25eeb48c5",
+	}
+	for i, blockHash := range blockHashes {
+		txs := []string{"7c6a3ba3fbf2a1a9a4a5e1ac2f2a5a5b6c0b8d1d2e4f5a6b7c8d9e0f1a2b3c4d"}
+		if i == 1 {
+			txs = append(txs, hash)
+		}
+		doer.AddResponse(revo.MethodGetBlockHash, blockHash)
+		doer.AddResponse(revo.MethodGetBlock, revo.GetBlockResponse{Hash: blockHash, Txs: txs})
+	}
+
+	tracker.poll(context.Background())
+
+	tx, _ := tracker.Get(hash)
+	if tx.Status != StatusMined {
+		t.Fatalf("expected status %s, got %s", StatusMined, tx.Status)
+	}
+	if tx.BlockHeight != 101 || tx.BlockHash != blockHashes[1] {
+		t.Errorf("unexpected block %d %s", tx.BlockHeight, tx.BlockHash)
+	}
+	if tx.Rebroadcasts != 0 {
+		t.Errorf("mined transactions must not be rebroadcast, got %d rebroadcasts", tx.Rebroadcasts)
+	}
+}
+
+func TestTrackerForgetsRejectedTransaction(t *testing.T) {
+	tracker, hash, _ := newTestTracker(t)
+
+	tracker.Forget(hash)
+
+	if _, ok := tracker.Get(hash); ok {
+		t.Error("expected transaction to be forgotten")
+	}
+}